@@ -0,0 +1,105 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"sort"
+	"time"
+)
+
+// defaultAccessResolution is the resolution of the read times tracked for
+// WithColdStorage when WithAccessTracking is not used.
+const defaultAccessResolution = time.Minute
+
+// WithAccessTracking tracks the approximate time each item was last read by
+// Get, so that ColdKeys can report items that are no longer used. To keep
+// reads cheap the time of a read is only recorded if the time already
+// recorded for the item is older than resolution, so tracked times may be
+// up to resolution earlier than the most recent read. Most reads of
+// frequently used items only take a shared lock. Read times are held in
+// memory and are forgotten when the table is closed, and those of deleted
+// items when it is compacted. A resolution of zero or less disables
+// tracking.
+func WithAccessTracking(resolution time.Duration) Option {
+	return func(t *Table) {
+		t.accessRes = resolution
+	}
+}
+
+// ColdKeys returns the keys of the items in the table that have not been
+// read or written for at least the period d, in sorted order. Soft deleted
+// items are not included. Reads are only counted when the table was opened
+// using WithAccessTracking or WithColdStorage. Items held in memory count
+// as used when the table is opened, so only those held in the cold file by
+// WithColdStorage can be reported until the table has been open for d.
+func (t *Table) ColdKeys(d time.Duration) []string {
+	t.mtx.RLock()
+	now := t.now().UnixNano()
+	var keys []string
+	t.touchMtx.RLock()
+	for k, p := range t.data {
+		if p.deleted != 0 {
+			continue
+		}
+		if now-t.lastAccess(k, p) >= int64(d) {
+			keys = append(keys, k)
+		}
+	}
+	t.touchMtx.RUnlock()
+	t.mtx.RUnlock()
+	sort.Strings(keys)
+	return keys
+}
+
+// touch records that the value stored under key k has been read.
+func (t *Table) touch(k string) {
+	if t.accessRes <= 0 {
+		return
+	}
+	now := t.now().UnixNano()
+	t.touchMtx.RLock()
+	last, found := t.touched[k]
+	t.touchMtx.RUnlock()
+	if found && now-last < int64(t.accessRes) {
+		return
+	}
+
+	t.touchMtx.Lock()
+	if t.touched == nil {
+		t.touched = make(map[string]int64)
+	}
+	if now > t.touched[k] {
+		t.touched[k] = now
+	}
+	t.touchMtx.Unlock()
+}
+
+// lastAccess returns the approximate time, in nanoseconds since the unix
+// epoch, that the item p stored under key k was last read or written.
+// It is the responsibility of the caller to acquire locks, including
+// touchMtx.
+func (t *Table) lastAccess(k string, p item) int64 {
+	last := max(p.updated, t.touched[k])
+	if !p.cold {
+		// Items are not known to be idle until they have been held in
+		// memory for the period
+		last = max(last, t.opened.UnixNano())
+	}
+	return last
+}
+
+// forgetAccess discards the read times of items that are no longer in the
+// table.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) forgetAccess() {
+	t.touchMtx.Lock()
+	defer t.touchMtx.Unlock()
+	for k := range t.touched {
+		if _, exists := t.data[k]; !exists {
+			delete(t.touched, k)
+		}
+	}
+}
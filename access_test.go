@@ -0,0 +1,78 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestColdKeys(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	table, err := New("", 50, WithAccessTracking(time.Minute), withClock(clock))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, k := range []string{"a", "b", "c", "d"} {
+		if err := table.PutString(k, "val"); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if keys := table.ColdKeys(time.Hour); len(keys) != 0 {
+		t.Errorf("got cold keys %v, wanted none", keys)
+	}
+
+	now = now.Add(90 * time.Minute)
+	table.Get("a")
+	if err := table.PutString("b", "new"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.Delete("d"); err != nil {
+		t.Fatal(err.Error())
+	}
+	want := []string{"c"}
+	if keys := table.ColdKeys(time.Hour); !reflect.DeepEqual(keys, want) {
+		t.Errorf("got cold keys %v, wanted %v", keys, want)
+	}
+
+	// Reads within the resolution of the last recorded read are not
+	// recorded
+	now = now.Add(30 * time.Second)
+	table.Get("a")
+	now = now.Add(90*time.Minute - 30*time.Second)
+	want = []string{"a", "b", "c"}
+	if keys := table.ColdKeys(time.Hour); !reflect.DeepEqual(keys, want) {
+		t.Errorf("got cold keys %v, wanted %v", keys, want)
+	}
+	want = []string{"c"}
+	if keys := table.ColdKeys(2 * time.Hour); !reflect.DeepEqual(keys, want) {
+		t.Errorf("got cold keys %v, wanted %v", keys, want)
+	}
+}
+
+func TestColdKeysUntracked(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	table, err := New("", 50, withClock(clock))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.PutString("a", "val"); err != nil {
+		t.Fatal(err.Error())
+	}
+	now = now.Add(2 * time.Hour)
+	table.Get("a")
+	want := []string{"a"}
+	if keys := table.ColdKeys(time.Hour); !reflect.DeepEqual(keys, want) {
+		t.Errorf("got cold keys %v, wanted %v", keys, want)
+	}
+	if len(table.touched) != 0 {
+		t.Errorf("got %d read times tracked, wanted none", len(table.touched))
+	}
+}
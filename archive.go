@@ -0,0 +1,171 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/iand/lash/format"
+)
+
+// Names of the entries in a backup archive.
+const (
+	archiveManifest = "manifest.json"
+	archiveData     = "data.lash"
+)
+
+// ErrArchive is returned by RestoreArchive when an archive is incomplete or
+// does not match its manifest.
+var ErrArchive = errors.New("lash: invalid backup archive")
+
+// An ArchiveManifest describes the data file held in a backup archive.
+type ArchiveManifest struct {
+	FormatVersion int       `json:"format_version"` // version of the data file format
+	Checksum      string    `json:"checksum"`       // algorithm used to checksum records
+	SHA256        string    `json:"sha256"`         // hex encoded SHA-256 digest of the data file
+	Size          int64     `json:"size"`           // size of the data file in bytes
+	Keys          int       `json:"keys"`           // number of items, excluding soft deleted items
+	Seq           uint64    `json:"seq"`            // sequence number of the last write
+	Created       time.Time `json:"created"`        // time the archive was created
+	LastUpdated   time.Time `json:"last_updated"`   // time of the most recent write to an item
+}
+
+// BackupArchive writes a gzip compressed tar archive to w holding a snapshot
+// of the table, in the format of a compacted data file, and a manifest that
+// describes it. The snapshot is staged in a temporary file while the archive
+// is written. The archive may be restored using RestoreArchive, or unpacked
+// and the data file opened using New.
+func (t *Table) BackupArchive(w io.Writer) (*ArchiveManifest, error) {
+	f, err := os.CreateTemp("", "lash-backup")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	h := sha256.New()
+	m := &ArchiveManifest{FormatVersion: format.Version, Created: t.now().UTC()}
+	t.mtx.RLock()
+	// Values are not compressed individually since the archive is
+	// compressed as a whole
+	_, m.Size, err = t.writeSnapshot(io.MultiWriter(f, h), nil, nil, nil)
+	m.Checksum = t.checksum.String()
+	m.Keys = len(t.data) - t.trashed
+	m.Seq = t.seq
+	var updated int64
+	for _, p := range t.data {
+		if p.deleted == 0 && p.updated > updated {
+			updated = p.updated
+		}
+	}
+	t.mtx.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	m.SHA256 = hex.EncodeToString(h.Sum(nil))
+	if updated != 0 {
+		m.LastUpdated = unixTime(updated).UTC()
+	}
+
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	entries := []struct {
+		name string
+		size int64
+		r    io.Reader
+	}{
+		{name: archiveManifest, size: int64(len(manifest)), r: bytes.NewReader(manifest)},
+		{name: archiveData, size: m.Size, r: f},
+	}
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name:    e.name,
+			Mode:    0o644,
+			Size:    e.size,
+			ModTime: m.Created,
+			Format:  tar.FormatPAX,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := io.CopyN(tw, e.r, e.size); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RestoreArchive reads an archive written by BackupArchive from r and stores
+// the items and metadata it holds in the table, replacing any existing
+// values stored under the same keys, as ReadFrom does. The data file is
+// verified against the archive's manifest before any items are stored and
+// ErrArchive is returned if they do not match. It returns the manifest.
+func (t *Table) RestoreArchive(r io.Reader) (*ArchiveManifest, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+
+	var m *ArchiveManifest
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			if err == io.EOF {
+				return nil, ErrArchive
+			}
+			return nil, err
+		}
+		switch hdr.Name {
+		case archiveManifest:
+			m = &ArchiveManifest{}
+			if err := json.NewDecoder(tr).Decode(m); err != nil {
+				return nil, err
+			}
+		case archiveData:
+			if m == nil {
+				return nil, ErrArchive
+			}
+			h := sha256.New()
+			cr := &countingWriter{w: h}
+			snap, _, err := readSnapshot(io.TeeReader(tr, cr))
+			if err != nil {
+				return nil, err
+			}
+			if _, err := io.Copy(cr, tr); err != nil {
+				return nil, err
+			}
+			if cr.n != m.Size || hex.EncodeToString(h.Sum(nil)) != m.SHA256 || len(snap.data)-snap.trashed != m.Keys {
+				return nil, ErrArchive
+			}
+			return m, t.restore(snap)
+		}
+	}
+}
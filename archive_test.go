@@ -0,0 +1,152 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestBackupArchive(t *testing.T) {
+	src, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer src.Close()
+
+	src.PutString("a", "1")
+	src.PutString("b", "2")
+	src.PutString("c", "3")
+	src.SoftDelete("c")
+	src.SetMetadata("schema", "4")
+
+	var buf bytes.Buffer
+	m, err := src.BackupArchive(&buf)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if m.Keys != 2 {
+		t.Errorf("got %d keys, wanted %d", m.Keys, 2)
+	}
+	if m.Checksum != "crc32c" {
+		t.Errorf("got checksum %q, wanted %q", m.Checksum, "crc32c")
+	}
+	if m.LastUpdated.IsZero() {
+		t.Errorf("got zero last updated time, wanted non-zero")
+	}
+
+	// The unpacked data file can be opened directly
+	data := archiveEntry(t, buf.Bytes(), archiveData)
+	if int64(len(data)) != m.Size {
+		t.Errorf("got data file size %d, wanted %d", len(data), m.Size)
+	}
+	df, err := os.CreateTemp("", "lash")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(df.Name())
+	df.Write(data)
+	df.Close()
+	unpacked, err := New(df.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if unpacked.Len() != 2 {
+		t.Errorf("got len %d, wanted %d", unpacked.Len(), 2)
+	}
+	unpacked.Close()
+
+	dst, err := New("", 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	rm, err := dst.RestoreArchive(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if rm.SHA256 != m.SHA256 {
+		t.Errorf("got digest %q, wanted %q", rm.SHA256, m.SHA256)
+	}
+	for k, want := range map[string]string{"a": "1", "b": "2"} {
+		if got, _ := dst.GetString(k); got != want {
+			t.Errorf("got %q, wanted %q", got, want)
+		}
+	}
+	if _, found := dst.Get("c"); found {
+		t.Errorf("got found for soft deleted key, wanted not found")
+	}
+	if v, _ := dst.Metadata("schema"); v != "4" {
+		t.Errorf("got metadata %q, wanted %q", v, "4")
+	}
+}
+
+func TestRestoreArchiveMismatch(t *testing.T) {
+	src, err := New("", 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	src.PutString("a", "1")
+
+	var buf bytes.Buffer
+	if _, err := src.BackupArchive(&buf); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Rebuild the archive with a manifest that does not match the data
+	manifest := bytes.Replace(archiveEntry(t, buf.Bytes(), archiveManifest), []byte(`"keys": 1`), []byte(`"keys": 2`), 1)
+	data := archiveEntry(t, buf.Bytes(), archiveData)
+	var tampered bytes.Buffer
+	zw := gzip.NewWriter(&tampered)
+	tw := tar.NewWriter(zw)
+	for _, e := range []struct {
+		name string
+		b    []byte
+	}{{archiveManifest, manifest}, {archiveData, data}} {
+		tw.WriteHeader(&tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.b))})
+		tw.Write(e.b)
+	}
+	tw.Close()
+	zw.Close()
+
+	dst, err := New("", 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := dst.RestoreArchive(&tampered); err != ErrArchive {
+		t.Errorf("got error %v, wanted %v", err, ErrArchive)
+	}
+	if dst.Len() != 0 {
+		t.Errorf("got len %d, wanted %d", dst.Len(), 0)
+	}
+}
+
+// archiveEntry returns the contents of the named entry in a backup archive.
+func archiveEntry(t *testing.T, archive []byte, name string) []byte {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			t.Fatalf("entry %q not found: %v", name, err)
+		}
+		if hdr.Name == name {
+			b, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			return b
+		}
+	}
+}
@@ -0,0 +1,249 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrClosed is returned when an operation is attempted on a closed table.
+var ErrClosed = errors.New("lash: table is closed")
+
+const (
+	asyncQueueLen = 1024 // number of writes that may be queued before PutAsync blocks
+	maxBatch      = 256  // maximum number of writes committed by a single fsync
+)
+
+// A WriteResult reports the outcome of a write submitted using PutAsync.
+type WriteResult struct {
+	done chan struct{}
+	err  error
+}
+
+// Done returns a channel that is closed when the write has completed,
+// either by being written to the data file, and committed to persistent
+// storage if the table's sync policy requires it, or by failing.
+func (r *WriteResult) Done() <-chan struct{} {
+	return r.done
+}
+
+// Err waits for the write to complete and returns any error encountered
+// while writing it.
+func (r *WriteResult) Err() error {
+	<-r.done
+	return r.err
+}
+
+func (r *WriteResult) complete(err error) {
+	r.err = err
+	close(r.done)
+}
+
+type asyncWrite struct {
+	key    string
+	val    []byte
+	result *WriteResult
+}
+
+// A Priority determines how soon a write submitted using PutAsync is
+// committed relative to other waiting writes.
+type Priority int
+
+const (
+	// PriorityNormal writes are committed in the order they were submitted.
+	PriorityNormal Priority = iota
+
+	// PriorityHigh writes are committed ahead of any waiting normal priority
+	// writes, in the next batch to be committed. They are queued separately
+	// so they do not wait for space when the queue of normal priority writes
+	// is full. They suit critical writes such as commit markers that must
+	// not be delayed by bulk background writes.
+	PriorityHigh
+)
+
+// pipeline batches asynchronous writes so that many are committed by a single
+// fsync of the data file.
+type pipeline struct {
+	mu     sync.RWMutex // guards closed and sends on queue and urgent
+	closed bool
+	queue  chan *asyncWrite
+	urgent chan *asyncWrite // high priority writes
+	done   chan struct{}    // closed when the pipeline has stopped
+}
+
+// PutAsync submits a write of the value v under key k and returns without
+// waiting for it to be persisted. The returned WriteResult reports when the
+// write has completed, after which the value is visible to Get. Whether the
+// write has then been committed to persistent storage depends on the
+// table's sync policy, as it does for Put: it has under the default
+// SyncAlways policy, but under SyncNever or SyncInterval, or between calls
+// to BeginBulk and EndBulk, it may be lost if the machine crashes. Writes
+// submitted by PutAsync with the same priority are applied in the order
+// they were submitted and are batched so that many share a single fsync.
+// The priority is PriorityNormal unless overridden by passing PriorityHigh,
+// in which case the write may be applied before normal priority writes
+// submitted earlier, including writes to the same key. PutAsync blocks if
+// too many writes with the same priority are waiting to be committed.
+func (t *Table) PutAsync(k string, v []byte, pri ...Priority) *WriteResult {
+	r := &WriteResult{done: make(chan struct{})}
+	if t.readonly {
+		r.complete(ErrReadOnly)
+		return r
+	}
+	if err := t.checkPut(k, v); err != nil {
+		r.complete(err)
+		return r
+	}
+	t.putLimit.wait()
+
+	p := t.startPipeline()
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		r.complete(ErrClosed)
+		return r
+	}
+	queue := p.queue
+	if len(pri) > 0 && pri[len(pri)-1] == PriorityHigh {
+		queue = p.urgent
+	}
+	queue <- &asyncWrite{key: k, val: v, result: r}
+	return r
+}
+
+// startPipeline starts the table's write pipeline if it is not already running.
+func (t *Table) startPipeline() *pipeline {
+	t.pipelineOnce.Do(func() {
+		t.pipeline = &pipeline{
+			queue:  make(chan *asyncWrite, asyncQueueLen),
+			urgent: make(chan *asyncWrite, asyncQueueLen),
+			done:   make(chan struct{}),
+		}
+		go t.runPipeline(t.pipeline)
+	})
+	return t.pipeline
+}
+
+func (t *Table) runPipeline(p *pipeline) {
+	defer close(p.done)
+	batch := make([]*asyncWrite, 0, maxBatch)
+	// Each queue is set to nil once it has been closed and drained
+	queue, urgent := p.queue, p.urgent
+	for queue != nil || urgent != nil {
+		var w *asyncWrite
+		var ok bool
+		select {
+		case w, ok = <-urgent:
+			if !ok {
+				urgent = nil
+				continue
+			}
+		case w, ok = <-queue:
+			if !ok {
+				queue = nil
+				continue
+			}
+		}
+		batch = append(batch[:0], w)
+
+		// Fill the batch with writes that are already waiting, taking
+		// high priority writes first
+	fill:
+		for len(batch) < maxBatch {
+			select {
+			case w, ok := <-urgent:
+				if !ok {
+					urgent = nil
+				} else {
+					batch = append(batch, w)
+				}
+				continue
+			default:
+			}
+			select {
+			case w, ok := <-queue:
+				if !ok {
+					queue = nil
+					break fill
+				}
+				batch = append(batch, w)
+			default:
+				break fill
+			}
+		}
+		t.commitBatch(batch)
+	}
+}
+
+// commitBatch writes a batch of values to the data file, commits them with
+// a single fsync and then applies them to the table.
+func (t *Table) commitBatch(batch []*asyncWrite) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	type pending struct {
+		w   *asyncWrite
+		add item
+	}
+	written := make([]pending, 0, len(batch))
+	latest := make(map[string]item, len(batch))
+	for _, w := range batch {
+		old, exists := latest[w.key]
+		if !exists {
+			old, exists = t.data[w.key]
+		}
+		add := t.newItem(w.val, old, exists)
+
+		var err error
+		add.pos, add.size, err = t.writeNoSync(add.record(w.key))
+		if err != nil {
+			w.result.complete(err)
+			continue
+		}
+		latest[w.key] = add
+		written = append(written, pending{w: w, add: add})
+	}
+
+	if err := t.sync(); err != nil {
+		for _, p := range written {
+			// Prevent the unapplied record from being loaded on restart
+			t.mark(p.add.pos, t.itemSize(p.w.key, p.add))
+			p.w.result.complete(err)
+		}
+		return
+	}
+
+	// The writes are only mirrored once they have been committed
+	for _, p := range written {
+		old, exists := t.data[p.w.key]
+		err := t.replace(p.w.key, p.add, old, exists)
+		if err == nil {
+			t.mirror(p.add.record(p.w.key))
+		}
+		p.w.result.complete(err)
+	}
+}
+
+// stopPipeline waits for all submitted writes to complete and stops the
+// table's write pipeline, if it was started.
+func (t *Table) stopPipeline() {
+	t.pipelineOnce.Do(func() {
+		// The pipeline was never started so install one that is already
+		// stopped to reject any later writes.
+		t.pipeline = &pipeline{closed: true, done: make(chan struct{})}
+		close(t.pipeline.done)
+	})
+	p := t.pipeline
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+		close(p.urgent)
+	}
+	p.mu.Unlock()
+	<-p.done
+}
@@ -0,0 +1,116 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestPutAsync(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	results := make([]*WriteResult, 0, 100)
+	for i := 0; i < 100; i++ {
+		results = append(results, table.PutAsync(fmt.Sprintf("k%d", i%10), []byte(fmt.Sprintf("v%d", i))))
+	}
+	for _, r := range results {
+		<-r.Done()
+		if err := r.Err(); err != nil {
+			t.Fatalf("got error %v, wanted nil", err)
+		}
+	}
+
+	if table.Len() != 10 {
+		t.Errorf("got len %d, wanted %d", table.Len(), 10)
+	}
+	v, found := table.Get("k3")
+	if !found {
+		t.Fatalf("got not found, wanted found")
+	}
+	if string(v) != "v93" {
+		t.Errorf("got %q, wanted %q", v, "v93")
+	}
+	m, _ := table.GetMeta("k3")
+	if m.Writes != 10 {
+		t.Errorf("got writes %d, wanted %d", m.Writes, 10)
+	}
+
+	// Writes still pending when the table is closed are completed
+	r := table.PutAsync("last", []byte("val"))
+	table.Close()
+	if err := r.Err(); err != nil {
+		t.Fatalf("got error %v, wanted nil", err)
+	}
+
+	err = table.PutAsync("closed", []byte("val")).Err()
+	if err != ErrClosed {
+		t.Errorf("got error %v, wanted %v", err, ErrClosed)
+	}
+
+	table2, err := New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table2.Close()
+	if table2.Len() != 11 {
+		t.Errorf("got len %d, wanted %d", table2.Len(), 11)
+	}
+	v, _ = table2.Get("k3")
+	if string(v) != "v93" {
+		t.Errorf("got %q, wanted %q", v, "v93")
+	}
+}
+
+func TestPutAsyncClosedUnstarted(t *testing.T) {
+	table, err := New("", 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Close()
+
+	err = table.PutAsync("a", []byte("val")).Err()
+	if err != ErrClosed {
+		t.Errorf("got error %v, wanted %v", err, ErrClosed)
+	}
+}
+
+func TestPutAsyncPriority(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	// Hold the lock so that the waiting writes cannot be committed until
+	// the high priority write has been submitted
+	table.mtx.Lock()
+	results := make([]*WriteResult, 0, 1001)
+	for i := 0; i < 1000; i++ {
+		results = append(results, table.PutAsync(fmt.Sprintf("k%d", i), []byte("bulk")))
+	}
+	results = append(results, table.PutAsync("marker", []byte("commit"), PriorityHigh))
+	table.mtx.Unlock()
+
+	for _, r := range results {
+		if err := r.Err(); err != nil {
+			t.Fatalf("got error %v, wanted nil", err)
+		}
+	}
+
+	table.mtx.RLock()
+	marker, last := table.data["marker"].seq, table.data["k999"].seq
+	table.mtx.RUnlock()
+	if marker > last {
+		t.Errorf("got marker committed after last bulk write (seq %d > %d), wanted before", marker, last)
+	}
+}
@@ -0,0 +1,290 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/iand/lash/format"
+)
+
+// ErrNotCompacted is returned by AttachSnapshot when the file holds records
+// that are only written to the data file of a table between compactions.
+var ErrNotCompacted = errors.New("lash: snapshot is not compacted")
+
+// An Attached serves reads from a snapshot file that has been mapped into
+// memory. Values are read from the mapping rather than copied onto the heap,
+// which holds only a sorted list of record offsets, so many large snapshots
+// may be attached cheaply. An Attached is safe for concurrent use.
+type Attached struct {
+	mu      sync.RWMutex // guards data against Close
+	data    []byte       // contents of the mapped file
+	unmap   func() error
+	version int
+	codec   *format.Codec // decompresses values, nil if they are not compressed
+	offs    []int64       // offsets of the put records of live keys, sorted by key
+	pins    atomic.Int64  // number of unreleased values returned by GetPinned
+	closed  bool          // Close has been called, the mapping may await release of pins
+}
+
+// AttachSnapshot maps the snapshot file named fname, written by WriteTo or
+// by compacting a table, into memory read-only. Every record is verified
+// before the snapshot is attached. Soft deleted items are not served. The
+// file must not be modified while it is attached.
+func AttachSnapshot(fname string) (*Attached, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	data, unmap, err := mapFile(f, fi.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Attached{data: data, unmap: unmap}
+	if err := a.load(); err != nil {
+		unmap()
+		return nil, err
+	}
+	return a, nil
+}
+
+// load verifies the records in the mapped file and builds the list of
+// offsets of live keys.
+func (a *Attached) load() error {
+	d, err := format.NewDecoder(bytes.NewReader(a.data))
+	if err != nil {
+		return err
+	}
+	if d.Version() == 0 {
+		return errors.New("lash: cannot attach a data file in the legacy format")
+	}
+	a.version = d.Version()
+	a.codec = d.Codec()
+
+	type entry struct {
+		off  int64
+		kind byte
+	}
+	var entries []entry
+	for {
+		off := d.Offset()
+		rec, err := d.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch rec.Kind {
+		case kindPut, kindSoftDelete, kindDelete, kindUndelete:
+			entries = append(entries, entry{off: off, kind: rec.Kind})
+		case kindMeta, tomb:
+		case kindDelta, kindOp:
+			return ErrNotCompacted
+		default:
+			return ErrCorrupt
+		}
+	}
+
+	// Group the records for each key, retaining the order in which they
+	// were written, so the last determines whether the key is live.
+	sort.SliceStable(entries, func(i, j int) bool {
+		return a.key(entries[i].off) < a.key(entries[j].off)
+	})
+	for i := 0; i < len(entries); {
+		k := a.key(entries[i].off)
+		live, put := false, int64(-1)
+		for ; i < len(entries) && a.key(entries[i].off) == k; i++ {
+			switch e := entries[i]; e.kind {
+			case kindPut:
+				live, put = true, e.off
+			case kindSoftDelete, kindDelete:
+				live = false
+			case kindUndelete:
+				live = put >= 0
+			}
+		}
+		if live {
+			a.offs = append(a.offs, put)
+		}
+	}
+	return nil
+}
+
+// key returns the key of the record at off without copying it.
+func (a *Attached) key(off int64) string {
+	b := a.data[off+1:]
+	n, w := binary.Uvarint(b)
+	return unsafeString(b[w : w+int(n)])
+}
+
+// unsafeString returns a string that shares the bytes of b, which must not
+// be modified while the string is in use.
+func unsafeString(b []byte) string {
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
+
+// value returns the value of the record at off.
+func (a *Attached) value(off int64) []byte {
+	b := a.data[off+1:]
+	n, w := binary.Uvarint(b)
+	b = b[w+int(n):]
+	fields := 0
+	if a.version >= 2 {
+		fields = 3 // created, updated and writes
+	}
+	if a.version >= 3 {
+		fields++ // seq
+	}
+	for i := 0; i < fields; i++ {
+		_, w = binary.Uvarint(b)
+		b = b[w:]
+	}
+	n, w = binary.Uvarint(b)
+	return b[w : w+int(n) : w+int(n)]
+}
+
+// Get returns the value stored under key k and reports whether it was
+// found. Unless the snapshot's values are compressed, the returned slice
+// refers to the mapped file; it must not be modified and must not be used
+// after Close.
+func (a *Attached) Get(k string) ([]byte, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.get(k)
+}
+
+// get returns the value stored under key k and reports whether it was found.
+// It is the responsibility of the caller to acquire locks.
+func (a *Attached) get(k string) ([]byte, bool) {
+	i := sort.Search(len(a.offs), func(i int) bool { return a.key(a.offs[i]) >= k })
+	if i == len(a.offs) || a.key(a.offs[i]) != k {
+		return nil, false
+	}
+	v := a.value(a.offs[i])
+	if a.codec != nil {
+		// The value was decompressed successfully when the snapshot was
+		// attached
+		v, _ = a.codec.Decompress(nil, v)
+	}
+	return v, true
+}
+
+// Has reports whether a value is stored under key k.
+func (a *Attached) Has(k string) bool {
+	_, ok := a.Get(k)
+	return ok
+}
+
+// Len returns the number of keys in the snapshot.
+func (a *Attached) Len() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.offs)
+}
+
+// Keys returns the keys in the snapshot that begin with prefix, in sorted
+// order.
+func (a *Attached) Keys(prefix string) []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	i := sort.Search(len(a.offs), func(i int) bool { return a.key(a.offs[i]) >= prefix })
+	var keys []string
+	for ; i < len(a.offs); i++ {
+		k := a.key(a.offs[i])
+		if !strings.HasPrefix(k, prefix) {
+			break
+		}
+		keys = append(keys, strings.Clone(k))
+	}
+	return keys
+}
+
+// First returns the smallest key in the snapshot and reports whether the
+// snapshot holds any keys.
+func (a *Attached) First() (string, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.keyAt(0)
+}
+
+// Last returns the largest key in the snapshot and reports whether the
+// snapshot holds any keys.
+func (a *Attached) Last() (string, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.keyAt(len(a.offs) - 1)
+}
+
+// SeekGE returns the smallest key in the snapshot that is greater than or
+// equal to k and reports whether there is one.
+func (a *Attached) SeekGE(k string) (string, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.keyAt(sort.Search(len(a.offs), func(i int) bool { return a.key(a.offs[i]) >= k }))
+}
+
+// SeekLE returns the largest key in the snapshot that is less than or equal
+// to k and reports whether there is one.
+func (a *Attached) SeekLE(k string) (string, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.keyAt(sort.Search(len(a.offs), func(i int) bool { return a.key(a.offs[i]) > k }) - 1)
+}
+
+// keyAt returns a copy of the key at index i of the sorted offsets and
+// reports whether i is within range.
+// It is the responsibility of the caller to acquire locks.
+func (a *Attached) keyAt(i int) (string, bool) {
+	if i < 0 || i >= len(a.offs) {
+		return "", false
+	}
+	return strings.Clone(a.key(a.offs[i])), true
+}
+
+// Close releases the mapping of the snapshot file. Values returned by Get
+// must not be used after Close. If values returned by GetPinned have not
+// been released then the mapping is released along with the last of them
+// instead, though the snapshot serves no further reads.
+func (a *Attached) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+	a.offs = nil
+	if a.pins.Load() > 0 {
+		return nil
+	}
+	return a.release()
+}
+
+// release unmaps the snapshot file.
+// It is the responsibility of the caller to acquire locks.
+func (a *Attached) release() error {
+	if a.unmap == nil {
+		return nil
+	}
+	err := a.unmap()
+	a.data, a.unmap = nil, nil
+	return err
+}
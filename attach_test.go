@@ -0,0 +1,139 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAttachSnapshot(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	for _, k := range []string{"b", "a", "c", "ab", "d"} {
+		if err := table.Put(k, []byte("value "+k)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := table.Put("a", []byte("new value a")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.SoftDelete("c"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.Delete("d"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	fname := filepath.Join(t.TempDir(), "snapshot")
+	f, err := os.Create(fname)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := table.WriteTo(f); err != nil {
+		t.Fatal(err.Error())
+	}
+	f.Close()
+
+	a, err := AttachSnapshot(fname)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer a.Close()
+
+	if a.Len() != 3 {
+		t.Errorf("got length %d, wanted %d", a.Len(), 3)
+	}
+	for k, want := range map[string]string{"a": "new value a", "ab": "value ab", "b": "value b"} {
+		v, ok := a.Get(k)
+		if !ok {
+			t.Errorf("got no value for %q, wanted %q", k, want)
+			continue
+		}
+		if string(v) != want {
+			t.Errorf("got %q, wanted %q", v, want)
+		}
+	}
+	for _, k := range []string{"c", "d", "e"} {
+		if a.Has(k) {
+			t.Errorf("got value for %q, wanted none", k)
+		}
+	}
+	if got := strings.Join(a.Keys("a"), " "); got != "a ab" {
+		t.Errorf("got keys %q, wanted %q", got, "a ab")
+	}
+
+	seeks := []struct {
+		name string
+		fn   func() (string, bool)
+		want string
+	}{
+		{"First", a.First, "a"},
+		{"Last", a.Last, "b"},
+		{"SeekGE(aa)", func() (string, bool) { return a.SeekGE("aa") }, "ab"},
+		{"SeekGE(ab)", func() (string, bool) { return a.SeekGE("ab") }, "ab"},
+		{"SeekGE(c)", func() (string, bool) { return a.SeekGE("c") }, ""},
+		{"SeekLE(aa)", func() (string, bool) { return a.SeekLE("aa") }, "a"},
+		{"SeekLE(ab)", func() (string, bool) { return a.SeekLE("ab") }, "ab"},
+		{"SeekLE(0)", func() (string, bool) { return a.SeekLE("0") }, ""},
+	}
+	for _, s := range seeks {
+		got, ok := s.fn()
+		if got != s.want || ok != (s.want != "") {
+			t.Errorf("%s: got %q, %v, wanted %q", s.name, got, ok, s.want)
+		}
+	}
+
+	if err := a.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if a.Len() != 0 {
+		t.Errorf("got length %d after close, wanted %d", a.Len(), 0)
+	}
+	if k, ok := a.First(); ok {
+		t.Errorf("got first key %q after close, wanted none", k)
+	}
+}
+
+func TestAttachSnapshotNotCompacted(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	// Incrementing an existing counter appends a delta record
+	for i := 0; i < 2; i++ {
+		if _, err := table.Counters().Incr("n", 1); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	_, err = AttachSnapshot(tf.Name())
+	if err != ErrNotCompacted {
+		t.Errorf("got %v, wanted %v", err, ErrNotCompacted)
+	}
+
+	if err := table.Compact(); err != nil {
+		t.Fatal(err.Error())
+	}
+	a, err := AttachSnapshot(tf.Name())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer a.Close()
+	if !a.Has("n") {
+		t.Errorf("got no value for %q, wanted one", "n")
+	}
+}
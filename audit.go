@@ -0,0 +1,246 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// WithAuditLog records every change made to the table in an append-only
+// audit log held in the file fname, which is created if it does not exist.
+// Each change is recorded before it is written to the table's data file,
+// and the log is committed to stable storage whenever the data file is, so
+// no committed change goes unrecorded. Entries for changes abandoned before
+// they were committed, such as by a ReadFrom that fails part way through,
+// are removed. The log is never compacted. Use AuditSince to read it.
+func WithAuditLog(fname string) Option {
+	return func(t *Table) {
+		t.auditName = fname
+	}
+}
+
+// An AuditEntry records a change made to a table opened using WithAuditLog.
+type AuditEntry struct {
+	// Time is the time the change was made.
+	Time time.Time `json:"time"`
+
+	// Op names the kind of change: put, delete, softdelete, undelete,
+	// delta for counter increments, op for set and list operations, meta
+	// for table metadata, or swap for the replacement of the table's
+	// entire contents by SwapFile or Swap.
+	Op string `json:"op"`
+
+	// Seq is the sequence number of the write that made the change, or
+	// for a swap the last sequence number used before it.
+	Seq uint64 `json:"seq"`
+
+	// Key is the key that was changed, or empty for a swap, which is
+	// recorded by a single entry rather than one for each key.
+	Key string `json:"key"`
+
+	// Actor identifies who made the change if it was made using PutCtx or
+	// DeleteCtx with a context returned by WithActor.
+	Actor string `json:"actor,omitempty"`
+
+	// PrevHash is the hex encoded SHA-256 hash of the value stored under
+	// the key before the change, or empty if there was none.
+	PrevHash string `json:"prev,omitempty"`
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx that identifies actor as the one making
+// any changes to a table using the context, for recording in its audit log.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor attached to ctx by WithActor, or an empty
+// string if there is none.
+func ActorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// AuditSince returns the entries in the table's audit log for changes made
+// at or after the time since, oldest first. It returns an error if the
+// table was not opened using WithAuditLog.
+func (t *Table) AuditSince(since time.Time) ([]AuditEntry, error) {
+	if t.auditName == "" {
+		return nil, errors.New("lash: table has no audit log")
+	}
+	// Prevent entries being appended while the log is read
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	f, err := os.Open(t.auditName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []AuditEntry
+	dec := json.NewDecoder(f)
+	for {
+		var e AuditEntry
+		if err := dec.Decode(&e); err != nil {
+			if err == io.EOF {
+				return entries, nil
+			}
+			return nil, err
+		}
+		if !e.Time.Before(since) {
+			entries = append(entries, e)
+		}
+	}
+}
+
+// openAudit opens the table's audit log for appending.
+func (t *Table) openAudit() error {
+	f, err := os.OpenFile(t.auditName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, os.FileMode(0666))
+	if err != nil {
+		return ioError("open audit log", err)
+	}
+	t.audit = f
+	return nil
+}
+
+// auditSize returns the size of the table's audit log, or zero if it has
+// none, so that entries appended after it can later be removed by
+// truncateAudit.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) auditSize() (int64, error) {
+	if t.audit == nil {
+		return 0, nil
+	}
+	fi, err := t.audit.Stat()
+	if err != nil {
+		return 0, ioError("stat audit log", err)
+	}
+	return fi.Size(), nil
+}
+
+// truncateAudit removes the entries appended to the table's audit log, if it
+// has one, since it was size bytes long, for changes that were abandoned
+// before being committed.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) truncateAudit(size int64) {
+	if t.audit == nil {
+		return
+	}
+	if err := t.audit.Truncate(size); err != nil {
+		t.logger.Error("lash: failed to remove abandoned changes from audit log", "file", t.auditName, "error", err)
+	}
+}
+
+// auditRecord appends an entry for the change made by writing rec to the
+// table's audit log, if it has one.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) auditRecord(rec record) error {
+	if t.audit == nil {
+		return nil
+	}
+	var prev []byte
+	var found bool
+	if rec.kind == kindMeta {
+		var m metaItem
+		if m, found = t.meta[rec.key]; found {
+			prev = []byte(m.val)
+		}
+	} else if p, exists := t.data[rec.key]; exists {
+		var err error
+		if prev, err = t.value(p); err != nil {
+			return err
+		}
+		found = true
+	}
+	return t.writeAudit(rec, prev, found)
+}
+
+// writeAudit appends an entry for the change made by writing rec to the
+// table's audit log, which must be open. If found is true then prev is the
+// value stored under the key before the change.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) writeAudit(rec record, prev []byte, found bool) error {
+	e := AuditEntry{
+		Time:  t.now(),
+		Op:    auditOps[rec.kind],
+		Seq:   rec.seq,
+		Key:   rec.key,
+		Actor: t.actor,
+	}
+	if found {
+		h := sha256.Sum256(prev)
+		e.PrevHash = hex.EncodeToString(h[:])
+	}
+	return t.appendAudit(e)
+}
+
+// auditSwap appends a single entry for the replacement of the table's
+// contents by SwapFile or Swap to the table's audit log, if it has one, and
+// commits it to stable storage so that it is recorded before the contents
+// are replaced.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) auditSwap() error {
+	if t.audit == nil {
+		return nil
+	}
+	err := t.appendAudit(AuditEntry{Time: t.now(), Op: "swap", Seq: t.seq, Actor: t.actor})
+	if err != nil {
+		return err
+	}
+	if err := t.syncFile(t.audit.Sync); err != nil {
+		if err == ErrFsyncTimeout {
+			return err
+		}
+		return ioError("sync audit log", err)
+	}
+	return nil
+}
+
+// appendAudit appends the entry e to the table's audit log, which must be
+// open.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) appendAudit(e AuditEntry) error {
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = t.audit.Write(append(buf, '\n'))
+	return t.recordIOError("write audit log", -1, e.Key, err)
+}
+
+// closeAudit closes the table's audit log, if it has one. Later changes
+// are not recorded.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) closeAudit() error {
+	if t.audit == nil {
+		return nil
+	}
+	err := t.audit.Sync()
+	if cerr := t.audit.Close(); err == nil {
+		err = cerr
+	}
+	t.audit = nil
+	return err
+}
+
+var auditOps = map[byte]string{
+	kindPut:        "put",
+	kindDelete:     "delete",
+	kindSoftDelete: "softdelete",
+	kindUndelete:   "undelete",
+	kindDelta:      "delta",
+	kindOp:         "op",
+	kindMeta:       "meta",
+}
@@ -0,0 +1,133 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	dir := t.TempDir()
+	fname := filepath.Join(dir, "data")
+	auditName := filepath.Join(dir, "audit")
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	table, err := New(fname, 0, WithAuditLog(auditName), withClock(clock))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := table.Put("a", []byte("v1")); err != nil {
+		t.Fatal(err.Error())
+	}
+	now = now.Add(time.Hour)
+	ctx := WithActor(context.Background(), "alice")
+	if err := table.PutCtx(ctx, "a", []byte("v2")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.DeleteCtx(ctx, "a"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.SetMetadata("version", "1"); err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Close()
+
+	// The log is appended to when the table is reopened
+	table, err = New(fname, 0, WithAuditLog(auditName), withClock(clock))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+	if err := table.Put("b", []byte("v1")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	all, err := table.AuditSince(time.Time{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(all) != 5 {
+		t.Fatalf("got %d entries, wanted %d", len(all), 5)
+	}
+
+	entries, err := table.AuditSince(now)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	hash := func(v string) string {
+		h := sha256.Sum256([]byte(v))
+		return hex.EncodeToString(h[:])
+	}
+	want := []AuditEntry{
+		{Op: "put", Key: "a", Actor: "alice", PrevHash: hash("v1")},
+		{Op: "delete", Key: "a", Actor: "alice", PrevHash: hash("v2")},
+		{Op: "meta", Key: "version"},
+		{Op: "put", Key: "b"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, wanted %d", len(entries), len(want))
+	}
+	for i, e := range entries {
+		if !e.Time.Equal(now) {
+			t.Errorf("entry %d: got time %v, wanted %v", i, e.Time, now)
+		}
+		e.Time, e.Seq = time.Time{}, 0
+		if e != want[i] {
+			t.Errorf("entry %d: got %+v, wanted %+v", i, e, want[i])
+		}
+	}
+}
+
+func TestAuditBulkLoad(t *testing.T) {
+	dir := t.TempDir()
+	table, err := New(filepath.Join(dir, "data"), 0, WithAuditLog(filepath.Join(dir, "audit")))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+
+	if err := table.Put("k1", []byte("old")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.BulkLoad(context.Background(), &pairIterator{n: 4002}, 2); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.ImportCSV(strings.NewReader("key,value\nk1,csv\n")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	entries, err := table.AuditSince(time.Time{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(entries) != 1+4002+1 {
+		t.Fatalf("got %d entries, wanted %d", len(entries), 1+4002+1)
+	}
+	hash := func(v string) string {
+		h := sha256.Sum256([]byte(v))
+		return hex.EncodeToString(h[:])
+	}
+	// k1 is written by the put, twice by the bulk load and by the import
+	var prevs []string
+	for _, e := range entries {
+		if e.Key == "k1" {
+			prevs = append(prevs, e.PrevHash)
+		}
+	}
+	want := []string{"", hash("old"), hash("v1"), hash("v4001")}
+	if !reflect.DeepEqual(prevs, want) {
+		t.Errorf("got previous hashes %v for k1, wanted %v", prevs, want)
+	}
+}
@@ -0,0 +1,72 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"errors"
+	"io"
+	"math/rand/v2"
+)
+
+// ErrBackupOffset is returned by BackupSince when the offset was not
+// returned by an earlier call for the table's current data file, such as
+// when the file has since been compacted.
+var ErrBackupOffset = errors.New("lash: backup offset is not valid for the data file")
+
+// Offsets returned by BackupSince hold the position in the data file in
+// their low bits and an identifier for the data file, which changes each
+// time it is compacted, in the bits above.
+const backupOffsetBits = 44
+
+// BackupSince writes the records appended to the table's data file since
+// offset to w and returns the offset to pass to the next call. An offset of
+// zero writes every record, giving a full backup, and other offsets must have
+// been returned by an earlier call. Each backup is written in the form of a
+// data file, so a full backup may be opened using New. The records of a
+// sequence of incremental backups, replayed in order after those of the full
+// backup on which they are based, reproduce the table's contents.
+//
+// Offsets are only valid until the data file is next compacted, including
+// when the table is next opened, after which BackupSince returns
+// ErrBackupOffset and a new full backup must be made. The table is locked
+// for reading while the records are written.
+func (t *Table) BackupSince(offset int64, w io.Writer) (int64, error) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	if t.readonly {
+		return offset, ErrReadOnly
+	}
+	if t.dbfile == nil {
+		if t.filename == "" {
+			return offset, errors.New("lash: table does not persist data")
+		}
+		return offset, errors.New("database not open")
+	}
+
+	hdr := appendHeader(nil, t.checksum, t.codec)
+	start := int64(len(hdr))
+	if offset != 0 {
+		id, pos := uint32(offset>>backupOffsetBits), offset&(1<<backupOffsetBits-1)
+		if id != t.fileID || pos < start || pos > t.size {
+			return offset, ErrBackupOffset
+		}
+		start = pos
+	}
+
+	if _, err := w.Write(hdr); err != nil {
+		return offset, err
+	}
+	if _, err := io.Copy(w, io.NewSectionReader(t.dbfile, start, t.size-start)); err != nil {
+		return offset, err
+	}
+	return int64(t.fileID)<<backupOffsetBits | t.size, nil
+}
+
+// newFileID returns an identifier for a new data file for use in the offsets
+// returned by BackupSince. Identifiers are never zero.
+func newFileID() uint32 {
+	return rand.Uint32N(1<<(63-backupOffsetBits)-1) + 1
+}
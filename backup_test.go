@@ -0,0 +1,140 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestBackupSince(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	table.PutString("a", "1")
+	table.PutString("b", "2")
+	table.PutString("c", "3")
+	table.SoftDelete("c")
+	table.Counters().Incr("n", 1)
+
+	var full bytes.Buffer
+	offset, err := table.BackupSince(0, &full)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.PutString("a", "10")
+	table.Delete("b")
+	table.Undelete("c")
+	table.PutString("d", "4")
+	table.Counters().Incr("n", 2)
+
+	var incr1 bytes.Buffer
+	offset, err = table.BackupSince(offset, &incr1)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.Delete("d")
+	table.SetMetadata("schema", "1")
+
+	var incr2 bytes.Buffer
+	offset, err = table.BackupSince(offset, &incr2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Nothing has been written since the last backup
+	var empty bytes.Buffer
+	if _, err := table.BackupSince(offset, &empty); err != nil {
+		t.Fatal(err.Error())
+	}
+	hdrlen := len(appendHeader(nil, table.checksum, nil))
+	if empty.Len() != hdrlen {
+		t.Errorf("got %d bytes, wanted %d", empty.Len(), hdrlen)
+	}
+
+	// Replaying the incremental backups after the full backup reproduces
+	// the table
+	replay := append(full.Bytes(), incr1.Bytes()[hdrlen:]...)
+	replay = append(replay, incr2.Bytes()[hdrlen:]...)
+	rf, err := os.CreateTemp("", "lash")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(rf.Name())
+	rf.Write(replay)
+	rf.Close()
+
+	restored, err := New(rf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer restored.Close()
+
+	want := map[string]string{"a": "10", "c": "3"}
+	for k, v := range want {
+		if got, _ := restored.GetString(k); got != v {
+			t.Errorf("%s: got %q, wanted %q", k, got, v)
+		}
+	}
+	for _, k := range []string{"b", "d"} {
+		if _, found := restored.Get(k); found {
+			t.Errorf("%s: got found, wanted not found", k)
+		}
+	}
+	if n, _ := restored.Counters().Get("n"); n != 3 {
+		t.Errorf("got counter %d, wanted %d", n, 3)
+	}
+	if v, _ := restored.Metadata("schema"); v != "1" {
+		t.Errorf("got metadata %q, wanted %q", v, "1")
+	}
+	if restored.Len() != table.Len() {
+		t.Errorf("got len %d, wanted %d", restored.Len(), table.Len())
+	}
+}
+
+func TestBackupSinceInvalidOffset(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	table.PutString("a", "1")
+	var buf bytes.Buffer
+	offset, err := table.BackupSince(0, &buf)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, bad := range []int64{1, offset + 1, offset ^ 1<<backupOffsetBits} {
+		if _, err := table.BackupSince(bad, &buf); err != ErrBackupOffset {
+			t.Errorf("offset %d: got error %v, wanted %v", bad, err, ErrBackupOffset)
+		}
+	}
+
+	if err := table.Compact(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := table.BackupSince(offset, &buf); err != ErrBackupOffset {
+		t.Errorf("got error %v, wanted %v", err, ErrBackupOffset)
+	}
+
+	mem, err := New("", 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := mem.BackupSince(0, &buf); err == nil {
+		t.Errorf("got no error for in-memory table, wanted one")
+	}
+}
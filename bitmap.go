@@ -0,0 +1,421 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"sort"
+)
+
+// Bitmap returns a view of the bitmap stored under key k. The bitmap need
+// not exist until a bit is set.
+func (t *Table) Bitmap(k string) Bitmap {
+	return Bitmap{t: t, k: k}
+}
+
+// Bitmap is a view of a set of uint32 values stored under a key in a Table
+// as a compressed bitmap. Bitmaps are suitable for recording membership by
+// numeric ID, such as the users for whom a feature is enabled. Setting or
+// clearing a bit appends a small record to the data file rather than
+// rewriting the whole bitmap.
+//
+// Bitmaps use the roaring format: values are divided into chunks of 65536
+// by their high 16 bits and each chunk is stored as a sorted array of the
+// low 16 bits when it is sparse or as a bitset when it is dense.
+type Bitmap struct {
+	t *Table
+	k string
+}
+
+// SetBit sets bit i of the bitmap and reports whether it was previously
+// clear.
+func (b Bitmap) SetBit(i uint32) (bool, error) {
+	_, changed, err := b.t.modify(b.k, typeBitmap, opBitSet, binary.BigEndian.AppendUint32(nil, i))
+	return changed, err
+}
+
+// ClearBit clears bit i of the bitmap and reports whether it was previously
+// set.
+func (b Bitmap) ClearBit(i uint32) (bool, error) {
+	_, changed, err := b.t.modify(b.k, typeBitmap, opBitClear, binary.BigEndian.AppendUint32(nil, i))
+	return changed, err
+}
+
+// GetBit reports whether bit i of the bitmap is set.
+func (b Bitmap) GetBit(i uint32) bool {
+	r := b.t.roaring(b.k)
+	return r != nil && r.contains(i)
+}
+
+// Cardinality returns the number of bits that are set in the bitmap.
+func (b Bitmap) Cardinality() int {
+	r := b.t.roaring(b.k)
+	if r == nil {
+		return 0
+	}
+	return r.cardinality()
+}
+
+// Bits returns the positions of the bits that are set in the bitmap in
+// ascending order.
+func (b Bitmap) Bits() []uint32 {
+	r := b.t.roaring(b.k)
+	if r == nil {
+		return nil
+	}
+	return r.values()
+}
+
+// And replaces the bitmap with its intersection with the bitmap stored
+// under key other. A missing bitmap is treated as empty.
+func (b Bitmap) And(other string) error {
+	return b.combine(other, (*roaring).and)
+}
+
+// Or replaces the bitmap with its union with the bitmap stored under key
+// other. A missing bitmap is treated as empty.
+func (b Bitmap) Or(other string) error {
+	return b.combine(other, (*roaring).or)
+}
+
+// combine replaces the bitmap with the result of applying fn to it and the
+// bitmap stored under key other. The bitmaps are read and the result
+// written atomically.
+func (b Bitmap) combine(other string, fn func(r, o *roaring)) error {
+	t := b.t
+	return t.update(b.k, func(v []byte, found bool) ([]byte, error) {
+		if !found {
+			v = []byte{typeBitmap}
+		}
+		r, err := decodeRoaring(v)
+		if err != nil {
+			return nil, err
+		}
+		ov := []byte{typeBitmap}
+		if p, ok := t.data[other]; ok && p.deleted == 0 {
+			var err error
+			ov, err = t.value(p)
+			if err != nil {
+				return nil, err
+			}
+		}
+		o, err := decodeRoaring(ov)
+		if err != nil {
+			return nil, err
+		}
+		fn(r, o)
+		return r.encode(), nil
+	})
+}
+
+// roaring returns the decoded bitmap stored under key k, or nil if there is
+// no such bitmap.
+func (t *Table) roaring(k string) *roaring {
+	v, found := t.Get(k)
+	if !found {
+		return nil
+	}
+	r, err := decodeRoaring(v)
+	if err != nil {
+		return nil
+	}
+	return r
+}
+
+// applyBitmap returns the result of setting or clearing the bit held in
+// operand in the encoded bitmap v, and reports whether v was changed.
+func applyBitmap(v []byte, op byte, operand []byte) ([]byte, bool, error) {
+	r, err := decodeRoaring(v)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(operand) != 4 {
+		return nil, false, ErrCorrupt
+	}
+	i := binary.BigEndian.Uint32(operand)
+	var changed bool
+	if op == opBitSet {
+		changed = r.add(i)
+	} else {
+		changed = r.remove(i)
+	}
+	if !changed {
+		return v, false, nil
+	}
+	return r.encode(), true, nil
+}
+
+// maxArray is the largest number of values held by a container in array
+// form. Larger containers are held as bitsets, which occupy the same space.
+const maxArray = 4096
+
+const bitsetWords = 65536 / 64
+
+// A container holds the low 16 bits of the values in a bitmap that share
+// the same high 16 bits, either as a sorted array or as a bitset.
+type container struct {
+	key    uint16
+	array  []uint16
+	bitset []uint64
+}
+
+// roaring is a decoded bitmap. Its containers are sorted by key and none
+// are empty.
+type roaring struct {
+	containers []*container
+}
+
+func (r *roaring) find(key uint16) (int, bool) {
+	i := sort.Search(len(r.containers), func(i int) bool { return r.containers[i].key >= key })
+	return i, i < len(r.containers) && r.containers[i].key == key
+}
+
+func (r *roaring) contains(x uint32) bool {
+	i, ok := r.find(uint16(x >> 16))
+	return ok && r.containers[i].contains(uint16(x))
+}
+
+func (r *roaring) add(x uint32) bool {
+	i, ok := r.find(uint16(x >> 16))
+	if !ok {
+		r.containers = append(r.containers, nil)
+		copy(r.containers[i+1:], r.containers[i:])
+		r.containers[i] = &container{key: uint16(x >> 16)}
+	}
+	return r.containers[i].add(uint16(x))
+}
+
+func (r *roaring) remove(x uint32) bool {
+	i, ok := r.find(uint16(x >> 16))
+	if !ok || !r.containers[i].remove(uint16(x)) {
+		return false
+	}
+	if r.containers[i].cardinality() == 0 {
+		r.containers = append(r.containers[:i], r.containers[i+1:]...)
+	}
+	return true
+}
+
+func (r *roaring) cardinality() int {
+	n := 0
+	for _, c := range r.containers {
+		n += c.cardinality()
+	}
+	return n
+}
+
+func (r *roaring) values() []uint32 {
+	var vals []uint32
+	for _, c := range r.containers {
+		c.each(func(lo uint16) {
+			vals = append(vals, uint32(c.key)<<16|uint32(lo))
+		})
+	}
+	return vals
+}
+
+// and replaces r with its intersection with o.
+func (r *roaring) and(o *roaring) {
+	var result []*container
+	for _, c := range r.containers {
+		j, ok := o.find(c.key)
+		if !ok {
+			continue
+		}
+		a, b := c.toBitset(), o.containers[j].toBitset()
+		for w := range a {
+			a[w] &= b[w]
+		}
+		c.array, c.bitset = nil, a
+		if c.cardinality() > 0 {
+			result = append(result, c)
+		}
+	}
+	r.containers = result
+}
+
+// or replaces r with its union with o.
+func (r *roaring) or(o *roaring) {
+	for _, oc := range o.containers {
+		i, ok := r.find(oc.key)
+		if !ok {
+			r.containers = append(r.containers, nil)
+			copy(r.containers[i+1:], r.containers[i:])
+			r.containers[i] = &container{key: oc.key, bitset: oc.toBitset()}
+			continue
+		}
+		c := r.containers[i]
+		a, b := c.toBitset(), oc.toBitset()
+		for w := range a {
+			a[w] |= b[w]
+		}
+		c.array, c.bitset = nil, a
+	}
+}
+
+func (c *container) contains(lo uint16) bool {
+	if c.bitset != nil {
+		return c.bitset[lo/64]&(1<<(lo%64)) != 0
+	}
+	i := sort.Search(len(c.array), func(i int) bool { return c.array[i] >= lo })
+	return i < len(c.array) && c.array[i] == lo
+}
+
+func (c *container) add(lo uint16) bool {
+	if c.bitset != nil {
+		if c.contains(lo) {
+			return false
+		}
+		c.bitset[lo/64] |= 1 << (lo % 64)
+		return true
+	}
+	i := sort.Search(len(c.array), func(i int) bool { return c.array[i] >= lo })
+	if i < len(c.array) && c.array[i] == lo {
+		return false
+	}
+	c.array = append(c.array, 0)
+	copy(c.array[i+1:], c.array[i:])
+	c.array[i] = lo
+	if len(c.array) > maxArray {
+		c.array, c.bitset = nil, c.toBitset()
+	}
+	return true
+}
+
+func (c *container) remove(lo uint16) bool {
+	if c.bitset != nil {
+		if !c.contains(lo) {
+			return false
+		}
+		c.bitset[lo/64] &^= 1 << (lo % 64)
+		return true
+	}
+	i := sort.Search(len(c.array), func(i int) bool { return c.array[i] >= lo })
+	if i == len(c.array) || c.array[i] != lo {
+		return false
+	}
+	c.array = append(c.array[:i], c.array[i+1:]...)
+	return true
+}
+
+func (c *container) cardinality() int {
+	if c.bitset == nil {
+		return len(c.array)
+	}
+	n := 0
+	for _, w := range c.bitset {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+func (c *container) each(fn func(lo uint16)) {
+	if c.bitset == nil {
+		for _, lo := range c.array {
+			fn(lo)
+		}
+		return
+	}
+	for i, w := range c.bitset {
+		for w != 0 {
+			fn(uint16(i*64 + bits.TrailingZeros64(w)))
+			w &= w - 1
+		}
+	}
+}
+
+// toBitset returns a copy of the container's values as a bitset.
+func (c *container) toBitset() []uint64 {
+	b := make([]uint64, bitsetWords)
+	if c.bitset != nil {
+		copy(b, c.bitset)
+		return b
+	}
+	for _, lo := range c.array {
+		b[lo/64] |= 1 << (lo % 64)
+	}
+	return b
+}
+
+// encode returns the encoded form of the bitmap, which is laid out as:
+//
+//	typeBitmap | uvarint(number of containers) | containers
+//
+// where each container is laid out as:
+//
+//	uint16(key) | uvarint(cardinality) | values
+//
+// and the values are held as a sorted array of uint16 if the cardinality is
+// at most maxArray, otherwise as a bitset of uint64 words. All integers are
+// big endian.
+func (r *roaring) encode() []byte {
+	buf := []byte{typeBitmap}
+	buf = binary.AppendUvarint(buf, uint64(len(r.containers)))
+	for _, c := range r.containers {
+		n := c.cardinality()
+		buf = binary.BigEndian.AppendUint16(buf, c.key)
+		buf = binary.AppendUvarint(buf, uint64(n))
+		if n <= maxArray {
+			c.each(func(lo uint16) {
+				buf = binary.BigEndian.AppendUint16(buf, lo)
+			})
+			continue
+		}
+		for _, w := range c.toBitset() {
+			buf = binary.BigEndian.AppendUint64(buf, w)
+		}
+	}
+	return buf
+}
+
+// decodeRoaring decodes a bitmap encoded by encode. It returns ErrWrongType
+// if v does not hold a bitmap.
+func decodeRoaring(v []byte) (*roaring, error) {
+	if len(v) == 0 || v[0] != typeBitmap {
+		return nil, ErrWrongType
+	}
+	r := &roaring{}
+	if len(v) == 1 {
+		return r, nil
+	}
+	n, b, err := consumeUvarint(v[1:])
+	if err != nil {
+		return nil, ErrWrongType
+	}
+	for i := uint64(0); i < n; i++ {
+		var card uint64
+		if len(b) < 2 {
+			return nil, ErrWrongType
+		}
+		c := &container{key: binary.BigEndian.Uint16(b)}
+		card, b, err = consumeUvarint(b[2:])
+		if err != nil || card == 0 || card > 65536 {
+			return nil, ErrWrongType
+		}
+		if card <= maxArray {
+			if uint64(len(b)) < 2*card {
+				return nil, ErrWrongType
+			}
+			c.array = make([]uint16, card)
+			for j := range c.array {
+				c.array[j] = binary.BigEndian.Uint16(b[2*j:])
+			}
+			b = b[2*card:]
+		} else {
+			if len(b) < 8*bitsetWords {
+				return nil, ErrWrongType
+			}
+			c.bitset = make([]uint64, bitsetWords)
+			for j := range c.bitset {
+				c.bitset[j] = binary.BigEndian.Uint64(b[8*j:])
+			}
+			b = b[8*bitsetWords:]
+		}
+		r.containers = append(r.containers, c)
+	}
+	return r, nil
+}
@@ -0,0 +1,89 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestBitmap(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	b := table.Bitmap("flags")
+	for _, i := range []uint32{7, 1 << 20, 3, 70000, 7} {
+		if _, err := b.SetBit(i); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if set, _ := b.SetBit(3); set {
+		t.Errorf("got set for bit already set, wanted not set")
+	}
+	if cleared, _ := b.ClearBit(70000); !cleared {
+		t.Errorf("got not cleared, wanted cleared")
+	}
+	if !b.GetBit(1<<20) || b.GetBit(70000) {
+		t.Errorf("got bits %v and %v, wanted true and false", b.GetBit(1<<20), b.GetBit(70000))
+	}
+	if got, want := b.Bits(), []uint32{3, 7, 1 << 20}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, wanted %v", got, want)
+	}
+
+	// A dense container is held as a bitset
+	d := table.Bitmap("dense")
+	for i := uint32(0); i < 10000; i += 2 {
+		d.SetBit(i)
+	}
+	if d.Cardinality() != 5000 {
+		t.Errorf("got cardinality %d, wanted %d", d.Cardinality(), 5000)
+	}
+
+	o := table.Bitmap("other")
+	o.SetBit(7)
+	o.SetBit(8)
+	o.SetBit(1 << 20)
+	if err := b.And("other"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if got, want := b.Bits(), []uint32{7, 1 << 20}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v after and, wanted %v", got, want)
+	}
+	if err := b.Or("dense"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if b.Cardinality() != 5002 {
+		t.Errorf("got cardinality %d after or, wanted %d", b.Cardinality(), 5002)
+	}
+	if err := b.And("missing"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if b.Cardinality() != 0 {
+		t.Errorf("got cardinality %d after and with missing, wanted %d", b.Cardinality(), 0)
+	}
+
+	table.PutString("name", "value")
+	if _, err := table.Bitmap("name").SetBit(1); err != ErrWrongType {
+		t.Errorf("got error %v, wanted %v", err, ErrWrongType)
+	}
+	table.Close()
+
+	table2, err := New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table2.Close()
+	if got := table2.Bitmap("dense").Cardinality(); got != 5000 {
+		t.Errorf("got cardinality %d after reopen, wanted %d", got, 5000)
+	}
+	if got, want := table2.Bitmap("other").Bits(), []uint32{7, 8, 1 << 20}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v after reopen, wanted %v", got, want)
+	}
+}
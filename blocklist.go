@@ -0,0 +1,173 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import "sort"
+
+// blockLen is the maximum number of elements held in a block of a
+// blockList. Blocks are split in two once they exceed it.
+const blockLen = 512
+
+// A blockList is a sequence of elements held in a series of blocks, so that
+// an element can be inserted or removed at any position by moving only the
+// elements of one block rather than every element that follows it. A
+// position in the list is given by the index of a block and the index of an
+// element within that block. Positions are invalidated by any change to the
+// list.
+type blockList[E any] struct {
+	blocks [][]E // non-empty blocks of at most blockLen elements, in order
+	n      int   // number of elements
+}
+
+// len returns the number of elements in the list.
+func (l *blockList[E]) len() int {
+	return l.n
+}
+
+// end returns the position that follows the last element of the list.
+func (l *blockList[E]) end() (int, int) {
+	return len(l.blocks), 0
+}
+
+// search returns the position of the first element for which f returns
+// true, or the end of the list if there is none. Like sort.Search, f must
+// return false for some prefix of the list and true for the remainder.
+func (l *blockList[E]) search(f func(e E) bool) (int, int) {
+	b := sort.Search(len(l.blocks), func(i int) bool {
+		blk := l.blocks[i]
+		return f(blk[len(blk)-1])
+	})
+	if b == len(l.blocks) {
+		return l.end()
+	}
+	blk := l.blocks[b]
+	return b, sort.Search(len(blk), func(i int) bool { return f(blk[i]) })
+}
+
+// get returns the element at position (b, i) and reports whether there is
+// one.
+func (l *blockList[E]) get(b, i int) (E, bool) {
+	if b >= len(l.blocks) {
+		var zero E
+		return zero, false
+	}
+	return l.blocks[b][i], true
+}
+
+// locate returns the position of the element at index n of the list, or
+// the end of the list if there is none.
+func (l *blockList[E]) locate(n int) (int, int) {
+	if n < 0 || n >= l.n {
+		return l.end()
+	}
+	for b, blk := range l.blocks {
+		if n < len(blk) {
+			return b, n
+		}
+		n -= len(blk)
+	}
+	return l.end()
+}
+
+// rank returns the index in the list of the element at position (b, i).
+func (l *blockList[E]) rank(b, i int) int {
+	for _, blk := range l.blocks[:b] {
+		i += len(blk)
+	}
+	return i
+}
+
+// insert inserts e at position (b, i), moving the element held there, if
+// any, and those following it along by one.
+func (l *blockList[E]) insert(b, i int, e E) {
+	if b == len(l.blocks) {
+		// Elements inserted at the end are appended to the last block
+		if b == 0 {
+			l.blocks = append(l.blocks, nil)
+		}
+		b = len(l.blocks) - 1
+		i = len(l.blocks[b])
+	}
+	blk := append(l.blocks[b], e)
+	copy(blk[i+1:], blk[i:])
+	blk[i] = e
+	l.blocks[b] = blk
+	l.n++
+	if len(blk) > blockLen {
+		half := len(blk) / 2
+		tail := append([]E(nil), blk[half:]...)
+		l.blocks[b] = blk[:half:half]
+		l.blocks = append(l.blocks, nil)
+		copy(l.blocks[b+2:], l.blocks[b+1:])
+		l.blocks[b+1] = tail
+	}
+}
+
+// remove removes and returns the element at position (b, i).
+func (l *blockList[E]) remove(b, i int) E {
+	blk := l.blocks[b]
+	e := blk[i]
+	blk = append(blk[:i], blk[i+1:]...)
+	var zero E
+	blk[:len(blk)+1][len(blk)] = zero
+	if len(blk) == 0 {
+		l.blocks = append(l.blocks[:b], l.blocks[b+1:]...)
+	} else {
+		l.blocks[b] = blk
+	}
+	l.n--
+	return e
+}
+
+// scan calls fn with each element from position (b, i) onwards, in order,
+// until fn returns false.
+func (l *blockList[E]) scan(b, i int, fn func(e E) bool) {
+	for ; b < len(l.blocks); b, i = b+1, 0 {
+		for _, e := range l.blocks[b][i:] {
+			if !fn(e) {
+				return
+			}
+		}
+	}
+}
+
+// scanBack calls fn with each element before position (b, i), in reverse
+// order, until fn returns false.
+func (l *blockList[E]) scanBack(b, i int, fn func(e E) bool) {
+	if b == len(l.blocks) {
+		if b == 0 {
+			return
+		}
+		b, i = b-1, len(l.blocks[b-1])
+	}
+	for ; b >= 0; b-- {
+		for j := i - 1; j >= 0; j-- {
+			if !fn(l.blocks[b][j]) {
+				return
+			}
+		}
+		if b > 0 {
+			i = len(l.blocks[b-1])
+		}
+	}
+}
+
+// set replaces the elements of the list with elems, which the list then
+// owns. The blocks are left half full so that later insertions do not
+// immediately split them.
+func (l *blockList[E]) set(elems []E) {
+	l.blocks, l.n = nil, len(elems)
+	for len(elems) > 0 {
+		n := min(len(elems), blockLen/2)
+		l.blocks = append(l.blocks, elems[:n:n])
+		elems = elems[n:]
+	}
+}
+
+// clear removes all the elements of the list.
+func (l *blockList[E]) clear() {
+	l.blocks, l.n = nil, 0
+}
@@ -0,0 +1,74 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBlockList(t *testing.T) {
+	var l blockList[int]
+	var want []int
+	// Insert enough even numbers out of order to split blocks
+	for i := 0; i < 5*blockLen; i++ {
+		n := (i * 7919) % (5 * blockLen) * 2
+		b, j := l.search(func(e int) bool { return e >= n })
+		l.insert(b, j, n)
+		want = append(want, n)
+	}
+	slices.Sort(want)
+
+	check := func(when string) {
+		var got []int
+		l.scan(0, 0, func(e int) bool {
+			got = append(got, e)
+			return true
+		})
+		if !slices.Equal(got, want) || l.len() != len(want) {
+			t.Fatalf("%s: got %d elements out of order, wanted %d in order", when, l.len(), len(want))
+		}
+		for _, n := range []int{len(want), len(want) / 2} {
+			var back []int
+			b, j := l.locate(n)
+			l.scanBack(b, j, func(e int) bool {
+				back = append(back, e)
+				return true
+			})
+			slices.Reverse(back)
+			if !slices.Equal(back, want[:n]) {
+				t.Fatalf("%s: got %d elements scanning back from %d, wanted %d in reverse order", when, len(back), n, n)
+			}
+		}
+		for i, e := range want {
+			b, j := l.locate(i)
+			if got, _ := l.get(b, j); got != e {
+				t.Fatalf("%s: got %d at index %d, wanted %d", when, got, i, e)
+			}
+			if r := l.rank(b, j); r != i {
+				t.Fatalf("%s: got rank %d, wanted %d", when, r, i)
+			}
+		}
+	}
+	check("after insert")
+
+	for i := 0; i < len(want); {
+		if want[i]%6 != 0 {
+			i++
+			continue
+		}
+		b, j := l.search(func(e int) bool { return e >= want[i] })
+		if got := l.remove(b, j); got != want[i] {
+			t.Fatalf("got %d removed, wanted %d", got, want[i])
+		}
+		want = slices.Delete(want, i, i+1)
+	}
+	check("after remove")
+
+	if _, ok := l.get(l.locate(len(want))); ok {
+		t.Errorf("got element beyond end of list, wanted none")
+	}
+}
@@ -0,0 +1,310 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// An Iterator supplies a sequence of key/value pairs.
+type Iterator interface {
+	// Next advances the iterator to the next pair, returning false when
+	// there are no more pairs or an error occurred.
+	Next() bool
+
+	// Key returns the key of the current pair.
+	Key() string
+
+	// Value returns the value of the current pair.
+	Value() []byte
+
+	// Err returns any error encountered by the iterator.
+	Err() error
+}
+
+const (
+	bulkChunkLen   = 1024    // number of pairs serialised as a unit by a bulk load worker
+	bulkBufferSize = 4 << 20 // size of the write buffer used for bulk loads
+)
+
+type bulkChunk struct {
+	index int
+	keys  []string
+	items []item
+	offs  []int64 // offsets of each record within buf
+	buf   []byte
+}
+
+// BulkLoad stores every key/value pair supplied by it in the table. It is
+// intended for importing large datasets and is much faster than calling Put
+// for each pair. Records are serialised in parallel by the given number of
+// workers, or by one worker per CPU if workers is less than one, written
+// through a large buffer and committed to persistent storage by a single
+// fsync. Pairs are applied in the order supplied by it so later values for
+// a key replace earlier ones.
+//
+// The table is locked for the duration of the load, which is refused in the
+// same cases as Put, such as once the disk has become full or while the
+// table is reserved by SingleWriter. Each pair is recorded in the table's
+// audit log, if it has one, before its record is written, and is mirrored to
+// the table's shadow, if it has one, once the load has been committed. If
+// the load fails or ctx is cancelled then the data file is truncated to
+// remove any records that were written and the table is left unchanged,
+// although entries already recorded in the audit log remain, as they do for
+// a failed Put. The iterator is consumed by a goroutine other than the
+// caller's.
+func (t *Table) BulkLoad(ctx context.Context, it Iterator, workers int) error {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.readonly {
+		return ErrReadOnly
+	}
+	if t.dbfile == nil && t.filename != "" {
+		return errors.New("database not open")
+	}
+	if t.full {
+		return ErrDiskFull
+	}
+	if t.stalled {
+		return ErrFsyncTimeout
+	}
+	if t.writer != nil && !t.writing {
+		return ErrSingleWriter
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	toEncode := make(chan *bulkChunk, workers)
+	encoded := make(chan *bulkChunk, workers)
+
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(toEncode)
+		readErr <- t.readBulk(ctx, it, toEncode)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range toEncode {
+				c.offs = make([]int64, len(c.keys))
+				for i, k := range c.keys {
+					c.offs[i] = int64(len(c.buf))
+					c.buf = appendRecord(c.buf, c.items[i].record(k), t.checksum, t.codec)
+				}
+				select {
+				case encoded <- c:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(encoded)
+	}()
+
+	start := t.size
+	var w *bufio.Writer
+	if t.dbfile != nil {
+		var err error
+		start, err = t.dbfile.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+		w = bufio.NewWriterSize(t.dbfile, bulkBufferSize)
+	}
+
+	// Chunks may be encoded out of order so hold them until they can be
+	// written in sequence.
+	var err error
+	var chunks []*bulkChunk
+	latest := make(map[string][]byte) // values audited so far, by key
+	pending := make(map[int]*bulkChunk)
+	offset := start
+	for c := range encoded {
+		if err != nil {
+			continue
+		}
+		pending[c.index] = c
+		for {
+			c, ok := pending[len(chunks)]
+			if !ok {
+				break
+			}
+			delete(pending, c.index)
+			if err = t.auditBulk(c, latest); err != nil {
+				cancel()
+				break
+			}
+			if w != nil {
+				t.written += int64(len(c.buf))
+				if _, err = w.Write(c.buf); err != nil {
+					cancel()
+					break
+				}
+			}
+			for i := range c.items {
+				c.items[i].pos = offset + c.offs[i]
+				if w != nil {
+					end := int64(len(c.buf))
+					if i+1 < len(c.offs) {
+						end = c.offs[i+1]
+					}
+					c.items[i].size = end - c.offs[i]
+				}
+			}
+			offset += int64(len(c.buf))
+			c.buf = nil
+			chunks = append(chunks, c)
+		}
+	}
+
+	// Wait for the reader even on failure since it uses the table.
+	if rerr := <-readErr; err == nil {
+		err = rerr
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil && w != nil {
+		err = w.Flush()
+		if err == nil {
+			err = t.fsync()
+		}
+	}
+	if err != nil {
+		if t.dbfile != nil {
+			t.dbfile.Truncate(start)
+		}
+		if isDiskFull(err) {
+			return t.diskFull(start)
+		}
+		return err
+	}
+	if t.dbfile != nil {
+		t.size = offset
+	}
+
+	for _, c := range chunks {
+		for i, k := range c.keys {
+			old, exists := t.data[k]
+			if err := t.replace(k, c.items[i], old, exists); err != nil {
+				return err
+			}
+			t.mirror(c.items[i].record(k))
+		}
+	}
+	return nil
+}
+
+// auditBulk records the pairs in the chunk c in the table's audit log, if it
+// has one. latest holds the values recorded earlier in the same load, which
+// have not yet been stored in the table, and is updated with those from c.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) auditBulk(c *bulkChunk, latest map[string][]byte) error {
+	if t.audit == nil {
+		return nil
+	}
+	for i, k := range c.keys {
+		prev, found := latest[k]
+		if p, exists := t.data[k]; !found && exists {
+			var err error
+			if prev, err = t.value(p); err != nil {
+				return err
+			}
+			found = true
+		}
+		if err := t.writeAudit(c.items[i].record(k), prev, found); err != nil {
+			return err
+		}
+		latest[k] = c.items[i].val
+	}
+	return nil
+}
+
+// readBulk reads pairs from it, prepares the items that will store them and
+// sends them in chunks to be encoded.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) readBulk(ctx context.Context, it Iterator, chunks chan<- *bulkChunk) error {
+	latest := make(map[string]item)
+	c := &bulkChunk{}
+	send := func() bool {
+		select {
+		case chunks <- c:
+			c = &bulkChunk{index: c.index + 1}
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for it.Next() {
+		k := it.Key()
+		if err := t.checkPut(k, it.Value()); err != nil {
+			return err
+		}
+		old, exists := latest[k]
+		if !exists {
+			old, exists = t.data[k]
+		}
+		add := t.newItem(it.Value(), old, exists)
+		latest[k] = add
+		c.keys = append(c.keys, k)
+		c.items = append(c.items, add)
+		if len(c.keys) == bulkChunkLen && !send() {
+			return ctx.Err()
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	if len(c.keys) > 0 && !send() {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// BeginBulk suspends the fsync that normally follows each write so that a
+// large number of writes can be made quickly. Until the matching call to
+// EndBulk, writes are acknowledged once they reach the operating system and a
+// crash may lose any of them, in any order. Calls to BeginBulk may be nested;
+// fsyncs resume when every call has been matched by a call to EndBulk.
+func (t *Table) BeginBulk() {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.bulk++
+}
+
+// EndBulk ends a period of bulk writing started by BeginBulk. When the
+// outermost period ends, every write made during it is committed to
+// persistent storage by a single fsync, whatever the table's sync policy,
+// and any error encountered while doing so is returned.
+func (t *Table) EndBulk() error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.bulk == 0 {
+		return errors.New("not in bulk mode")
+	}
+	t.bulk--
+	if t.readonly || t.bulk > 0 {
+		return nil
+	}
+	return t.fsync()
+}
@@ -0,0 +1,233 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+type pairIterator struct {
+	n, i int
+	err  error
+}
+
+func (p *pairIterator) Next() bool {
+	if p.i >= p.n {
+		return false
+	}
+	p.i++
+	return true
+}
+
+func (p *pairIterator) Key() string   { return fmt.Sprintf("k%d", (p.i-1)%4000) }
+func (p *pairIterator) Value() []byte { return []byte(fmt.Sprintf("v%d", p.i-1)) }
+func (p *pairIterator) Err() error {
+	if p.i >= p.n {
+		return p.err
+	}
+	return nil
+}
+
+func TestBulkLoad(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	table.Put("k1", []byte("old"))
+	if err := table.BulkLoad(context.Background(), &pairIterator{n: 5000}, 4); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if table.Len() != 4000 {
+		t.Errorf("got len %d, wanted %d", table.Len(), 4000)
+	}
+	v, _ := table.Get("k1")
+	if string(v) != "v4001" {
+		t.Errorf("got %q, wanted %q", v, "v4001")
+	}
+	m, _ := table.GetMeta("k1")
+	if m.Writes != 3 {
+		t.Errorf("got writes %d, wanted %d", m.Writes, 3)
+	}
+	table.Close()
+
+	table2, err := New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table2.Close()
+	if table2.Len() != 4000 {
+		t.Errorf("got len %d, wanted %d", table2.Len(), 4000)
+	}
+	v, _ = table2.Get("k1")
+	if string(v) != "v4001" {
+		t.Errorf("got %q, wanted %q", v, "v4001")
+	}
+	v, _ = table2.Get("k3999")
+	if string(v) != "v3999" {
+		t.Errorf("got %q, wanted %q", v, "v3999")
+	}
+}
+
+func TestBulkLoadError(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	table.Put("a", []byte("b"))
+	before := table.Stats().FileBytes
+
+	errIter := errors.New("iterator failed")
+	err = table.BulkLoad(context.Background(), &pairIterator{n: 3000, err: errIter}, 2)
+	if err != errIter {
+		t.Errorf("got error %v, wanted %v", err, errIter)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = table.BulkLoad(ctx, &pairIterator{n: 3000}, 2)
+	if err != context.Canceled {
+		t.Errorf("got error %v, wanted %v", err, context.Canceled)
+	}
+
+	if table.Len() != 1 {
+		t.Errorf("got len %d, wanted %d", table.Len(), 1)
+	}
+	fi, err := os.Stat(tf.Name())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if fi.Size() != before {
+		t.Errorf("got file size %d, wanted %d", fi.Size(), before)
+	}
+}
+
+func TestBulkLoadRefused(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	w, err := table.SingleWriter()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.BulkLoad(context.Background(), &pairIterator{n: 10}, 1); err != ErrSingleWriter {
+		t.Errorf("got error %v, wanted %v", err, ErrSingleWriter)
+	}
+	w.Release()
+
+	for _, tc := range []struct {
+		set  func()
+		want error
+	}{
+		{set: func() { table.full = true }, want: ErrDiskFull},
+		{set: func() { table.stalled = true }, want: ErrFsyncTimeout},
+	} {
+		table.mtx.Lock()
+		table.full, table.stalled = false, false
+		tc.set()
+		table.mtx.Unlock()
+		if err := table.BulkLoad(context.Background(), &pairIterator{n: 10}, 1); err != tc.want {
+			t.Errorf("got error %v, wanted %v", err, tc.want)
+		}
+	}
+	table.mtx.Lock()
+	table.full, table.stalled = false, false
+	table.mtx.Unlock()
+	if table.Len() != 0 {
+		t.Errorf("got len %d, wanted %d", table.Len(), 0)
+	}
+}
+
+func TestBeginBulk(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	table.BeginBulk()
+	table.BeginBulk()
+	for i := 0; i < 100; i++ {
+		if err := table.Put(fmt.Sprintf("k%d", i), []byte("val")); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := table.EndBulk(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.EndBulk(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.EndBulk(); err == nil {
+		t.Errorf("got nil error for unmatched EndBulk, wanted error")
+	}
+
+	// Closing the table ends bulk mode
+	table.BeginBulk()
+	table.Put("last", []byte("val"))
+	if err := table.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table2, err := New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table2.Close()
+	if table2.Len() != 101 {
+		t.Errorf("got len %d, wanted %d", table2.Len(), 101)
+	}
+}
+
+func TestEndBulkSyncInterval(t *testing.T) {
+	tf, err := os.CreateTemp("", "lash")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tf.Close()
+	defer os.Remove(tf.Name())
+
+	table, err := New(tf.Name(), 50, WithSyncInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+
+	opened := table.DurableOffset()
+	table.BeginBulk()
+	table.BeginBulk()
+	for i := 0; i < 10; i++ {
+		if err := table.Put(fmt.Sprintf("k%d", i), []byte("val")); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := table.EndBulk(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if got := table.DurableOffset(); got != opened {
+		t.Errorf("got durable offset %d inside bulk mode, wanted %d", got, opened)
+	}
+	if err := table.EndBulk(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if got, want := table.DurableOffset(), table.Stats().FileBytes; got != want {
+		t.Errorf("got durable offset %d, wanted %d", got, want)
+	}
+}
@@ -0,0 +1,151 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// PutCAS stores the value v in the table's content addressed store, such as
+// an artifact or blob cache, and returns its hash, the hex encoded SHA-256
+// hash of v, by which it may be retrieved using GetCAS. Identical values
+// are stored only once: if v is already stored then nothing is written,
+// unless all its references have been released, in which case it is
+// protected from CASSweep once more. Values are held under keys formed by
+// CompositeKey from "cas" and their hash.
+func (t *Table) PutCAS(v []byte) (string, error) {
+	sum := sha256.Sum256(v)
+	hash := hex.EncodeToString(sum[:])
+	k := casKey(hash)
+
+	t.putLimit.wait()
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if cur, exists := t.data[k]; exists && cur.deleted == 0 {
+		if refs, found, err := t.casRefs(hash); err != nil || !found || refs > 0 {
+			return hash, err
+		}
+		if err := t.delete(casRefsKey(hash), 0); err != nil {
+			return "", err
+		}
+		return hash, nil
+	}
+	if err := t.put(k, v, 0); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// GetCAS retrieves the value with the given hash from the table's content
+// addressed store and reports whether it was found.
+func (t *Table) GetCAS(hash string) ([]byte, bool) {
+	return t.Get(casKey(hash))
+}
+
+// CASRetain adds a reference to the value with the given hash in the
+// table's content addressed store and returns the number of references it
+// now has. It returns ErrNotFound if there is no such value. References
+// are counted in counters held under keys formed by CompositeKey from
+// "cas", the hash and "refs".
+func (t *Table) CASRetain(hash string) (int64, error) {
+	t.putLimit.wait()
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if cur, exists := t.data[casKey(hash)]; !exists || cur.deleted != 0 {
+		return 0, ErrNotFound
+	}
+	return t.incrCounter(casRefsKey(hash), 1)
+}
+
+// CASRelease removes a reference to the value with the given hash in the
+// table's content addressed store, added by CASRetain, and returns the
+// number of references that remain. A value whose references have all
+// been released is removed by the next call to CASSweep. It returns
+// ErrNotFound if there is no such value and an error if the value has no
+// references to release.
+func (t *Table) CASRelease(hash string) (int64, error) {
+	t.putLimit.wait()
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if cur, exists := t.data[casKey(hash)]; !exists || cur.deleted != 0 {
+		return 0, ErrNotFound
+	}
+	refs, _, err := t.casRefs(hash)
+	if err != nil {
+		return 0, err
+	}
+	if refs <= 0 {
+		return 0, errors.New("lash: content addressed value has no references")
+	}
+	return t.incrCounter(casRefsKey(hash), -1)
+}
+
+// CASSweep deletes the values in the table's content addressed store whose
+// references have all been released, returning the number deleted. Values
+// that have never been retained are kept. The space the values occupy in
+// the data file is reclaimed when it is next compacted.
+func (t *Table) CASSweep() (int, error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	prefix := CompositeKey([]byte("cas"))
+	var unreferenced []string
+	for k, p := range t.data {
+		if p.deleted != 0 || !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		parts, err := SplitCompositeKey(k)
+		if err != nil || len(parts) != 3 || string(parts[2]) != "refs" {
+			continue
+		}
+		hash := string(parts[1])
+		if refs, _, err := t.casRefs(hash); err != nil {
+			return 0, err
+		} else if refs == 0 {
+			unreferenced = append(unreferenced, hash)
+		}
+	}
+
+	for i, hash := range unreferenced {
+		if err := t.delete(casKey(hash), Deferred); err != nil {
+			return i, err
+		}
+		if err := t.delete(casRefsKey(hash), Deferred); err != nil {
+			return i, err
+		}
+	}
+	return len(unreferenced), t.syncFor(0)
+}
+
+// casRefs returns the number of references to the content addressed value
+// with the given hash and reports whether it has ever been retained.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) casRefs(hash string) (int64, bool, error) {
+	cur, exists := t.data[casRefsKey(hash)]
+	if !exists || cur.deleted != 0 {
+		return 0, false, nil
+	}
+	v, err := t.value(cur)
+	if err != nil {
+		return 0, false, err
+	}
+	n, err := counterValue(v)
+	if err != nil {
+		return 0, false, err
+	}
+	return n, true, nil
+}
+
+func casKey(hash string) string {
+	return CompositeKey([]byte("cas"), []byte(hash))
+}
+
+func casRefsKey(hash string) string {
+	return CompositeKey([]byte("cas"), []byte(hash), []byte("refs"))
+}
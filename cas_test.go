@@ -0,0 +1,125 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPutCAS(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	hash, err := table.PutCAS([]byte("hello"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	want := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if hash != want {
+		t.Errorf("got hash %q, wanted %q", hash, want)
+	}
+	v, found := table.GetCAS(hash)
+	if !found || string(v) != "hello" {
+		t.Errorf("got %q, %v, wanted %q", v, found, "hello")
+	}
+
+	size := table.Stats().BytesWritten
+	again, err := table.PutCAS([]byte("hello"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if again != hash {
+		t.Errorf("got hash %q for identical value, wanted %q", again, hash)
+	}
+	if got := table.Stats().BytesWritten; got != size {
+		t.Errorf("got %d bytes written after storing identical value, wanted %d", got, size)
+	}
+	if table.Len() != 1 {
+		t.Errorf("got length %d, wanted %d", table.Len(), 1)
+	}
+
+	if _, found := table.GetCAS(want[:10]); found {
+		t.Errorf("got value for unknown hash, wanted none")
+	}
+}
+
+func TestCASSweep(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	kept, err := table.PutCAS([]byte("kept"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	swept, err := table.PutCAS([]byte("swept"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	unretained, err := table.PutCAS([]byte("unretained"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, hash := range []string{kept, kept, swept} {
+		if _, err := table.CASRetain(hash); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if n, err := table.CASRelease(kept); err != nil || n != 1 {
+		t.Errorf("got %d, %v, wanted 1 reference remaining", n, err)
+	}
+	if n, err := table.CASRelease(swept); err != nil || n != 0 {
+		t.Errorf("got %d, %v, wanted 0 references remaining", n, err)
+	}
+	if _, err := table.CASRelease(swept); err == nil {
+		t.Errorf("got no error releasing a value without references, wanted error")
+	}
+	if _, err := table.CASRetain("unknown"); err != ErrNotFound {
+		t.Errorf("got error %v, wanted %v", err, ErrNotFound)
+	}
+
+	n, err := table.CASSweep()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if n != 1 {
+		t.Errorf("got %d values swept, wanted %d", n, 1)
+	}
+	if _, found := table.GetCAS(swept); found {
+		t.Errorf("got unreferenced value after sweep, wanted none")
+	}
+	for _, hash := range []string{kept, unretained} {
+		if _, found := table.GetCAS(hash); !found {
+			t.Errorf("got no value for %s after sweep, wanted value", hash)
+		}
+	}
+	if table.Len() != 3 {
+		t.Errorf("got length %d, wanted %d", table.Len(), 3)
+	}
+
+	// Storing a released value again protects it from the sweep
+	if _, err := table.CASRelease(kept); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := table.PutCAS([]byte("kept")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if n, _ := table.CASSweep(); n != 0 {
+		t.Errorf("got %d values swept, wanted %d", n, 0)
+	}
+	if _, found := table.GetCAS(kept); !found {
+		t.Errorf("got no value after storing it again, wanted value")
+	}
+}
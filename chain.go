@@ -0,0 +1,64 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+// Chain returns a read-only view over tables that consults them in order,
+// such as a small hot table in front of a large table on disk. The view
+// shares its data with the tables.
+func Chain(tables ...*Table) Chained {
+	return Chained{tables: tables}
+}
+
+// Chained is a view over a sequence of tables in which each key is looked
+// up in each table in turn until it is found.
+type Chained struct {
+	tables  []*Table
+	promote bool
+}
+
+// Promoting returns a copy of the view that copies a value found in any
+// table other than the first into the first table, so that later lookups
+// of the same key are served by it. A failure to copy the value is logged
+// by the first table rather than returned.
+func (c Chained) Promoting() Chained {
+	c.promote = true
+	return c
+}
+
+// Get retrieves the value stored under key k in the first table that holds
+// it and returns it along with a boolean that indicates whether the value
+// was found in any of the tables.
+func (c Chained) Get(k string) ([]byte, bool) {
+	for i, t := range c.tables {
+		v, found := t.Get(k)
+		if !found {
+			continue
+		}
+		if c.promote && i > 0 {
+			if err := c.tables[0].Put(k, v); err != nil {
+				c.tables[0].logger.Error("lash: failed to promote value", "key", c.tables[0].redactKey(k), "error", err)
+			}
+		}
+		return v, true
+	}
+	return nil, false
+}
+
+// Has reports whether a value is stored under key k in any of the tables.
+func (c Chained) Has(k string) bool {
+	for _, t := range c.tables {
+		if _, found := t.Get(k); found {
+			return true
+		}
+	}
+	return false
+}
+
+// Tables returns the tables underlying the view, in the order in which
+// they are consulted.
+func (c Chained) Tables() []*Table {
+	return append([]*Table(nil), c.tables...)
+}
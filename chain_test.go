@@ -0,0 +1,48 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+	hot, err := New("", 10)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cold, err := New("", 10)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	hot.Put("a", []byte("hot a"))
+	cold.Put("a", []byte("cold a"))
+	cold.Put("b", []byte("cold b"))
+
+	c := Chain(hot, cold)
+	for k, want := range map[string]string{"a": "hot a", "b": "cold b"} {
+		v, found := c.Get(k)
+		if !found || string(v) != want {
+			t.Errorf("got %q, wanted %q", v, want)
+		}
+	}
+	if _, found := c.Get("c"); found {
+		t.Errorf("got value for missing key, wanted none")
+	}
+	if !c.Has("b") || c.Has("c") {
+		t.Errorf("got wrong result from Has")
+	}
+	if _, found := hot.Get("b"); found {
+		t.Errorf("got value promoted without promotion, wanted none")
+	}
+
+	if v, _ := c.Promoting().Get("b"); string(v) != "cold b" {
+		t.Errorf("got %q, wanted %q", v, "cold b")
+	}
+	if v, _ := hot.Get("b"); string(v) != "cold b" {
+		t.Errorf("got %q promoted, wanted %q", v, "cold b")
+	}
+}
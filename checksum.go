@@ -0,0 +1,53 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"hash/crc32"
+
+	"github.com/iand/lash/format"
+)
+
+// ErrChecksum is returned when a record in the data file fails checksum
+// validation. Like ErrCorrupt it may be wrapped, so should be matched using
+// errors.Is.
+var ErrChecksum = format.ErrChecksum
+
+// A Checksum identifies the algorithm used to verify the integrity of each
+// record in a table's data file. The algorithm is recorded in the header of
+// the data file.
+type Checksum = format.Checksum
+
+const (
+	// ChecksumNone disables checksums. Records are written without a
+	// checksum and corruption may go undetected.
+	ChecksumNone = format.ChecksumNone
+
+	// ChecksumCRC32C uses CRC-32 with the Castagnoli polynomial, which is
+	// hardware accelerated on most platforms. It adds four bytes to each
+	// record and is the default.
+	ChecksumCRC32C = format.ChecksumCRC32C
+
+	// ChecksumXXHash64 uses the 64-bit xxHash algorithm. It adds eight bytes
+	// to each record.
+	ChecksumXXHash64 = format.ChecksumXXHash64
+)
+
+const defaultChecksum = ChecksumCRC32C
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// WithChecksum sets the algorithm used to checksum records in the table's
+// data file. If an existing data file uses a different algorithm then it is
+// rewritten with the new algorithm when the table is opened. If WithChecksum
+// is not used then an existing data file retains its algorithm and new data
+// files use ChecksumCRC32C.
+func WithChecksum(c Checksum) Option {
+	return func(t *Table) {
+		t.checksum = c
+		t.checksumSet = true
+	}
+}
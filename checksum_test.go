@@ -0,0 +1,242 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+)
+
+func TestChecksumAlgorithms(t *testing.T) {
+	for _, c := range []Checksum{ChecksumNone, ChecksumCRC32C, ChecksumXXHash64} {
+		t.Run(c.String(), func(t *testing.T) {
+			tf, err := os.CreateTemp("", "lash")
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			tf.Close()
+			defer os.Remove(tf.Name())
+
+			table, err := New(tf.Name(), 50, WithChecksum(c))
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			err = table.Put("a", []byte("val"))
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			err = table.Put("a", []byte("val2"))
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			table.Close()
+
+			// The algorithm is read from the header when not specified
+			table, err = New(tf.Name(), 50)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			defer table.Close()
+
+			if table.checksum != c {
+				t.Errorf("got checksum %s, wanted %s", table.checksum, c)
+			}
+			v, found := table.Get("a")
+			if !found {
+				t.Fatalf("got not found, wanted found")
+			}
+			if string(v) != "val2" {
+				t.Errorf("got %q, wanted %q", v, "val2")
+			}
+		})
+	}
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	err = table.Put("a", []byte("value"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = table.Put("b", []byte("value"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Close()
+
+	data, err := os.ReadFile(tf.Name())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	i := bytes.Index(data, []byte("value"))
+	data[i] = 'V'
+	err = os.WriteFile(tf.Name(), data, 0o666)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	_, err = New(tf.Name(), 50)
+	if !errors.Is(err, ErrChecksum) {
+		t.Errorf("got error %v, wanted %v", err, ErrChecksum)
+	}
+}
+
+func TestTruncateCorruptTail(t *testing.T) {
+	testCases := []struct {
+		name    string
+		corrupt func(data []byte) []byte
+	}{
+		{
+			name: "torn write",
+			corrupt: func(data []byte) []byte {
+				return data[:len(data)-3]
+			},
+		},
+		{
+			name: "checksum mismatch",
+			corrupt: func(data []byte) []byte {
+				i := bytes.LastIndex(data, []byte("value"))
+				data[i] = 'V'
+				return data
+			},
+		},
+		{
+			name: "zero filled",
+			corrupt: func(data []byte) []byte {
+				return append(data, make([]byte, 64)...)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			table, tf, err := makeTable(50)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			defer os.Remove(tf.Name())
+
+			err = table.Put("a", []byte("value"))
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			err = table.Put("b", []byte("value"))
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			table.Close()
+
+			data, err := os.ReadFile(tf.Name())
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			err = os.WriteFile(tf.Name(), tc.corrupt(data), 0o666)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+
+			var logbuf bytes.Buffer
+			table, err = New(tf.Name(), 50, WithLogger(slog.New(slog.NewTextHandler(&logbuf, nil))))
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			defer table.Close()
+
+			if _, found := table.Get("a"); !found {
+				t.Errorf("got not found for a, wanted found")
+			}
+			if tc.name != "zero filled" {
+				if _, found := table.Get("b"); found {
+					t.Errorf("got found for b, wanted not found")
+				}
+			}
+			if !bytes.Contains(logbuf.Bytes(), []byte("discarded corrupt records")) {
+				t.Errorf("truncation was not logged")
+			}
+		})
+	}
+}
+
+func TestStrictOpen(t *testing.T) {
+	testCases := []struct {
+		name    string
+		corrupt func(data []byte) []byte
+		wantErr error
+	}{
+		{
+			name: "torn write",
+			corrupt: func(data []byte) []byte {
+				return data[:len(data)-3]
+			},
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		{
+			name: "checksum mismatch",
+			corrupt: func(data []byte) []byte {
+				i := bytes.LastIndex(data, []byte("value"))
+				data[i] = 'V'
+				return data
+			},
+			wantErr: ErrChecksum,
+		},
+		{
+			name: "unknown kind",
+			corrupt: func(data []byte) []byte {
+				return appendRecord(data, record{kind: 'z', key: "c", seq: 3}, defaultChecksum, nil)
+			},
+			wantErr: ErrCorrupt,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			table, tf, err := makeTable(50)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			defer os.Remove(tf.Name())
+
+			err = table.Put("a", []byte("value"))
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			err = table.Put("b", []byte("value"))
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			table.Close()
+
+			data, err := os.ReadFile(tf.Name())
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			err = os.WriteFile(tf.Name(), tc.corrupt(data), 0o666)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+
+			_, err = New(tf.Name(), 50, WithStrictOpen())
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("got error %v, wanted %v", err, tc.wantErr)
+			}
+
+			table, err = New(tf.Name(), 50)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			table.Close()
+		})
+	}
+}
@@ -0,0 +1,304 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/iand/lash/format"
+)
+
+// coldSuffix is appended to the name of the data file to form the name of
+// the cold file.
+const coldSuffix = ".cold"
+
+// WithColdStorage moves items that have not been read by Get or written for
+// the period d into a separate cold file alongside the data file, named by
+// appending ".cold" to its name, whenever the table is compacted. The values
+// of items in the cold file are not held in memory but read from the file
+// whenever they are needed, and their records are omitted from the data
+// file, so the working set of recently used items stays in memory and the
+// data file stays small. An item in the cold file is moved back into memory
+// and the data file when it is written, and when it is compacted after being
+// read. Reads are tracked as described by WithAccessTracking, using a
+// resolution of one minute unless it is also used. Items held in memory
+// count as used when the table is opened.
+//
+// The cold file is rewritten when the table is compacted if its contents
+// have changed, before the data file is replaced. Items in the cold file are
+// not passed to the function set using WithRewrite. The cold file is not
+// included in backups made using BackupSince nor checked by WithScrub, but
+// WriteTo and Archive include its items. A table whose data file has a cold
+// file loads it when opened even if WithColdStorage is not used, in which
+// case its items are moved back into the data file. WithColdStorage has no
+// effect on tables that do not persist data and cannot be used with
+// WithReadOnly.
+func WithColdStorage(d time.Duration) Option {
+	return func(t *Table) {
+		t.coldAfter = d
+	}
+}
+
+// loadCold loads the items in the table's cold file, if it has one. Their
+// values are left in the file unless the table is read only.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) loadCold() error {
+	name := t.filename + coldSuffix
+	if !t.readonly {
+		// An incomplete rewrite never replaced the cold file
+		if err := os.Remove(name + compactSuffix); err != nil && !os.IsNotExist(err) {
+			return ioError("recover cold file", err)
+		}
+	}
+	f, err := openFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return ioError("open cold file", err)
+	}
+
+	d, err := newDecoder(f)
+	if err != nil {
+		f.Close()
+		return ioError("load cold file", err)
+	}
+	for {
+		pos := d.offset()
+		rec, err := d.next()
+		if err == io.EOF {
+			break
+		}
+		if err == nil && rec.kind != kindPut {
+			err = ErrCorrupt
+		}
+		if err != nil {
+			f.Close()
+			return t.recordIOError("load cold file", pos, "", err)
+		}
+		if rec.seq > t.seq {
+			t.seq = rec.seq
+		}
+		p := item{
+			val:     rec.val,
+			pos:     pos,
+			size:    d.offset() - pos,
+			created: rec.created,
+			updated: rec.updated,
+			writes:  rec.writes,
+			seq:     rec.seq,
+		}
+		if !t.readonly {
+			p.val, p.onDisk, p.cold = nil, true, true
+		}
+		t.data[rec.key] = p
+		t.coldCount++
+	}
+	if t.readonly {
+		return f.Close()
+	}
+	t.coldfile, t.coldChecksum, t.coldCodec = f, d.checksum, d.codec
+	return nil
+}
+
+// closeCold closes the table's cold file, if it has one.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) closeCold() error {
+	if t.coldfile == nil {
+		return nil
+	}
+	err := t.coldfile.Close()
+	t.coldfile = nil
+	return err
+}
+
+// markItem marks the record of the item p stored under key k as deleted.
+// Records in the cold file are left in place since they are superseded by
+// the later records in the data file until the cold file is next rewritten.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) markItem(k string, p item) error {
+	if p.cold {
+		return nil
+	}
+	return t.mark(p.pos, t.itemSize(k, p))
+}
+
+// coldPlan holds the cold file written during a compaction until it
+// replaces the table's cold file.
+type coldPlan struct {
+	t        *Table
+	keys     map[string]bool // items whose records are in the cold file after compaction
+	changed  bool            // the cold file must be replaced
+	f        *os.File        // new cold file, nil if the cold file is to be removed
+	codec    *format.Codec
+	written  []string // keys of the items written to the new cold file
+	pos      []int64  // positions of the records of the written items
+	size     []int64  // sizes of the records of the written items
+	replaced bool     // the new cold file has replaced the old one
+}
+
+// prepareCold decides which items are to be held in the cold file after a
+// compaction, writing a new cold file holding them if they differ from those
+// held in the existing one. Items leaving the cold file are also written to
+// the new file until the new data file holding them has replaced the old,
+// and are dropped from it by the next compaction.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) prepareCold(codec *format.Codec) (*coldPlan, error) {
+	c := &coldPlan{t: t, keys: make(map[string]bool), codec: codec}
+
+	now := t.now().UnixNano()
+	cold := 0
+	var leaving []string
+	t.touchMtx.RLock()
+	for k, p := range t.data {
+		if p.cold {
+			cold++
+		}
+		var want bool
+		if p.deleted != 0 {
+			want = p.cold && !t.expired(p.deleted)
+		} else if t.coldAfter > 0 {
+			want = now-t.lastAccess(k, p) > int64(t.coldAfter)
+		}
+		switch {
+		case want:
+			c.keys[k] = true
+			c.changed = c.changed || !p.cold
+		case p.cold:
+			c.changed = true
+			if p.deleted == 0 {
+				leaving = append(leaving, k)
+			}
+		}
+	}
+	t.touchMtx.RUnlock()
+	// Records of items that have since been replaced or deleted remain in
+	// the cold file
+	if cold != t.coldCount {
+		c.changed = true
+	}
+	if !c.changed || len(c.keys) == 0 && t.coldfile == nil {
+		c.changed = false
+		return c, nil
+	}
+
+	for k := range c.keys {
+		c.written = append(c.written, k)
+	}
+	sort.Slice(c.written, func(i, j int) bool { return t.data[c.written[i]].seq < t.data[c.written[j]].seq })
+	c.written = append(c.written, leaving...)
+	if len(c.keys) == 0 {
+		return c, nil
+	}
+
+	tmpname := t.filename + coldSuffix + compactSuffix
+	f, err := openFile(tmpname, os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.FileMode(0666))
+	if err != nil {
+		return nil, err
+	}
+	c.f = f
+	if err := c.write(); err != nil {
+		c.abort()
+		return nil, err
+	}
+	return c, nil
+}
+
+// write writes the records of the items to be held in the new cold file.
+func (c *coldPlan) write() error {
+	t := c.t
+	w := bufio.NewWriter(c.f)
+	buf := appendHeader(nil, t.checksum, c.codec)
+	offset := int64(len(buf))
+	if _, err := w.Write(buf); err != nil {
+		return err
+	}
+	for _, k := range c.written {
+		p := t.data[k]
+		v, err := t.value(p)
+		if err != nil {
+			return err
+		}
+		p.val, p.coll = v, nil
+		buf = appendRecord(buf[:0], p.record(k), t.checksum, c.codec)
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+		c.pos = append(c.pos, offset)
+		c.size = append(c.size, int64(len(buf)))
+		offset += int64(len(buf))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return c.f.Sync()
+}
+
+// replace replaces the table's cold file with the new cold file, or removes
+// it if no items are to be held in it.
+func (c *coldPlan) replace() error {
+	if !c.changed {
+		return nil
+	}
+	name := c.t.filename + coldSuffix
+	var err error
+	if c.f == nil {
+		err = os.Remove(name)
+		if os.IsNotExist(err) {
+			err = nil
+		}
+	} else {
+		err = os.Rename(name+compactSuffix, name)
+	}
+	if err != nil {
+		return err
+	}
+	c.replaced = true
+	return syncDir(filepath.Dir(name))
+}
+
+// abort discards the new cold file. If it has already replaced the table's
+// cold file then the table continues to read from the replaced file, which
+// holds the same values, until it is next compacted.
+func (c *coldPlan) abort() {
+	if c.f == nil {
+		return
+	}
+	c.f.Close()
+	if !c.replaced {
+		os.Remove(c.t.filename + coldSuffix + compactSuffix)
+	}
+}
+
+// apply updates the table to read from the new cold file once it and the
+// new data file have replaced the old.
+// It is the responsibility of the caller to acquire locks.
+func (c *coldPlan) apply() {
+	if !c.changed {
+		return
+	}
+	t := c.t
+	if err := t.closeCold(); err != nil {
+		t.logger.Error("lash: failed to close cold file", "file", t.filename+coldSuffix, "error", err)
+	}
+	t.coldfile, t.coldChecksum, t.coldCodec = c.f, t.checksum, c.codec
+	t.coldCount = len(c.written)
+	for i, k := range c.written {
+		if !c.keys[k] {
+			// Items leaving the cold file are updated to refer to the new
+			// data file
+			continue
+		}
+		p := t.data[k]
+		p.val, p.coll, p.pos, p.size, p.onDisk, p.cold = nil, nil, c.pos[i], c.size[i], true, true
+		t.data[k] = p
+	}
+}
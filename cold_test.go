@@ -0,0 +1,118 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestColdStorage(t *testing.T) {
+	tf, err := os.CreateTemp("", "lash")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tf.Close()
+	defer os.Remove(tf.Name())
+	defer os.Remove(tf.Name() + coldSuffix)
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	open := func() *Table {
+		table, err := New(tf.Name(), 50, WithColdStorage(time.Hour), withClock(clock))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return table
+	}
+
+	table := open()
+	for _, k := range []string{"a", "b", "c", "d"} {
+		if err := table.PutString(k, "val"+k); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	now = now.Add(2 * time.Hour)
+	if _, found := table.Get("a"); !found {
+		t.Fatalf("key %q not found", "a")
+	}
+	if err := table.Compact(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := os.Stat(tf.Name() + coldSuffix); err != nil {
+		t.Fatalf("cold file not written: %v", err)
+	}
+
+	check := func(table *Table, want map[string]string) {
+		t.Helper()
+		for k, v := range want {
+			got, found := table.GetString(k)
+			if found != (v != "") {
+				t.Errorf("got found %v for key %q, wanted %v", found, k, v != "")
+				continue
+			}
+			if got != v {
+				t.Errorf("got %q for key %q, wanted %q", got, k, v)
+			}
+		}
+	}
+
+	check(table, map[string]string{"a": "vala", "b": "valb", "c": "valc", "d": "vald"})
+	if table.data["a"].cold || !table.data["b"].cold {
+		t.Errorf("got cold %v, %v for keys a and b, wanted false, true", table.data["a"].cold, table.data["b"].cold)
+	}
+
+	if err := table.PutString("b", "new"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.Delete("c"); err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Close()
+
+	// Reading an item counts as using it, so the items are checked after
+	// compacting
+	table = open()
+	if err := table.Compact(); err != nil {
+		t.Fatal(err.Error())
+	}
+	want := map[string]string{"a": "vala", "b": "new", "c": "", "d": "vald"}
+	check(table, want)
+	table.Close()
+
+	table = open()
+	if !table.data["d"].cold {
+		t.Errorf("got key d not cold after reopening, wanted cold")
+	}
+	check(table, want)
+
+	// Reading cold items moves them back into the data file
+	now = now.Add(2 * time.Hour)
+	for _, k := range []string{"a", "b", "d"} {
+		if _, found := table.Get(k); !found {
+			t.Fatalf("key %q not found", k)
+		}
+	}
+	if err := table.Compact(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if table.data["d"].cold {
+		t.Errorf("got key d cold after it was read, wanted not cold")
+	}
+	check(table, want)
+	if err := table.Compact(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := os.Stat(tf.Name() + coldSuffix); !os.IsNotExist(err) {
+		t.Errorf("got cold file after all items were read, wanted it removed")
+	}
+	table.Close()
+
+	table = open()
+	defer table.Close()
+	check(table, want)
+}
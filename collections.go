@@ -0,0 +1,550 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"encoding/binary"
+	"errors"
+	"slices"
+)
+
+// ErrWrongType is returned when an operation on a collection such as a set
+// or list is applied to a value of a different type.
+var ErrWrongType = errors.New("lash: value has the wrong type for the operation")
+
+// Sets, lists and sorted sets are stored as a byte identifying the type
+// followed by each element preceded by its length. The members of a set are
+// held in sorted order. Each element of a sorted set is its score, encoded
+// so that the bytewise order of the elements matches the numeric order of
+// the scores, followed by its member. Elements are held in sorted order so
+// they are ordered by score and then by member.
+const (
+	typeSet    = byte('S')
+	typeList   = byte('L')
+	typeZSet   = byte('Z')
+	typeHLL    = byte('H') // followed by the registers of a HyperLogLog sketch
+	typeBitmap = byte('B') // followed by a roaring bitmap
+)
+
+// Operations held in op records, which modify a collection such as a set or
+// list.
+const (
+	opSetAdd    = byte('a') // add the member held in the operand
+	opSetRemove = byte('r') // remove the member held in the operand
+	opListPush  = byte('p') // append the element held in the operand
+	opListPop   = byte('o') // remove the last element
+	opZAdd      = byte('z') // add or rescore the sorted set element held in the operand
+	opZRemove   = byte('x') // remove the sorted set member held in the operand
+	opHLLAdd    = byte('h') // raise the HyperLogLog register held in the operand
+	opBitSet    = byte('b') // set the bitmap bit held in the operand
+	opBitClear  = byte('c') // clear the bitmap bit held in the operand
+)
+
+// Set returns a view of the set stored under key k. The set need not
+// exist until a member is added.
+func (t *Table) Set(k string) Set {
+	return Set{t: t, k: k}
+}
+
+// Set is a view of a set of strings stored under a key in a Table. Adding or
+// removing a member appends a small record to the data file rather than
+// rewriting the whole set.
+type Set struct {
+	t *Table
+	k string
+}
+
+// Add adds m to the set and reports whether it was not already a member.
+func (s Set) Add(m string) (bool, error) {
+	_, changed, err := s.t.modify(s.k, typeSet, opSetAdd, []byte(m))
+	return changed, err
+}
+
+// Remove removes m from the set and reports whether it was a member.
+func (s Set) Remove(m string) (bool, error) {
+	_, changed, err := s.t.modify(s.k, typeSet, opSetRemove, []byte(m))
+	return changed, err
+}
+
+// Contains reports whether m is a member of the set.
+func (s Set) Contains(m string) bool {
+	var found bool
+	s.t.view(s.k, typeSet, func(c *collection) {
+		_, _, found = c.find([]byte(m))
+	})
+	return found
+}
+
+// Members returns the members of the set in sorted order.
+func (s Set) Members() []string {
+	var members []string
+	s.t.view(s.k, typeSet, func(c *collection) {
+		members = make([]string, 0, c.elems.len())
+		c.elems.scan(0, 0, func(e []byte) bool {
+			members = append(members, string(e))
+			return true
+		})
+	})
+	return members
+}
+
+// List returns a view of the list stored under key k. The list need not
+// exist until an element is pushed.
+func (t *Table) List(k string) List {
+	return List{t: t, k: k}
+}
+
+// List is a view of a list of byte slices stored under a key in a Table.
+// Pushing or popping an element appends a small record to the data file
+// rather than rewriting the whole list.
+type List struct {
+	t *Table
+	k string
+}
+
+// Push appends v to the end of the list.
+func (l List) Push(v []byte) error {
+	_, _, err := l.t.modify(l.k, typeList, opListPush, v)
+	return err
+}
+
+// Pop removes the element at the end of the list and returns it along with
+// a boolean that indicates whether the list held any elements.
+func (l List) Pop() ([]byte, bool, error) {
+	e, changed, err := l.t.modify(l.k, typeList, opListPop, nil)
+	if err != nil || !changed {
+		return nil, false, err
+	}
+	return e, true, nil
+}
+
+// Index returns the element at position i in the list along with a boolean
+// that indicates whether the list has such an element.
+func (l List) Index(i int) ([]byte, bool) {
+	var e []byte
+	var found bool
+	l.t.view(l.k, typeList, func(c *collection) {
+		e, found = c.elems.get(c.elems.locate(i))
+	})
+	return e, found
+}
+
+// Len returns the number of elements in the list.
+func (l List) Len() int {
+	var n int
+	l.t.view(l.k, typeList, func(c *collection) {
+		n = c.elems.len()
+	})
+	return n
+}
+
+// view calls fn with the set, list or sorted set of type typ stored under
+// key k while the table is locked for reading. fn is not called if there is
+// no such value. The collection passed to fn must not be modified or
+// retained.
+func (t *Table) view(k string, typ byte, fn func(c *collection)) {
+	t.getLimit.wait()
+	t.mtx.RLock()
+	cur, found := t.data[k]
+	if !found || cur.deleted != 0 {
+		t.mtx.RUnlock()
+		return
+	}
+	c := cur.coll
+	if c == nil {
+		v, err := t.value(cur)
+		if err != nil {
+			t.mtx.RUnlock()
+			t.logger.Error("lash: failed to read value", "key", t.redactKey(k), "error", err)
+			return
+		}
+		c, _ = decodeCollection(v, typ)
+	}
+	if c != nil && c.typ == typ {
+		fn(c)
+	}
+	t.mtx.RUnlock()
+	t.touch(k)
+}
+
+// modify applies the operation op with the given operand to the collection
+// of type typ stored under key k by writing an op record, creating the
+// value if it does not exist. It returns the element removed or replaced
+// by the operation, if any, and reports whether the operation changed the
+// value.
+func (t *Table) modify(k string, typ byte, op byte, operand []byte) ([]byte, bool, error) {
+	t.putLimit.wait()
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	cur, exists := t.data[k]
+	if !exists || cur.deleted != 0 {
+		v, changed, err := applyOp([]byte{typ}, op, operand)
+		if err != nil || !changed {
+			return nil, false, err
+		}
+		return nil, true, t.put(k, v, 0)
+	}
+	val := binary.AppendUvarint(nil, cur.seq)
+	val = append(val, op)
+	val = append(val, operand...)
+
+	if typ == typeHLL || typ == typeBitmap {
+		old, err := t.value(cur)
+		if err != nil {
+			return nil, false, err
+		}
+		v, changed, err := applyOp(old, op, operand)
+		if err != nil || !changed {
+			return nil, false, err
+		}
+		return nil, true, t.writeDelta(k, cur, kindOp, val, v)
+	}
+
+	c, err := t.collection(cur, typ)
+	if err != nil {
+		return nil, false, err
+	}
+	// The previous value is needed to update the indexes, and the size of
+	// the item's record for the accounting of garbage, both of which change
+	// along with the collection
+	var prev []byte
+	if len(t.indexes) > 0 {
+		prev = c.encode()
+	}
+	before := t.itemSize(k, cur)
+	e, changed, err := c.apply(op, operand)
+	if err != nil || !changed {
+		return nil, false, err
+	}
+	if err := t.writeOp(k, cur, val, c, prev, before); err != nil {
+		c.undo(op, operand, e)
+		return nil, false, err
+	}
+	return e, true, nil
+}
+
+// writeOp writes an op record holding val, which changes the value of the
+// live item cur stored under key k to the collection c. The item then holds
+// c in place of its encoded value. prev is the previous value, which is
+// only needed if the table has indexes, and before is the size of the
+// item's record.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) writeOp(k string, cur item, val []byte, c *collection, prev []byte, before int64) error {
+	if cur.cold {
+		// A delta cannot refer to a record in the cold file, as explained
+		// by writeDelta
+		return t.put(k, c.encode(), 0)
+	}
+	if err := t.checkCollection(k, c); err != nil {
+		return err
+	}
+	rec := t.deltaRecord(k, cur, kindOp, val)
+	if _, err := t.write(rec); err != nil {
+		return err
+	}
+
+	cur.val, cur.coll = nil, c
+	t.folded(&cur, rec, before, recordSize(rec, t.checksum))
+	t.data[k] = cur
+	t.logical += int64(len(k) + len(rec.val))
+	if len(t.indexes) > 0 || t.shadow != nil {
+		v := c.encode()
+		t.unindexValue(k, prev)
+		t.indexValue(k, v)
+		t.mirrorValue(k, v)
+	}
+	return nil
+}
+
+// checkCollection returns an error if the collection c may not be stored
+// under key k. The collection is only encoded if the table has a validator.
+func (t *Table) checkCollection(k string, c *collection) error {
+	if t.validator != nil {
+		return t.checkPut(k, c.encode())
+	}
+	if err := t.checkKey(k); err != nil {
+		return err
+	}
+	if t.maxValue > 0 && c.size > t.maxValue {
+		return ErrValueTooLarge
+	}
+	return nil
+}
+
+// collection returns the value of the live item cur, which must be a set,
+// list or sorted set of type typ, as a collection. The value is decoded if
+// the item does not already hold it as one.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) collection(cur item, typ byte) (*collection, error) {
+	if cur.coll != nil {
+		if cur.coll.typ != typ {
+			return nil, ErrWrongType
+		}
+		return cur.coll, nil
+	}
+	v, err := t.value(cur)
+	if err != nil {
+		return nil, err
+	}
+	return decodeCollection(v, typ)
+}
+
+// loadOp applies an op record, occupying size bytes in the data file, to the
+// collection it modifies. Op records for values that have since been
+// replaced or deleted are counted as garbage. Op records for sets, lists and
+// sorted sets are folded into the decoded form of the value, which is then
+// held by its item, so that loading a long series of them does not decode
+// and encode the value for each one.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) loadOp(rec record, size int64) error {
+	base, n := binary.Uvarint(rec.val)
+	if n <= 0 || n == len(rec.val) {
+		return ErrCorrupt
+	}
+	op, operand := rec.val[n], rec.val[n+1:]
+
+	cur, exists := t.data[rec.key]
+	if !exists || cur.deleted != 0 || cur.seq != base {
+		t.garbage += size
+		return nil
+	}
+
+	typ := opType(op)
+	if typ == 0 {
+		old, err := t.value(cur)
+		if err != nil {
+			return err
+		}
+		v, _, err := applyOp(old, op, operand)
+		if err != nil {
+			t.garbage += size
+			return nil
+		}
+		t.fold(&cur, rec, v, size)
+		t.data[rec.key] = cur
+		return nil
+	}
+
+	c, err := t.collection(cur, typ)
+	if err == ErrWrongType {
+		t.garbage += size
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	before := t.itemSize(rec.key, cur)
+	if _, _, err := c.apply(op, operand); err != nil {
+		t.garbage += size
+		return nil
+	}
+	cur.val, cur.coll = nil, c
+	t.folded(&cur, rec, before, size)
+	t.data[rec.key] = cur
+	return nil
+}
+
+// applyOp returns the result of applying the operation op with the given
+// operand to the encoded value v, and reports whether v was changed.
+func applyOp(v []byte, op byte, operand []byte) ([]byte, bool, error) {
+	switch op {
+	case opHLLAdd:
+		return applyHLL(v, operand)
+	case opBitSet, opBitClear:
+		return applyBitmap(v, op, operand)
+	}
+	c, err := decodeCollection(v, opType(op))
+	if err != nil {
+		return nil, false, err
+	}
+	if _, changed, err := c.apply(op, operand); err != nil || !changed {
+		return v, false, err
+	}
+	return c.encode(), true, nil
+}
+
+// opType returns the type of collection modified by the operation op, or
+// zero if op does not modify a set, list or sorted set.
+func opType(op byte) byte {
+	switch op {
+	case opSetAdd, opSetRemove:
+		return typeSet
+	case opListPush, opListPop:
+		return typeList
+	case opZAdd, opZRemove:
+		return typeZSet
+	}
+	return 0
+}
+
+// A collection is the decoded form of a set, list or sorted set. Once such a
+// value has been modified by an op record its item holds it as a collection
+// in place of its encoded value, so that later operations need not decode
+// and encode the whole value. The value is encoded only when it is needed
+// in full, such as by Get or when the data file is compacted. A collection
+// belongs to a single item and is only accessed while the table is locked.
+type collection struct {
+	typ     byte
+	elems   blockList[[]byte] // in sorted order for sets and sorted sets
+	members map[string][]byte // the element of each member of a sorted set
+	size    int               // length of the encoded value
+}
+
+// decodeCollection decodes the set, list or sorted set of type typ encoded
+// in v. It returns ErrWrongType if v does not hold a value of that type.
+func decodeCollection(v []byte, typ byte) (*collection, error) {
+	if typ == 0 {
+		return nil, ErrCorrupt
+	}
+	elems, err := decodeElems(v, typ)
+	if err != nil {
+		return nil, err
+	}
+	c := &collection{typ: typ, size: len(v)}
+	if typ == typeZSet {
+		c.members = make(map[string][]byte, len(elems))
+		for _, e := range elems {
+			c.members[string(e[8:])] = e
+		}
+	}
+	c.elems.set(elems)
+	return c, nil
+}
+
+// encode returns the encoded form of the collection.
+func (c *collection) encode() []byte {
+	buf := make([]byte, 0, c.size)
+	buf = append(buf, c.typ)
+	c.elems.scan(0, 0, func(e []byte) bool {
+		buf = appendBytes(buf, e)
+		return true
+	})
+	return buf
+}
+
+// find returns the position at which the element e is, or would be, held in
+// a set or sorted set and reports whether it is held.
+func (c *collection) find(e []byte) (int, int, bool) {
+	b, i := c.elems.search(func(x []byte) bool { return string(x) >= string(e) })
+	x, ok := c.elems.get(b, i)
+	return b, i, ok && string(x) == string(e)
+}
+
+// insert inserts a copy of the element e at position (b, i) and returns
+// the copy.
+func (c *collection) insert(b, i int, e []byte) []byte {
+	e = slices.Clone(e)
+	c.elems.insert(b, i, e)
+	c.size += uvarintLen(uint64(len(e))) + len(e)
+	return e
+}
+
+// remove removes and returns the element at position (b, i).
+func (c *collection) remove(b, i int) []byte {
+	e := c.elems.remove(b, i)
+	c.size -= uvarintLen(uint64(len(e))) + len(e)
+	return e
+}
+
+// apply applies the operation op with the given operand to the collection.
+// It returns the element removed or replaced by the operation, if any, and
+// reports whether the collection was changed.
+func (c *collection) apply(op byte, operand []byte) ([]byte, bool, error) {
+	if opType(op) != c.typ {
+		return nil, false, ErrWrongType
+	}
+	switch op {
+	case opSetAdd:
+		b, i, found := c.find(operand)
+		if found {
+			return nil, false, nil
+		}
+		c.insert(b, i, operand)
+	case opSetRemove:
+		b, i, found := c.find(operand)
+		if !found {
+			return nil, false, nil
+		}
+		return c.remove(b, i), true, nil
+	case opListPush:
+		b, i := c.elems.end()
+		c.insert(b, i, operand)
+	case opListPop:
+		if c.elems.len() == 0 {
+			return nil, false, nil
+		}
+		b, i := c.elems.locate(c.elems.len() - 1)
+		return c.remove(b, i), true, nil
+	case opZAdd:
+		if len(operand) < 8 {
+			return nil, false, ErrCorrupt
+		}
+		old, found := c.members[string(operand[8:])]
+		if found {
+			if string(old) == string(operand) {
+				return nil, false, nil
+			}
+			b, i, _ := c.find(old)
+			c.remove(b, i)
+		}
+		b, i, _ := c.find(operand)
+		c.members[string(operand[8:])] = c.insert(b, i, operand)
+		return old, true, nil
+	case opZRemove:
+		old, found := c.members[string(operand)]
+		if !found {
+			return nil, false, nil
+		}
+		b, i, _ := c.find(old)
+		c.remove(b, i)
+		delete(c.members, string(operand))
+		return old, true, nil
+	}
+	return nil, true, nil
+}
+
+// undo reverses the operation op with the given operand, which changed the
+// collection and returned the element e.
+func (c *collection) undo(op byte, operand []byte, e []byte) {
+	switch op {
+	case opSetAdd:
+		c.apply(opSetRemove, operand)
+	case opSetRemove:
+		c.apply(opSetAdd, operand)
+	case opListPush:
+		c.apply(opListPop, nil)
+	case opListPop:
+		c.apply(opListPush, e)
+	case opZAdd:
+		if e == nil {
+			c.apply(opZRemove, operand[8:])
+		} else {
+			c.apply(opZAdd, e)
+		}
+	case opZRemove:
+		c.apply(opZAdd, e)
+	}
+}
+
+// decodeElems decodes the elements of the set or list of type typ encoded
+// in v. It returns ErrWrongType if v does not hold a value of that type.
+func decodeElems(v []byte, typ byte) ([][]byte, error) {
+	if len(v) == 0 || v[0] != typ {
+		return nil, ErrWrongType
+	}
+	var elems [][]byte
+	for b := v[1:]; len(b) > 0; {
+		var e []byte
+		var err error
+		e, b, err = consumeBytes(b)
+		if err != nil || typ == typeZSet && len(e) < 8 {
+			return nil, ErrWrongType
+		}
+		elems = append(elems, e)
+	}
+	return elems, nil
+}
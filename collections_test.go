@@ -0,0 +1,180 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	s := table.Set("tags")
+	for _, m := range []string{"red", "green", "blue", "green"} {
+		if _, err := s.Add(m); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if added, _ := s.Add("red"); added {
+		t.Errorf("got added for existing member, wanted not added")
+	}
+	if removed, _ := s.Remove("green"); !removed {
+		t.Errorf("got not removed for member, wanted removed")
+	}
+	if removed, _ := s.Remove("pink"); removed {
+		t.Errorf("got removed for non-member, wanted not removed")
+	}
+	if !s.Contains("blue") || s.Contains("green") {
+		t.Errorf("got contains blue %v and green %v, wanted true and false", s.Contains("blue"), s.Contains("green"))
+	}
+	if got, want := s.Members(), []string{"blue", "red"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+
+	table.PutString("name", "value")
+	if _, err := table.Set("name").Add("x"); err != ErrWrongType {
+		t.Errorf("got error %v, wanted %v", err, ErrWrongType)
+	}
+	table.Close()
+
+	table2, err := New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table2.Close()
+	if got, want := table2.Set("tags").Members(), []string{"blue", "red"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q after reopen, wanted %q", got, want)
+	}
+}
+
+func TestList(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	l := table.List("queue")
+	if _, ok, _ := l.Pop(); ok {
+		t.Errorf("got element from empty list, wanted none")
+	}
+	for _, v := range []string{"a", "b", "c"} {
+		if err := l.Push([]byte(v)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	v, ok, err := l.Pop()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !ok || string(v) != "c" {
+		t.Errorf("got %q, wanted %q", v, "c")
+	}
+	l.Push([]byte("d"))
+	if l.Len() != 3 {
+		t.Errorf("got len %d, wanted %d", l.Len(), 3)
+	}
+	if v, _ := l.Index(2); string(v) != "d" {
+		t.Errorf("got %q, wanted %q", v, "d")
+	}
+	if _, ok := l.Index(3); ok {
+		t.Errorf("got element beyond end of list, wanted none")
+	}
+
+	// Folded op records are counted as garbage
+	s := table.Stats()
+	live := s.FileBytes - s.GarbageBytes
+	table.Close()
+
+	table2, err := New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table2.Close()
+	if s := table2.Stats(); s.FileBytes != live {
+		t.Errorf("got file bytes %d, wanted %d", s.FileBytes, live)
+	}
+	l2 := table2.List("queue")
+	var got []string
+	for i := 0; i < l2.Len(); i++ {
+		v, _ := l2.Index(i)
+		got = append(got, string(v))
+	}
+	if want := []string{"a", "b", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q after reopen, wanted %q", got, want)
+	}
+}
+
+func TestSetRejected(t *testing.T) {
+	table, err := New("", 50, WithMaxValueSize(12))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+
+	s := table.Set("tags")
+	for _, m := range []string{"red", "blue"} {
+		if _, err := s.Add(m); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if _, err := s.Add("green"); err != ErrValueTooLarge {
+		t.Errorf("got error %v, wanted %v", err, ErrValueTooLarge)
+	}
+	if got, want := s.Members(), []string{"blue", "red"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+	if v, _ := table.Get("tags"); string(v) != "S\x04blue\x03red" {
+		t.Errorf("got value %q, wanted %q", v, "S\x04blue\x03red")
+	}
+}
+
+func TestListLoadMany(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	const n = 2000
+	l := table.List("queue")
+	for i := 0; i < n; i++ {
+		if err := l.Push([]byte(fmt.Sprintf("e%d", i))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	for i := 0; i < n/2; i++ {
+		if _, _, err := l.Pop(); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	want, _ := table.Get("queue")
+	table.Close()
+
+	table2, err := New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table2.Close()
+	// The op records are folded into the decoded list
+	if table2.data["queue"].coll == nil {
+		t.Errorf("got list held encoded after load, wanted decoded")
+	}
+	if got, _ := table2.Get("queue"); !bytes.Equal(got, want) {
+		t.Errorf("got value of %d bytes after reopen, wanted %d bytes", len(got), len(want))
+	}
+	if v, _ := table2.List("queue").Index(n/2 - 1); string(v) != fmt.Sprintf("e%d", n/2-1) {
+		t.Errorf("got %q, wanted %q", v, fmt.Sprintf("e%d", n/2-1))
+	}
+}
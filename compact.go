@@ -0,0 +1,389 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/iand/lash/format"
+)
+
+// Compaction writes the new data file alongside the original using these
+// suffixes. The original is renamed with oldSuffix while the new file is
+// renamed into place so that it is never lost. swapSuffix was used by earlier
+// releases when rewriting the data file during initialisation.
+const (
+	compactSuffix = ".compact"
+	oldSuffix     = ".old"
+	swapSuffix    = ".swp"
+)
+
+// WithRewrite sets a function that is called with the key and value of each
+// item as it is rewritten whenever the data file is compacted, including
+// when the table is opened. The function returns the value to be stored in
+// place of v and false if the item should be dropped from the table instead.
+// This allows values to be migrated to a new format, or scrubbed, lazily
+// without a separate pass over the table. Soft deleted items are rewritten
+// unchanged. The function must not modify v or call methods of the table,
+// and the values it returns are not checked against the table's size limits
+// or validator.
+func WithRewrite(fn func(k string, v []byte) ([]byte, bool)) Option {
+	return func(t *Table) {
+		t.rewrite = fn
+	}
+}
+
+// Compact rewrites the table's data file to remove tombstones and soft
+// deleted items whose undelete window has passed. The new file is written
+// and synced under a temporary name before atomically replacing the original,
+// which is retained until the replacement is complete. If any step fails then
+// the original file is restored and the table continues to use it. A table
+// that stopped accepting changes because the disk was full accepts them
+// again once it has been compacted.
+func (t *Table) Compact() error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.readonly {
+		return ErrReadOnly
+	}
+	if t.dbfile == nil {
+		if t.filename == "" {
+			return nil
+		}
+		return errors.New("database not open")
+	}
+	if t.stalled {
+		return ErrFsyncTimeout
+	}
+	if err := t.compact(); err != nil {
+		return err
+	}
+	if t.full {
+		// Compaction may have freed enough space to continue
+		t.full = false
+		if err := t.writeReserve(); err != nil {
+			t.logger.Error("lash: failed to write disk reserve", "file", t.filename, "error", err)
+		}
+	}
+	return nil
+}
+
+// EstimateCompaction estimates the effect of compacting the table, so that
+// a scheduler can decide whether compaction is worth the IO it needs. It
+// returns the number of bytes of the data file that compaction would
+// reclaim, being those occupied by deleted or superseded records and by soft
+// deleted items whose undelete window has passed, and the number occupied
+// by records that would be kept. The estimate is made from the table's
+// accounting of its data file without reading it. Kept records may be a
+// different size once rewritten, such as when the table's compression has
+// changed, and items moved to or from the cold file by WithColdStorage are
+// not taken into account.
+func (t *Table) EstimateCompaction() (reclaimable int64, live int64) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	reclaimable = t.garbage
+	for k, p := range t.data {
+		if p.deleted == 0 || !t.expired(p.deleted) {
+			continue
+		}
+		reclaimable += t.itemSize(k, p) + recordSize(p.softDeleteRecord(k), t.checksum)
+	}
+	return reclaimable, max(t.size-reclaimable, 0)
+}
+
+// compact replaces the table's data file, if any, with a new file holding
+// only the table's current state.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) compact() error {
+	start := t.now()
+	before := t.size
+
+	tmpname := t.filename + compactSuffix
+	oldname := t.filename + oldSuffix
+
+	codec, err := t.newCodec()
+	if err != nil {
+		return err
+	}
+
+	cold, err := t.prepareCold(codec)
+	if err != nil {
+		return ioError("compact", err)
+	}
+
+	f, err := openFile(tmpname, os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.FileMode(0666))
+	if err != nil {
+		cold.abort()
+		return ioError("compact", err)
+	}
+
+	apply, size, err := t.writeSnapshot(f, codec, t.rewrite, cold.keys)
+	if err == nil {
+		err = t.syncFile(f.Sync)
+	}
+	if err == nil {
+		// The cold file must be replaced first since the new data file
+		// omits the items moved to it
+		err = cold.replace()
+	}
+	if err != nil {
+		cold.abort()
+		f.Close()
+		os.Remove(tmpname)
+		if err == ErrFsyncTimeout {
+			return err
+		}
+		return ioError("compact", err)
+	}
+
+	if t.dbfile != nil {
+		err = os.Rename(t.filename, oldname)
+		if err != nil {
+			cold.abort()
+			f.Close()
+			os.Remove(tmpname)
+			return ioError("compact", err)
+		}
+	}
+
+	err = os.Rename(tmpname, t.filename)
+	if err == nil {
+		err = syncDir(filepath.Dir(t.filename))
+		if err != nil {
+			os.Rename(t.filename, tmpname)
+		}
+	}
+	if err != nil {
+		if t.dbfile != nil {
+			os.Rename(oldname, t.filename)
+		}
+		cold.abort()
+		f.Close()
+		os.Remove(tmpname)
+		if err == ErrFsyncTimeout {
+			return err
+		}
+		return ioError("compact", err)
+	}
+
+	if t.dbfile != nil {
+		t.dbfile.Close()
+		os.Remove(oldname)
+	}
+	t.dbfile = f
+	t.fileID = newFileID()
+	t.codec = codec
+	cold.apply()
+	apply()
+	t.forgetAccess()
+	t.size = size
+	t.durable = size
+	t.garbage = 0
+	t.written += size
+	t.rewritten += size
+	t.compacted(start, before)
+	return nil
+}
+
+// writeSnapshot writes the table's current state to f in the order in which
+// the records were originally written, compressing values using codec.
+// Soft deleted items whose undelete window has passed are omitted, as are
+// the put records of items whose keys are in skip, which are held in the
+// cold file. Values left in the data file by WithMaxLoadBytes or held in the
+// cold file are read from it. If rewrite is not nil then it is used to
+// transform or drop the values of items that are not soft deleted or held
+// in the cold file, as described by WithRewrite. It returns the number of
+// bytes written and a function that updates the table to refer to the
+// records in f, which must only be called once f has replaced the table's
+// data file. Items that were held in the cold file but are not in skip are
+// then held in memory.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) writeSnapshot(f io.Writer, codec *format.Codec, rewrite func(k string, v []byte) ([]byte, bool), skip map[string]bool) (func(), int64, error) {
+	type entry struct {
+		pos  int64
+		kind byte // zero if the entry was dropped by rewrite
+		key  string
+		cold bool // the record is in the cold file
+	}
+	type change struct {
+		key      string
+		old, new []byte
+		drop     bool
+	}
+	var changes []change
+	var expired []string
+	warmed := make(map[string][]byte)
+	entries := make([]entry, 0, len(t.data)+t.trashed+len(t.meta))
+	for k, p := range t.data {
+		if p.deleted != 0 && t.expired(p.deleted) {
+			expired = append(expired, k)
+			continue
+		}
+		if !skip[k] {
+			entries = append(entries, entry{pos: p.pos, kind: kindPut, key: k, cold: p.cold})
+		}
+		if p.deleted != 0 {
+			entries = append(entries, entry{pos: p.dpos, kind: kindSoftDelete, key: k})
+		}
+	}
+	for k, m := range t.meta {
+		entries = append(entries, entry{pos: m.pos, kind: kindMeta, key: k})
+	}
+	// Records in the cold file are loaded before any in the data file
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].cold != entries[j].cold {
+			return entries[i].cold
+		}
+		return entries[i].pos < entries[j].pos
+	})
+
+	w := bufio.NewWriter(f)
+	buf := appendHeader(nil, t.checksum, codec)
+	offset := int64(0)
+	for i, e := range entries {
+		if len(buf) > 0 {
+			if _, err := w.Write(buf); err != nil {
+				return nil, 0, err
+			}
+			offset += int64(len(buf))
+			buf = buf[:0]
+		}
+		entries[i].pos = offset
+		switch e.kind {
+		case kindPut:
+			p := t.data[e.key]
+			v, err := t.value(p)
+			if err != nil {
+				return nil, 0, err
+			}
+			if p.cold && skip != nil {
+				warmed[e.key] = v
+			}
+			if rewrite != nil && p.deleted == 0 && !p.cold {
+				nv, keep := rewrite(e.key, v)
+				if !keep {
+					changes = append(changes, change{key: e.key, old: v, drop: true})
+					entries[i].kind = 0
+					continue
+				}
+				if !bytes.Equal(nv, v) {
+					changes = append(changes, change{key: e.key, old: v, new: nv})
+					v = nv
+				}
+			}
+			p.val, p.coll = v, nil
+			buf = appendRecord(buf, p.record(e.key), t.checksum, codec)
+		case kindSoftDelete:
+			buf = appendRecord(buf, t.data[e.key].softDeleteRecord(e.key), t.checksum, codec)
+		case kindMeta:
+			buf = appendRecord(buf, t.meta[e.key].record(e.key), t.checksum, codec)
+		}
+	}
+	if _, err := w.Write(buf); err != nil {
+		return nil, 0, err
+	}
+	offset += int64(len(buf))
+	if err := w.Flush(); err != nil {
+		return nil, 0, err
+	}
+
+	apply := func() {
+		for _, k := range expired {
+			t.unindexItem(k, t.data[k])
+			delete(t.data, k)
+			t.trashed--
+		}
+		for i, e := range entries {
+			end := offset
+			if i+1 < len(entries) {
+				end = entries[i+1].pos
+			}
+			switch e.kind {
+			case kindPut:
+				p := t.data[e.key]
+				p.pos, p.size = e.pos, end-e.pos
+				if v, ok := warmed[e.key]; ok {
+					p.val, p.onDisk, p.cold = v, false, false
+				}
+				t.data[e.key] = p
+			case kindSoftDelete:
+				p := t.data[e.key]
+				p.dpos = e.pos
+				t.data[e.key] = p
+			case kindMeta:
+				m := t.meta[e.key]
+				m.pos = e.pos
+				m.size = end - e.pos
+				t.meta[e.key] = m
+			}
+		}
+		for _, c := range changes {
+			t.unindexValue(c.key, c.old)
+			if c.drop {
+				delete(t.data, c.key)
+				continue
+			}
+			p := t.data[c.key]
+			if !p.onDisk {
+				p.val, p.coll = c.new, nil
+			}
+			t.data[c.key] = p
+			t.indexValue(c.key, c.new)
+		}
+	}
+
+	return apply, offset, nil
+}
+
+// recoverFiles restores the data file named fname after a compaction, or
+// initialisation by an earlier release, was interrupted. It reports whether
+// any files left by an interrupted compaction were found.
+func recoverFiles(fname string) (bool, error) {
+	// An incomplete compaction never replaced the data file
+	err := os.Remove(fname + compactSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	found := err == nil
+
+	_, err = os.Stat(fname)
+	if err != nil && !os.IsNotExist(err) {
+		return found, err
+	}
+	exists := err == nil
+
+	for _, suffix := range []string{oldSuffix, swapSuffix} {
+		_, err = os.Stat(fname + suffix)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return found, err
+		}
+		found = true
+
+		if suffix == oldSuffix && exists {
+			// The compacted file was renamed into place but the original
+			// was not removed
+			err = os.Remove(fname + suffix)
+		} else {
+			// The data file is missing, or was only partially rewritten
+			// from the swap file by an earlier release.
+			err = os.Rename(fname+suffix, fname)
+			exists = true
+		}
+		if err != nil {
+			return found, err
+		}
+	}
+	return found, nil
+}
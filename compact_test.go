@@ -0,0 +1,309 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompact(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	for _, v := range []string{"val", "val2", "val3"} {
+		err = table.Put("a", []byte(v))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	err = table.Put("b", []byte("val"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = table.SetMetadata("schema", "1")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	before := table.Stats()
+	if before.GarbageBytes == 0 {
+		t.Fatalf("got no garbage bytes, wanted some")
+	}
+
+	err = table.Compact()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	after := table.Stats()
+	if after.GarbageBytes != 0 {
+		t.Errorf("got garbage bytes %d, wanted %d", after.GarbageBytes, 0)
+	}
+	if after.FileBytes != before.FileBytes-before.GarbageBytes {
+		t.Errorf("got file bytes %d, wanted %d", after.FileBytes, before.FileBytes-before.GarbageBytes)
+	}
+
+	// Writes after compaction must use the new file positions
+	err = table.Put("b", []byte("val2"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = table.SetMetadata("schema", "2")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Close()
+
+	for _, suffix := range []string{compactSuffix, oldSuffix} {
+		if _, err := os.Stat(tf.Name() + suffix); !os.IsNotExist(err) {
+			t.Errorf("%s file was not removed", suffix)
+		}
+	}
+
+	table2, err := New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table2.Close()
+
+	for k, want := range map[string]string{"a": "val3", "b": "val2"} {
+		v, found := table2.Get(k)
+		if !found {
+			t.Fatalf("got not found for %q, wanted found", k)
+		}
+		if string(v) != want {
+			t.Errorf("got %q, wanted %q", v, want)
+		}
+	}
+	if v, _ := table2.Metadata("schema"); v != "2" {
+		t.Errorf("got metadata %q, wanted %q", v, "2")
+	}
+}
+
+func TestCompactMem(t *testing.T) {
+	table, err := New("", 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+
+	err = table.Compact()
+	if err != nil {
+		t.Errorf("got error %v, wanted nil", err)
+	}
+}
+
+func TestRecoverFiles(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	err = table.Put("a", []byte("val"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Close()
+
+	testCases := []struct {
+		name  string
+		setup func() error
+	}{
+		{
+			name: "interrupted before rename",
+			setup: func() error {
+				return os.WriteFile(tf.Name()+compactSuffix, []byte("partial"), 0o666)
+			},
+		},
+		{
+			name: "interrupted between renames",
+			setup: func() error {
+				return os.Rename(tf.Name(), tf.Name()+oldSuffix)
+			},
+		},
+		{
+			name: "interrupted before removing original",
+			setup: func() error {
+				return os.WriteFile(tf.Name()+oldSuffix, []byte("stale"), 0o666)
+			},
+		},
+		{
+			name: "interrupted legacy swap",
+			setup: func() error {
+				err := os.Rename(tf.Name(), tf.Name()+swapSuffix)
+				if err != nil {
+					return err
+				}
+				return os.WriteFile(tf.Name(), []byte("partial"), 0o666)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.setup()
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+
+			table, err := New(tf.Name(), 50)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			defer table.Close()
+
+			v, found := table.Get("a")
+			if !found {
+				t.Fatalf("got not found, wanted found")
+			}
+			if string(v) != "val" {
+				t.Errorf("got %q, wanted %q", v, "val")
+			}
+
+			for _, suffix := range []string{compactSuffix, oldSuffix, swapSuffix} {
+				if _, err := os.Stat(tf.Name() + suffix); !os.IsNotExist(err) {
+					t.Errorf("%s file was not removed", suffix)
+				}
+			}
+		})
+	}
+}
+
+func TestCompactRewrite(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer os.Remove(tf.Name() + ".idx")
+
+	for k, v := range map[string]string{"a": "v1:a", "b": "v2:b", "tmp:c": "v1:c"} {
+		if err := table.Put(k, []byte(v)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	table.Close()
+
+	// Values are migrated and temporary items dropped when the table is
+	// opened
+	migrate := func(k string, v []byte) ([]byte, bool) {
+		if strings.HasPrefix(k, "tmp:") {
+			return nil, false
+		}
+		if s, ok := strings.CutPrefix(string(v), "v1:"); ok {
+			return []byte("v2:" + s), true
+		}
+		return v, true
+	}
+	table, err = New(tf.Name(), 50, WithRewrite(migrate), WithSearchIndex(nil))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for k, want := range map[string]string{"a": "v2:a", "b": "v2:b"} {
+		if v, _ := table.Get(k); string(v) != want {
+			t.Errorf("got %q, wanted %q", v, want)
+		}
+	}
+	if _, found := table.Get("tmp:c"); found {
+		t.Errorf("got value for dropped item, wanted none")
+	}
+	if got := table.Search("v2"); len(got) != 2 {
+		t.Errorf("got %q from search for new values, wanted a and b", got)
+	}
+	if got := table.Search("v1"); got != nil {
+		t.Errorf("got %q from search for old values, wanted none", got)
+	}
+
+	// Later writes are rewritten by the next compaction
+	if err := table.Put("tmp:d", []byte("v1:d")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.Compact(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if table.Len() != 2 {
+		t.Errorf("got len %d, wanted %d", table.Len(), 2)
+	}
+	table.Close()
+
+	table, err = New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+	if v, _ := table.Get("a"); string(v) != "v2:a" {
+		t.Errorf("got %q after reopening, wanted %q", v, "v2:a")
+	}
+	if table.Len() != 2 {
+		t.Errorf("got len %d after reopening, wanted %d", table.Len(), 2)
+	}
+}
+
+func TestEstimateCompaction(t *testing.T) {
+	tf, err := os.CreateTemp("", "lash")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tf.Close()
+	defer os.Remove(tf.Name())
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	table, err := New(tf.Name(), 50, WithUndeleteWindow(time.Hour), withClock(clock))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+
+	for _, k := range []string{"a", "b", "c", "d"} {
+		if err := table.PutString(k, strings.Repeat(k, 100)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if reclaimable, live := table.EstimateCompaction(); reclaimable != 0 || live != table.Stats().FileBytes {
+		t.Errorf("got estimate %d, %d, wanted %d, %d", reclaimable, live, 0, table.Stats().FileBytes)
+	}
+
+	if err := table.PutString("a", "new"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.Delete("b"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.SoftDelete("c"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.SoftDelete("d"); err != nil {
+		t.Fatal(err.Error())
+	}
+	now = now.Add(30 * time.Minute)
+	if err := table.Undelete("d"); err != nil {
+		t.Fatal(err.Error())
+	}
+	now = now.Add(time.Hour)
+
+	reclaimable, live := table.EstimateCompaction()
+	stats := table.Stats()
+	if reclaimable <= stats.GarbageBytes {
+		t.Errorf("got reclaimable %d, wanted more than garbage %d including expired soft delete", reclaimable, stats.GarbageBytes)
+	}
+	if reclaimable+live != stats.FileBytes {
+		t.Errorf("got estimate %d, %d, wanted it to total file size %d", reclaimable, live, stats.FileBytes)
+	}
+	if err := table.Compact(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if size := table.Stats().FileBytes; size != live {
+		t.Errorf("got file size %d after compaction, wanted estimated %d", size, live)
+	}
+}
@@ -0,0 +1,60 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"github.com/iand/lash/format"
+)
+
+const (
+	dictSamples = 1000     // maximum number of values sampled to train a compression dictionary
+	dictSize    = 64 << 10 // maximum size of a compression dictionary in bytes
+	dictRatio   = 16       // minimum ratio of the size of the sampled values to the size of the dictionary
+)
+
+// WithCompression compresses the values stored in the table's data file
+// using zstd. Each time the data file is compacted a dictionary is trained
+// over a sample of the table's values and stored in the header of the new
+// file, where it is used to compress the values of every later write. A
+// dictionary greatly improves the compression of many small values that
+// share structure, such as JSON documents. Values are held uncompressed in
+// memory. A data file written without compression is compressed when the
+// table is opened, and one written with compression is decompressed when
+// opened without this option.
+func WithCompression() Option {
+	return func(t *Table) {
+		t.compress = true
+	}
+}
+
+// newCodec returns the codec used to compress values in a new data file
+// holding the table's current state, or nil if the table does not use
+// compression. If a dictionary cannot be trained, such as when the table
+// holds too few values, the values are compressed without one.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) newCodec() (*format.Codec, error) {
+	if !t.compress {
+		return nil, nil
+	}
+	samples := make([][]byte, 0, min(len(t.data), dictSamples))
+	sampled := 0
+	for _, p := range t.data {
+		if len(samples) == dictSamples {
+			break
+		}
+		if len(p.val) > 0 {
+			samples = append(samples, p.val)
+			sampled += len(p.val)
+		}
+	}
+	// The dictionary is stored in the data file so it must be small
+	// compared with the values it compresses
+	dict, err := format.TrainDict(samples, min(dictSize, sampled/dictRatio))
+	if err != nil {
+		dict = nil
+	}
+	return format.NewCodec(dict)
+}
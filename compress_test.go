@@ -0,0 +1,138 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iand/lash/format"
+)
+
+func TestCompression(t *testing.T) {
+	dir := t.TempDir()
+	values := make(map[string]string)
+	for i := 0; i < 500; i++ {
+		values[fmt.Sprintf("user%d", i)] = fmt.Sprintf(`{"id":%d,"name":"user%d","email":"user%d@example.com","active":true,"roles":["reader","writer"]}`, i, i, i)
+	}
+
+	sizes := make(map[bool]int64)
+	for _, compress := range []bool{false, true} {
+		fname := filepath.Join(dir, fmt.Sprintf("compress-%v", compress))
+		var opts []Option
+		if compress {
+			opts = append(opts, WithCompression())
+		}
+		table, err := New(fname, 0, opts...)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		for k, v := range values {
+			if err := table.Put(k, []byte(v)); err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+		if err := table.Compact(); err != nil {
+			t.Fatal(err.Error())
+		}
+		// Written using the trained dictionary
+		if err := table.Put("late", []byte(values["user1"])); err != nil {
+			t.Fatal(err.Error())
+		}
+		table.Close()
+
+		fi, err := os.Stat(fname)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		sizes[compress] = fi.Size()
+	}
+	if sizes[true] >= sizes[false]*2/3 {
+		t.Errorf("got compressed size %d, wanted less than two thirds of %d", sizes[true], sizes[false])
+	}
+
+	// The compressed file may be read with or without compression enabled
+	fname := filepath.Join(dir, "compress-true")
+	for _, opts := range [][]Option{{WithReadOnly()}, {WithCompression()}, nil} {
+		table, err := New(fname, 0, opts...)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if table.Len() != len(values)+1 {
+			t.Errorf("got length %d, wanted %d", table.Len(), len(values)+1)
+		}
+		for k, want := range map[string]string{"user1": values["user1"], "user499": values["user499"], "late": values["user1"]} {
+			if v, ok := table.Get(k); !ok || string(v) != want {
+				t.Errorf("got %q, wanted %q", v, want)
+			}
+		}
+		table.Close()
+	}
+
+	// Opening without compression rewrote the file uncompressed
+	f, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	d, err := newDecoder(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if d.codec != nil {
+		t.Errorf("got compression %s, wanted %s", d.codec.Compression(), format.CompressionNone)
+	}
+}
+
+func TestCompressionAttach(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "compressed")
+	table, err := New(fname, 0, WithCompression())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.Put("a", []byte("value a")); err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Close()
+
+	a, err := AttachSnapshot(fname)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer a.Close()
+	if v, ok := a.Get("a"); !ok || string(v) != "value a" {
+		t.Errorf("got %q, wanted %q", v, "value a")
+	}
+}
+
+func TestCompressionGarbage(t *testing.T) {
+	table, err := New(filepath.Join(t.TempDir(), "data"), 0, WithCompression())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+	for i := 0; i < 100; i++ {
+		if err := table.Put(fmt.Sprintf("user%d", i), fmt.Appendf(nil, `{"id":%d,"name":"user%d"}`, i, i)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := table.Compact(); err != nil {
+		t.Fatal(err.Error())
+	}
+	for i := 0; i < 100; i++ {
+		if err := table.Delete(fmt.Sprintf("user%d", i)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	// Only the header remains live once every compressed record is deleted
+	hdr := int64(len(appendHeader(nil, table.checksum, table.codec)))
+	if s := table.Stats(); s.FileBytes-s.GarbageBytes != hdr {
+		t.Errorf("got live bytes %d, wanted %d", s.FileBytes-s.GarbageBytes, hdr)
+	}
+}
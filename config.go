@@ -0,0 +1,211 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// BindConfig fills the exported fields of the struct pointed to by dst from
+// the values of keys beginning with prefix, giving an application its
+// configuration from a table. Each field is read from the key formed by
+// appending its name, or the name given by a `lash:"name"` field tag, to
+// prefix; fields tagged `lash:"-"` are skipped. Values are parsed from text
+// for fields of string, boolean and numeric types, by time.ParseDuration
+// for time.Duration fields and by UnmarshalText for fields that implement
+// encoding.TextUnmarshaler. []byte fields receive the value unchanged and
+// fields of any other type are decoded from JSON. The values dst holds when
+// it is bound are kept as defaults for fields whose keys are not present.
+//
+// If the table was opened using WithWatch then dst is bound again each time
+// the table is reloaded, so the application sees configuration changes made
+// by another process. Code that reads dst concurrently with reloads must do
+// so within the Read method of the returned Binding. A reload is applied in
+// full or not at all; errors are passed to the function given to WithWatch
+// and leave dst unchanged.
+func (t *Table) BindConfig(prefix string, dst any) (*Binding, error) {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("lash: config must be bound to a pointer to a struct")
+	}
+	b := &Binding{t: t, prefix: prefix, dst: rv.Elem()}
+	b.defaults = reflect.New(b.dst.Type()).Elem()
+	b.defaults.Set(b.dst)
+	if err := b.Refresh(); err != nil {
+		return nil, err
+	}
+
+	t.mtx.Lock()
+	t.bindings = append(t.bindings, b)
+	t.mtx.Unlock()
+	return b, nil
+}
+
+// A Binding is a struct bound to a table's configuration by BindConfig.
+type Binding struct {
+	t        *Table
+	prefix   string
+	dst      reflect.Value // struct being bound
+	defaults reflect.Value // copy of the struct taken when it was bound
+
+	mu sync.RWMutex // guards dst while it is bound
+}
+
+// Read calls fn, which may read the bound struct, while preventing it from
+// being bound again.
+func (b *Binding) Read(fn func()) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	fn()
+}
+
+// Refresh binds the struct again from the current contents of the table.
+// If an error occurs then the struct is unchanged.
+func (b *Binding) Refresh() error {
+	v := reflect.New(b.dst.Type()).Elem()
+	v.Set(b.defaults)
+
+	t := b.t
+	t.mtx.RLock()
+	err := b.fill(v)
+	t.mtx.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.dst.Set(v)
+	b.mu.Unlock()
+	return nil
+}
+
+// Unbind stops the struct from being bound again when the table is
+// reloaded.
+func (b *Binding) Unbind() {
+	t := b.t
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	for i, other := range t.bindings {
+		if other == b {
+			t.bindings = append(t.bindings[:i], t.bindings[i+1:]...)
+			return
+		}
+	}
+}
+
+// fill sets the fields of the struct v from the values of their keys.
+// It is the responsibility of the caller to acquire locks.
+func (b *Binding) fill(v reflect.Value) error {
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("lash"); ok {
+			if tag == "-" {
+				continue
+			}
+			name = tag
+		}
+
+		k := b.prefix + name
+		p, found := b.t.data[k]
+		if !found || p.deleted != 0 {
+			continue
+		}
+		val, err := b.t.value(p)
+		if err != nil {
+			return err
+		}
+		if err := setConfigField(v.Field(i), val); err != nil {
+			return fmt.Errorf("lash: config key %q: %w", b.t.redactKey(k), err)
+		}
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setConfigField sets the struct field f from the value val as described by
+// BindConfig.
+func setConfigField(f reflect.Value, val []byte) error {
+	if u, ok := f.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText(val)
+	}
+	if f.Type() == durationType {
+		d, err := time.ParseDuration(string(val))
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(d))
+		return nil
+	}
+
+	s := string(val)
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(s)
+	case reflect.Bool:
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.SetBool(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(s, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(s, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetUint(v)
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(s, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetFloat(v)
+	default:
+		if f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.Uint8 {
+			f.SetBytes(append([]byte(nil), val...))
+			return nil
+		}
+		// Decode into a fresh value so that fields of the default are not
+		// merged with the new value
+		nv := reflect.New(f.Type())
+		if err := json.Unmarshal(val, nv.Interface()); err != nil {
+			return err
+		}
+		f.Set(nv.Elem())
+	}
+	return nil
+}
+
+// rebind binds the structs bound to the table again after it has been
+// reloaded, passing any errors to report.
+func (t *Table) rebind(report func(error)) {
+	t.mtx.RLock()
+	bindings := append([]*Binding(nil), t.bindings...)
+	t.mtx.RUnlock()
+	for _, b := range bindings {
+		if err := b.Refresh(); err != nil {
+			report(err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"net/netip"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testConfig struct {
+	Name    string
+	Port    int
+	Debug   bool
+	Ratio   float64
+	Timeout time.Duration
+	Addr    netip.Addr
+	Tags    []string
+	Secret  string `lash:"secret_key"`
+	Skipped string `lash:"-"`
+}
+
+func TestBindConfig(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	values := map[string]string{
+		"app.Name":       "server",
+		"app.Port":       "8080",
+		"app.Debug":      "true",
+		"app.Timeout":    "3s",
+		"app.Addr":       "10.0.0.1",
+		"app.Tags":       `["a","b"]`,
+		"app.secret_key": "hunter2",
+		"app.Skipped":    "x",
+		"other.Name":     "other",
+	}
+	for k, v := range values {
+		if err := table.PutString(k, v); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	c := testConfig{Ratio: 0.5, Skipped: "default"}
+	if _, err := table.BindConfig("app.", &c); err != nil {
+		t.Fatal(err.Error())
+	}
+	want := testConfig{
+		Name:    "server",
+		Port:    8080,
+		Debug:   true,
+		Ratio:   0.5,
+		Timeout: 3 * time.Second,
+		Addr:    netip.MustParseAddr("10.0.0.1"),
+		Tags:    []string{"a", "b"},
+		Secret:  "hunter2",
+		Skipped: "default",
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("got %+v, wanted %+v", c, want)
+	}
+
+	if err := table.PutString("app.Port", "http"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := table.BindConfig("app.", &c); err == nil {
+		t.Errorf("got no error for invalid value, wanted error")
+	}
+	if _, err := table.BindConfig("app.", c); err == nil {
+		t.Errorf("got no error binding a struct that is not a pointer, wanted error")
+	}
+}
+
+func TestBindConfigWatch(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	if err := table.PutString("Port", "80"); err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Close()
+
+	ro, err := New(tf.Name(), 50, WithReadOnly(), WithWatch(func(err error) { t.Logf("watch error: %v", err) }))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer ro.Close()
+
+	c := testConfig{Name: "default"}
+	b, err := ro.BindConfig("", &c)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Port != 80 {
+		t.Errorf("got port %d, wanted %d", c.Port, 80)
+	}
+
+	// Reopening the table for writing rewrites the data file
+	table, err = New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.PutString("Name", "server"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.Delete("Port"); err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Close()
+	table, err = New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Close()
+
+	var name string
+	var port int
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		b.Read(func() { name, port = c.Name, c.Port })
+		if name == "server" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if name != "server" {
+		t.Errorf("got name %q after reload, wanted %q", name, "server")
+	}
+	if port != 0 {
+		t.Errorf("got port %d after key was deleted, wanted default %d", port, 0)
+	}
+}
@@ -0,0 +1,155 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// ErrNotCounter is returned when a counter operation is applied to a value
+// that is not a counter.
+var ErrNotCounter = errors.New("lash: value is not a counter")
+
+// Counters returns a view of the table that holds integer counters. The
+// view shares its data with the table.
+func (t *Table) Counters() Counters {
+	return Counters{t: t}
+}
+
+// Counters is a view of a Table that holds int64 counters. Incrementing a
+// counter appends a small delta record to the data file rather than
+// rewriting the counter, which makes frequent increments cheap. The deltas
+// are folded into the counter when the table is loaded and when the data
+// file is compacted. Counters are stored as varints so they should be read
+// using the view rather than Get.
+type Counters struct {
+	t *Table
+}
+
+// Get returns the value of the counter stored under key k along with a
+// boolean that indicates whether a counter was found in the table or not.
+func (c Counters) Get(k string) (int64, bool) {
+	v, found := c.t.Get(k)
+	if !found {
+		return 0, false
+	}
+	n, err := counterValue(v)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// Set stores a counter with the value n under key k, replacing any existing
+// value.
+func (c Counters) Set(k string, n int64) error {
+	return c.t.Put(k, binary.AppendVarint(nil, n))
+}
+
+// Incr adds delta to the counter stored under key k and returns its new
+// value. If there is no counter under k then one is created with the value
+// delta. It returns ErrNotCounter if k holds a value that is not a counter.
+func (c Counters) Incr(k string, delta int64) (int64, error) {
+	return c.t.incr(k, delta)
+}
+
+// Table returns the table underlying the view.
+func (c Counters) Table() *Table {
+	return c.t
+}
+
+// incr adds delta to the counter stored under key k by writing a delta
+// record and returns the counter's new value.
+func (t *Table) incr(k string, delta int64) (int64, error) {
+	t.putLimit.wait()
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.incrCounter(k, delta)
+}
+
+// incrCounter adds delta to the counter stored under key k and returns the
+// counter's new value.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) incrCounter(k string, delta int64) (int64, error) {
+	cur, exists := t.data[k]
+	if !exists || cur.deleted != 0 {
+		return delta, t.put(k, binary.AppendVarint(nil, delta), 0)
+	}
+	v, err := t.value(cur)
+	if err != nil {
+		return 0, err
+	}
+	n, err := counterValue(v)
+	if err != nil {
+		return 0, err
+	}
+
+	err = t.writeDelta(k, cur, kindDelta, appendDelta(nil, cur.seq, delta), binary.AppendVarint(nil, n+delta))
+	if err != nil {
+		return 0, err
+	}
+	return n + delta, nil
+}
+
+// loadDelta applies a delta record, occupying size bytes in the data file,
+// to the counter it increments. Delta records for counters that have since
+// been replaced or deleted are counted as garbage.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) loadDelta(rec record, size int64) error {
+	base, delta, err := decodeDelta(rec.val)
+	if err != nil {
+		return err
+	}
+	cur, exists := t.data[rec.key]
+	if !exists || cur.deleted != 0 || cur.seq != base {
+		t.garbage += size
+		return nil
+	}
+	v, err := t.value(cur)
+	if err != nil {
+		return err
+	}
+	n, err := counterValue(v)
+	if err != nil {
+		t.garbage += size
+		return nil
+	}
+	t.fold(&cur, rec, binary.AppendVarint(nil, n+delta), size)
+	t.data[rec.key] = cur
+	return nil
+}
+
+// counterValue decodes the value of a counter.
+func counterValue(v []byte) (int64, error) {
+	n, l := binary.Varint(v)
+	if l <= 0 || l != len(v) {
+		return 0, ErrNotCounter
+	}
+	return n, nil
+}
+
+// appendDelta appends the value of a delta record to buf. The value holds
+// the sequence number of the write that stored the counter, so that deltas
+// are not applied to a later value stored under the same key, followed by
+// the delta itself.
+func appendDelta(buf []byte, base uint64, delta int64) []byte {
+	buf = binary.AppendUvarint(buf, base)
+	return binary.AppendVarint(buf, delta)
+}
+
+// decodeDelta decodes the value of a delta record.
+func decodeDelta(v []byte) (uint64, int64, error) {
+	base, n := binary.Uvarint(v)
+	if n <= 0 {
+		return 0, 0, ErrCorrupt
+	}
+	delta, m := binary.Varint(v[n:])
+	if m <= 0 || n+m != len(v) {
+		return 0, 0, ErrCorrupt
+	}
+	return base, delta, nil
+}
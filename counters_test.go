@@ -0,0 +1,86 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCounters(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	c := table.Counters()
+	for i := 0; i < 10; i++ {
+		if _, err := c.Incr("hits", 2); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	n, err := c.Incr("hits", -5)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if n != 15 {
+		t.Errorf("got %d, wanted %d", n, 15)
+	}
+	c.Incr("misses", 1)
+	c.Incr("replaced", 10)
+	c.Set("replaced", 100)
+	c.Incr("replaced", 1)
+	table.PutString("name", "value")
+	if _, err := c.Incr("name", 1); err != ErrNotCounter {
+		t.Errorf("got error %v, wanted %v", err, ErrNotCounter)
+	}
+	m, _ := table.GetMeta("hits")
+	if m.Writes != 11 {
+		t.Errorf("got writes %d, wanted %d", m.Writes, 11)
+	}
+
+	s := table.Stats()
+	live := s.FileBytes - s.GarbageBytes
+	table.Close()
+
+	table2, err := New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table2.Close()
+	c2 := table2.Counters()
+	for k, want := range map[string]int64{"hits": 15, "misses": 1, "replaced": 101} {
+		if n, _ := c2.Get(k); n != want {
+			t.Errorf("%s: got %d, wanted %d", k, n, want)
+		}
+	}
+	if _, found := c2.Get("name"); found {
+		t.Errorf("got counter for name, wanted not found")
+	}
+	m2, _ := table2.GetMeta("hits")
+	if m2 != m {
+		t.Errorf("got meta %+v, wanted %+v", m2, m)
+	}
+
+	// Deltas were folded when the table was loaded and compacted
+	if s := table2.Stats(); s.FileBytes != live || s.GarbageBytes != 0 {
+		t.Errorf("got file bytes %d and garbage bytes %d, wanted %d and %d", s.FileBytes, s.GarbageBytes, live, 0)
+	}
+	c2.Incr("hits", 5)
+	table2.Delete("misses")
+	s = table2.Stats()
+	live = s.FileBytes - s.GarbageBytes
+	if err := table2.Compact(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s := table2.Stats(); s.FileBytes != live {
+		t.Errorf("got file bytes %d, wanted %d", s.FileBytes, live)
+	}
+	if n, _ := c2.Get("hits"); n != 20 {
+		t.Errorf("got %d, wanted %d", n, 20)
+	}
+}
@@ -0,0 +1,124 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"io"
+	"sort"
+)
+
+// The header row of a CSV export names the columns holding the key and the
+// value. The name of the value column records how values are encoded.
+const (
+	csvKeyColumn    = "key"
+	csvValueColumn  = "value"        // values written as strings
+	csvBase64Column = "value_base64" // values written in standard base64
+)
+
+// ExportCSV writes the table's items to w as CSV, with a header row followed
+// by one row for each item holding its key and value, in key order. Soft
+// deleted items are not included. If valueAsString is true then values are
+// written as strings, which is convenient for editing in a spreadsheet but
+// only suitable for values that are text. Otherwise values are written in
+// base64. Table metadata and the metadata of items are not exported.
+func (t *Table) ExportCSV(w io.Writer, valueAsString bool) error {
+	t.mtx.RLock()
+	keys := make([]string, 0, len(t.data)-t.trashed)
+	vals := make(map[string][]byte, len(t.data)-t.trashed)
+	for k, p := range t.data {
+		if p.deleted != 0 {
+			continue
+		}
+		v, err := t.value(p)
+		if err != nil {
+			t.mtx.RUnlock()
+			return err
+		}
+		keys = append(keys, k)
+		vals[k] = v
+	}
+	t.mtx.RUnlock()
+	sort.Strings(keys)
+
+	cw := csv.NewWriter(w)
+	column := csvBase64Column
+	if valueAsString {
+		column = csvValueColumn
+	}
+	if err := cw.Write([]string{csvKeyColumn, column}); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		v := string(vals[k])
+		if !valueAsString {
+			v = base64.StdEncoding.EncodeToString(vals[k])
+		}
+		if err := cw.Write([]string{k, v}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ImportCSV reads CSV written by ExportCSV from r and stores each item in
+// the table, replacing any existing values stored under the same keys. The
+// header row determines whether values are read as strings or as base64.
+// The items are stored using BulkLoad so if any row is invalid then none of
+// the items are stored.
+func (t *Table) ImportCSV(r io.Reader) error {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err != nil {
+		if err == io.EOF {
+			return errors.New("lash: missing CSV header")
+		}
+		return err
+	}
+	if len(header) != 2 || header[0] != csvKeyColumn || header[1] != csvValueColumn && header[1] != csvBase64Column {
+		return errors.New("lash: unrecognised CSV header")
+	}
+	return t.BulkLoad(context.Background(), &csvIterator{r: cr, base64: header[1] == csvBase64Column}, 1)
+}
+
+// csvIterator supplies the items held in rows of CSV.
+type csvIterator struct {
+	r      *csv.Reader
+	base64 bool
+	key    string
+	val    []byte
+	err    error
+}
+
+func (c *csvIterator) Next() bool {
+	row, err := c.r.Read()
+	if err != nil {
+		if err != io.EOF {
+			c.err = err
+		}
+		return false
+	}
+	c.key = row[0]
+	if !c.base64 {
+		c.val = []byte(row[1])
+		return true
+	}
+	c.val, err = base64.StdEncoding.DecodeString(row[1])
+	if err != nil {
+		c.err = err
+		return false
+	}
+	return true
+}
+
+func (c *csvIterator) Key() string   { return c.key }
+func (c *csvIterator) Value() []byte { return c.val }
+func (c *csvIterator) Err() error    { return c.err }
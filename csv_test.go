@@ -0,0 +1,96 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	for _, asString := range []bool{true, false} {
+		src, err := New("", 50)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		src.PutString("b", "two, with comma")
+		src.PutString("a", "one\nline \"quoted\"")
+		src.Put("c", []byte{0, 1, 2})
+		src.PutString("d", "gone")
+		src.SoftDelete("d")
+
+		var buf bytes.Buffer
+		if err := src.ExportCSV(&buf, asString); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		dst, err := New("", 50)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		dst.PutString("a", "old")
+		if err := dst.ImportCSV(&buf); err != nil {
+			t.Fatal(err.Error())
+		}
+		if dst.Len() != 3 {
+			t.Errorf("got len %d, wanted %d", dst.Len(), 3)
+		}
+		for _, k := range []string{"a", "b", "c"} {
+			want, _ := src.Get(k)
+			got, found := dst.Get(k)
+			if !found {
+				t.Fatalf("got not found for %q, wanted found", k)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("got %q, wanted %q", got, want)
+			}
+		}
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	table, err := New("", 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table.PutString("b", "2")
+	table.PutString("a", "1")
+
+	var buf bytes.Buffer
+	if err := table.ExportCSV(&buf, true); err != nil {
+		t.Fatal(err.Error())
+	}
+	if got, want := buf.String(), "key,value\na,1\nb,2\n"; got != want {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+}
+
+func TestImportCSVInvalid(t *testing.T) {
+	testCases := []struct {
+		name string
+		data string
+	}{
+		{name: "empty", data: ""},
+		{name: "unknown header", data: "k,v\na,1\n"},
+		{name: "bad base64", data: "key,value_base64\na,MQ==\nb,!!\n"},
+		{name: "wrong field count", data: "key,value\na,1\nb,2,3\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			table, err := New("", 50)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if err := table.ImportCSV(strings.NewReader(tc.data)); err == nil {
+				t.Errorf("got no error, wanted one")
+			}
+			if table.Len() != 0 {
+				t.Errorf("got len %d, wanted %d", table.Len(), 0)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultSampleKeys is the number of keys listed by the debug handler
+// when the request does not specify a number, and maxSampleKeys is the most
+// that it lists.
+const (
+	defaultSampleKeys = 20
+	maxSampleKeys     = 1000
+)
+
+type debugInfo struct {
+	Filename           string
+	Stats              Stats
+	GarbageRatio       float64
+	WriteAmplification float64
+	SampleKeys         []string
+}
+
+// DebugHandler returns an http.Handler that reports the table's statistics,
+// garbage ratio, recent compaction history and a sample of its keys. It is
+// intended to be mounted under a path such as /debug/lash in an existing
+// server. The report is served as JSON unless the request accepts HTML or
+// includes the query parameter format=html. The number of sampled keys
+// may be set with the keys query parameter, up to a maximum of 1000.
+func (t *Table) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := defaultSampleKeys
+		if s := r.URL.Query().Get("keys"); s != "" {
+			v, err := strconv.Atoi(s)
+			if err != nil || v < 0 {
+				http.Error(w, "invalid value for keys parameter", http.StatusBadRequest)
+				return
+			}
+			n = min(v, maxSampleKeys)
+		}
+
+		stats := t.Stats()
+		info := debugInfo{
+			Filename:           t.filename,
+			Stats:              stats,
+			GarbageRatio:       stats.GarbageRatio(),
+			WriteAmplification: stats.WriteAmplification(),
+			SampleKeys:         t.sampleKeys(n),
+		}
+
+		if r.URL.Query().Get("format") == "html" || strings.Contains(r.Header.Get("Accept"), "text/html") {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if err := debugTemplate.Execute(w, info); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(info); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// sampleKeys returns up to n keys from the table, excluding soft deleted
+// items. The keys are chosen in map iteration order which is unspecified
+// and varies between calls.
+func (t *Table) sampleKeys(n int) []string {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	keys := make([]string, 0, min(n, len(t.data)))
+	for k, p := range t.data {
+		if len(keys) >= n {
+			break
+		}
+		if p.deleted != 0 {
+			continue
+		}
+		keys = append(keys, t.redactKey(k))
+	}
+	return keys
+}
+
+var debugTemplate = template.Must(template.New("debug").Parse(`<!DOCTYPE html>
+<html>
+<head><title>lash {{.Filename}}</title></head>
+<body>
+<h1>lash table {{.Filename}}</h1>
+<h2>Statistics</h2>
+<table>
+<tr><td>Keys</td><td>{{.Stats.Keys}}</td></tr>
+<tr><td>Soft deleted</td><td>{{.Stats.SoftDeleted}}</td></tr>
+<tr><td>File bytes</td><td>{{.Stats.FileBytes}}</td></tr>
+<tr><td>Garbage bytes</td><td>{{.Stats.GarbageBytes}}</td></tr>
+<tr><td>Garbage ratio</td><td>{{printf "%.3f" .GarbageRatio}}</td></tr>
+<tr><td>Bytes written</td><td>{{.Stats.BytesWritten}}</td></tr>
+<tr><td>Compaction bytes written</td><td>{{.Stats.CompactionBytesWritten}}</td></tr>
+<tr><td>Logical bytes put</td><td>{{.Stats.LogicalBytesPut}}</td></tr>
+<tr><td>Write amplification</td><td>{{printf "%.3f" .WriteAmplification}}</td></tr>
+</table>
+<h2>Compactions</h2>
+<table>
+<tr><th>Time</th><th>Duration</th><th>Bytes before</th><th>Bytes after</th></tr>
+{{range .Stats.Compactions}}<tr><td>{{.Time}}</td><td>{{.Duration}}</td><td>{{.BytesBefore}}</td><td>{{.BytesAfter}}</td></tr>
+{{end}}</table>
+<h2>Sample keys</h2>
+<ul>
+{{range .SampleKeys}}<li>{{printf "%q" .}}</li>
+{{end}}</ul>
+</body>
+</html>
+`))
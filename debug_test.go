@@ -0,0 +1,75 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugHandler(t *testing.T) {
+	table, err := New("", 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+
+	for _, k := range []string{"a", "b", "c"} {
+		err = table.Put(k, []byte("val"))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	table.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/lash?keys=2", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, wanted %d", rec.Code, http.StatusOK)
+	}
+
+	var info debugInfo
+	err = json.Unmarshal(rec.Body.Bytes(), &info)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if info.Stats.Keys != 3 {
+		t.Errorf("got keys %d, wanted %d", info.Stats.Keys, 3)
+	}
+	if len(info.SampleKeys) != 2 {
+		t.Errorf("got %d sample keys, wanted %d", len(info.SampleKeys), 2)
+	}
+
+	rec = httptest.NewRecorder()
+	table.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/lash?format=html", nil))
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("got content type %q, wanted html", rec.Header().Get("Content-Type"))
+	}
+
+	for _, q := range []string{"x", "-1"} {
+		rec = httptest.NewRecorder()
+		table.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/lash?keys="+q, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("keys=%s: got status %d, wanted %d", q, rec.Code, http.StatusBadRequest)
+		}
+	}
+
+	// Large numbers of keys are limited rather than allocated
+	rec = httptest.NewRecorder()
+	table.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/lash?keys=1000000000000", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, wanted %d", rec.Code, http.StatusOK)
+	}
+	info = debugInfo{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(info.SampleKeys) != 3 {
+		t.Errorf("got %d sample keys, wanted %d", len(info.SampleKeys), 3)
+	}
+}
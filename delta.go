@@ -0,0 +1,78 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+// Values such as counters, sets and lists are modified by appending a small
+// delta record describing the change rather than rewriting the whole value.
+// Each delta record holds the sequence number of the write that stored the
+// value it modifies so that it is not applied to a later value stored under
+// the same key. Deltas are folded into the value when the table is loaded
+// and the data file is compacted.
+
+// writeDelta writes a delta record of the given kind holding val, which
+// changes the value of the live item cur stored under key k to v.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) writeDelta(k string, cur item, kind byte, val []byte, v []byte) error {
+	if cur.cold {
+		// A delta cannot refer to a record in the cold file since that
+		// file may be rewritten without the record before the data file is
+		// compacted
+		return t.put(k, v, 0)
+	}
+	if err := t.checkPut(k, v); err != nil {
+		return err
+	}
+	rec := t.deltaRecord(k, cur, kind, val)
+	if _, err := t.write(rec); err != nil {
+		return err
+	}
+
+	old := cur
+	t.fold(&cur, rec, v, recordSize(rec, t.checksum))
+	t.data[k] = cur
+	t.logical += int64(len(k) + len(rec.val))
+	t.unindexItem(k, old)
+	t.indexValue(k, cur.val)
+	t.mirrorValue(k, cur.val)
+	return nil
+}
+
+// deltaRecord returns a delta record of the given kind holding val, which
+// modifies the value of the live item cur stored under key k.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) deltaRecord(k string, cur item, kind byte, val []byte) record {
+	return record{
+		kind:    kind,
+		key:     k,
+		val:     val,
+		created: cur.created,
+		updated: t.now().UnixNano(),
+		writes:  cur.writes + 1,
+		seq:     t.nextSeq(),
+	}
+}
+
+// fold updates the item p to hold the value v following the delta record
+// rec, which occupies size bytes in the data file. Once folded, the delta
+// record is counted as garbage since compaction will replace it and the
+// item's record with a single record holding the new value.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) fold(p *item, rec record, v []byte, size int64) {
+	before := t.itemSize(rec.key, *p)
+	p.val, p.coll = v, nil
+	t.folded(p, rec, before, size)
+}
+
+// folded completes folding the delta record rec, which occupies size bytes
+// in the data file, into the item p once p holds the new value. before is
+// the size of the item's record before the value changed.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) folded(p *item, rec record, before, size int64) {
+	p.size, p.onDisk = 0, false
+	p.updated = rec.updated
+	p.writes = rec.writes
+	t.garbage += before + size - t.itemSize(rec.key, *p)
+}
@@ -0,0 +1,91 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrDiskFull is returned by methods that modify the table once a write to
+// the data file has failed because the disk is full. The table stops
+// accepting changes until it has been compacted successfully, which may
+// free enough space to continue.
+var ErrDiskFull = errors.New("lash: disk full")
+
+// reserveSuffix is appended to the name of the data file to form the name of
+// the file holding the space reserved by WithDiskReserve.
+const reserveSuffix = ".reserve"
+
+// WithDiskReserve reserves n bytes of disk space for the table by writing a
+// file of that size alongside the data file. If the disk becomes full then
+// the file is removed so that the space it occupied is available to Compact
+// to free space, and it is written again once compaction succeeds.
+func WithDiskReserve(n int64) Option {
+	return func(t *Table) {
+		t.reserve = n
+	}
+}
+
+// diskFull stops the table accepting changes after writing to the data file
+// failed because the disk is full, discarding any part of a record that was
+// written at pos, and releases the table's disk reserve. It returns
+// ErrDiskFull.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) diskFull(pos int64) error {
+	if t.size > pos {
+		if err := t.dbfile.Truncate(pos); err == nil {
+			t.size = pos
+		}
+	}
+	if !t.full {
+		t.logger.Error("lash: disk full, table will not accept changes until compacted", "file", t.filename)
+	}
+	t.full = true
+	if t.reserve > 0 {
+		os.Remove(t.filename + reserveSuffix)
+	}
+	return ErrDiskFull
+}
+
+// writeReserve writes the file holding the table's disk reserve, if it has
+// one, unless it already exists with the reserved size.
+func (t *Table) writeReserve() error {
+	if t.reserve <= 0 || t.filename == "" {
+		return nil
+	}
+	fname := t.filename + reserveSuffix
+	if fi, err := os.Stat(fname); err == nil && fi.Size() == t.reserve {
+		return nil
+	}
+
+	f, err := os.OpenFile(fname, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(0666))
+	if err != nil {
+		return err
+	}
+	// Zeros are written rather than the file being truncated to size so
+	// that the space is allocated
+	_, err = io.CopyN(f, zeroReader{}, t.reserve)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(fname)
+	}
+	return err
+}
+
+// zeroReader is an io.Reader that reads an endless stream of zero bytes.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
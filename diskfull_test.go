@@ -0,0 +1,79 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDiskFull(t *testing.T) {
+	// Writes to /dev/full fail as though the disk were full
+	full, err := os.OpenFile("/dev/full", os.O_RDWR, 0)
+	if err != nil {
+		t.Skip("/dev/full is not available")
+	}
+	defer full.Close()
+
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Close()
+	defer os.Remove(tf.Name())
+	defer os.Remove(tf.Name() + reserveSuffix)
+
+	table, err = New(tf.Name(), 50, WithDiskReserve(4096))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+	if fi, err := os.Stat(tf.Name() + reserveSuffix); err != nil || fi.Size() != 4096 {
+		t.Fatalf("got reserve %v, %v, wanted %d bytes", fi, err, 4096)
+	}
+	if err := table.Put("a", []byte("value")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.mtx.Lock()
+	dbfile := table.dbfile
+	table.dbfile = full
+	table.mtx.Unlock()
+
+	if err := table.Put("b", []byte("value")); err != ErrDiskFull {
+		t.Errorf("got error %v, wanted %v", err, ErrDiskFull)
+	}
+	if !table.Stats().DiskFull {
+		t.Errorf("got disk full not reported, wanted it reported")
+	}
+	if _, err := os.Stat(tf.Name() + reserveSuffix); !os.IsNotExist(err) {
+		t.Errorf("got reserve kept, wanted it released")
+	}
+
+	// Changes are refused even once space is available
+	table.mtx.Lock()
+	table.dbfile = dbfile
+	table.mtx.Unlock()
+	if err := table.Delete("a"); err != ErrDiskFull {
+		t.Errorf("got error %v, wanted %v", err, ErrDiskFull)
+	}
+	if v, _ := table.Get("a"); string(v) != "value" {
+		t.Errorf("got %q, wanted %q", v, "value")
+	}
+
+	if err := table.Compact(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if table.Stats().DiskFull {
+		t.Errorf("got disk full after compaction, wanted it cleared")
+	}
+	if _, err := os.Stat(tf.Name() + reserveSuffix); err != nil {
+		t.Errorf("got error %v for reserve, wanted it written again", err)
+	}
+	if err := table.Put("b", []byte("value")); err != nil {
+		t.Fatal(err.Error())
+	}
+}
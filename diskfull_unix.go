@@ -0,0 +1,18 @@
+//go:build !windows
+
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isDiskFull reports whether err was caused by the disk being full.
+func isDiskFull(err error) bool {
+	return errors.Is(err, syscall.ENOSPC)
+}
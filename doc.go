@@ -0,0 +1,132 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Doc returns a handle to the JSON document stored under key k. The
+// document need not exist until a field is set.
+func (t *Table) Doc(k string) Doc {
+	return Doc{t: t, k: k}
+}
+
+// Doc provides access to the fields of a JSON document stored in a table.
+// Fields are addressed by a path of names separated by dots, such as
+// "address.city". Array elements are addressed by their index, so
+// "phones.0" refers to the first element of the phones array. An empty path
+// refers to the whole document.
+type Doc struct {
+	t *Table
+	k string
+}
+
+// Key returns the key under which the document is stored.
+func (d Doc) Key() string {
+	return d.k
+}
+
+// GetField returns the value of the field at path, decoded in the same way
+// as json.Unmarshal decodes into an interface value. It returns ErrNotFound
+// if the document or the field does not exist.
+func (d Doc) GetField(path string) (any, error) {
+	v, found := d.t.Get(d.k)
+	if !found {
+		return nil, ErrNotFound
+	}
+	var doc any
+	if err := json.Unmarshal(v, &doc); err != nil {
+		return nil, err
+	}
+
+	for _, name := range splitPath(path) {
+		switch c := doc.(type) {
+		case map[string]any:
+			var ok bool
+			doc, ok = c[name]
+			if !ok {
+				return nil, ErrNotFound
+			}
+		case []any:
+			i, err := strconv.Atoi(name)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, ErrNotFound
+			}
+			doc = c[i]
+		default:
+			return nil, ErrNotFound
+		}
+	}
+	return doc, nil
+}
+
+// SetField sets the field at path to value, which is encoded using
+// json.Marshal, and writes the updated document to persistent storage. The
+// document is read, modified and written atomically with respect to other
+// changes to the table. Objects are created as needed to hold the field,
+// including the document itself if it does not exist.
+func (d Doc) SetField(path string, value any) error {
+	return d.t.update(d.k, func(v []byte, found bool) ([]byte, error) {
+		var doc any
+		if found {
+			if err := json.Unmarshal(v, &doc); err != nil {
+				return nil, err
+			}
+		}
+		doc, err := setField(doc, splitPath(path), value)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(doc)
+	})
+}
+
+// setField returns doc with the field at the path formed from names set to
+// value.
+func setField(doc any, names []string, value any) (any, error) {
+	if len(names) == 0 {
+		return value, nil
+	}
+	switch c := doc.(type) {
+	case nil:
+		child, err := setField(nil, names[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{names[0]: child}, nil
+	case map[string]any:
+		child, err := setField(c[names[0]], names[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		c[names[0]] = child
+		return c, nil
+	case []any:
+		i, err := strconv.Atoi(names[0])
+		if err != nil || i < 0 || i >= len(c) {
+			return nil, fmt.Errorf("lash: invalid array index %q", names[0])
+		}
+		c[i], err = setField(c[i], names[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	default:
+		return nil, fmt.Errorf("lash: cannot set field %q of a %T", names[0], doc)
+	}
+}
+
+// splitPath splits a field path into its component names.
+func splitPath(path string) []string {
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
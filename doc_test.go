@@ -0,0 +1,103 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestDoc(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	d := table.Doc("user")
+	if _, err := d.GetField("name"); err != ErrNotFound {
+		t.Errorf("got error %v, wanted %v", err, ErrNotFound)
+	}
+
+	if err := d.SetField("name", "alice"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := d.SetField("address.city", "Paris"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := d.SetField("phones", []string{"123", "456"}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := d.SetField("phones.1", "789"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	v, _ := table.GetString("user")
+	wanted := `{"address":{"city":"Paris"},"name":"alice","phones":["123","789"]}`
+	if v != wanted {
+		t.Errorf("got %q, wanted %q", v, wanted)
+	}
+
+	f, err := d.GetField("address.city")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if f != "Paris" {
+		t.Errorf("got %q, wanted %q", f, "Paris")
+	}
+	f, err = d.GetField("phones.0")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if f != "123" {
+		t.Errorf("got %q, wanted %q", f, "123")
+	}
+	if _, err := d.GetField("phones.2"); err != ErrNotFound {
+		t.Errorf("got error %v, wanted %v", err, ErrNotFound)
+	}
+
+	if err := d.SetField("name.first", "alice"); err == nil {
+		t.Errorf("got nil error setting field of a string, wanted error")
+	}
+	if err := d.SetField("phones.5", "0"); err == nil {
+		t.Errorf("got nil error setting out of range index, wanted error")
+	}
+	v2, _ := table.GetString("user")
+	if v2 != v {
+		t.Errorf("got %q after failed update, wanted %q", v2, v)
+	}
+}
+
+func TestDocConcurrentSetField(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	d := table.Doc("doc")
+	names := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if err := d.SetField(name, 1); err != nil {
+				t.Error(err.Error())
+			}
+		}(name)
+	}
+	wg.Wait()
+
+	for _, name := range names {
+		if _, err := d.GetField(name); err != nil {
+			t.Errorf("got error %v for field %s, wanted nil", err, name)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"time"
+)
+
+// A SyncPolicy determines when writes to a table are committed to stable
+// storage using fsync.
+type SyncPolicy int
+
+const (
+	// SyncAlways commits each write to stable storage before the call that
+	// made it returns. It is the default.
+	SyncAlways SyncPolicy = iota
+
+	// SyncNever leaves writes to be committed to stable storage by the
+	// operating system, or when the table is closed. Writes are much faster
+	// but the most recent writes may be lost if the machine crashes.
+	SyncNever
+
+	// SyncInterval commits writes to stable storage periodically using a
+	// single fsync that covers every write made since the last one. It is
+	// set using WithSyncInterval. DurableOffset reports how much of the data
+	// file has been committed.
+	SyncInterval
+)
+
+// defaultSyncInterval is the period between fsyncs used by the SyncInterval
+// policy if it is set using WithSyncPolicy rather than WithSyncInterval.
+const defaultSyncInterval = time.Second
+
+// WithSyncPolicy sets the policy that determines when writes are committed
+// to stable storage. The default is SyncAlways. If the policy is
+// SyncInterval then writes are committed every second; use WithSyncInterval
+// to choose a different period.
+func WithSyncPolicy(p SyncPolicy) Option {
+	return func(t *Table) {
+		t.policy = p
+	}
+}
+
+// WithSyncInterval sets the table's sync policy to SyncInterval with writes
+// committed to stable storage every period d.
+func WithSyncInterval(d time.Duration) Option {
+	return func(t *Table) {
+		t.policy = SyncInterval
+		t.interval = d
+	}
+}
+
+// DurableOffset returns the offset in the data file up to which all writes
+// are known to have been committed to stable storage. Any write that ended
+// at or before the offset will survive a crash. Since compaction rewrites
+// the data file, offsets from before a compaction do not correspond to those
+// after it.
+func (t *Table) DurableOffset() int64 {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	return t.durable
+}
+
+// flusher periodically commits a table's writes to stable storage.
+type flusher struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startFlusher starts a goroutine that commits the table's writes to stable
+// storage every sync interval.
+func (t *Table) startFlusher() {
+	if t.interval <= 0 {
+		t.interval = defaultSyncInterval
+	}
+	f := &flusher{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	t.flusher = f
+	go t.runFlusher(f, t.interval)
+}
+
+func (t *Table) runFlusher(f *flusher, interval time.Duration) {
+	defer close(f.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			t.flush()
+		}
+	}
+}
+
+// flush commits the table's writes to stable storage if there are any that
+// have not been committed. The table is not locked during the fsync so
+// writes may continue while it is in progress.
+func (t *Table) flush() {
+	t.mtx.RLock()
+	dbfile, audit, end, durable, stalled := t.dbfile, t.audit, t.size, t.durable, t.stalled
+	t.mtx.RUnlock()
+	if dbfile == nil || end <= durable || stalled {
+		return
+	}
+
+	var err error
+	if audit != nil {
+		err = syncWithin(audit.Sync, t.fsyncTimeout)
+	}
+	if err == nil {
+		err = syncWithin(dbfile.Sync, t.fsyncTimeout)
+	}
+	if err == ErrFsyncTimeout {
+		t.mtx.Lock()
+		t.fsyncStalled()
+		t.mtx.Unlock()
+		return
+	}
+	if err != nil {
+		// The data file may have been replaced by a compaction, which
+		// commits the new file itself.
+		return
+	}
+
+	t.mtx.Lock()
+	if t.dbfile == dbfile && end > t.durable {
+		t.durable = end
+	}
+	t.mtx.Unlock()
+}
+
+// stopFlusher stops the table's flusher, if any, and waits for it to exit.
+func (t *Table) stopFlusher() {
+	if t.flusher == nil {
+		return
+	}
+	close(t.flusher.stop)
+	<-t.flusher.done
+	t.flusher = nil
+}
+
+// Durability overrides the table's sync policy for an individual write.
+type Durability int
+
+const (
+	// Durable commits the write to stable storage before the call that
+	// made it returns, whatever the table's sync policy and even during a
+	// bulk import started with BeginBulk. It is intended for critical writes
+	// such as financial records.
+	Durable Durability = iota + 1
+
+	// Deferred allows the call that made the write to return before it is
+	// committed to stable storage, whatever the table's sync policy. The
+	// write is committed along with a later write or when the table is
+	// closed.
+	Deferred
+)
+
+// syncFor commits the table's datafile to stable storage if required by the
+// durability d or, if d is zero, by the table's sync policy.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) syncFor(d Durability) error {
+	switch d {
+	case Durable:
+		return t.fsync()
+	case Deferred:
+		if t.dbfile != nil {
+			return nil
+		}
+	}
+	return t.sync()
+}
@@ -0,0 +1,154 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDurability(t *testing.T) {
+	for _, policy := range []SyncPolicy{SyncAlways, SyncNever} {
+		tf, err := os.CreateTemp("", "lash")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		tf.Close()
+		defer os.Remove(tf.Name())
+
+		table, err := New(tf.Name(), 50, WithSyncPolicy(policy))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if err := table.Put("default", []byte("a")); err != nil {
+			t.Fatal(err.Error())
+		}
+		if err := table.Put("durable", []byte("b"), Durable); err != nil {
+			t.Fatal(err.Error())
+		}
+		if err := table.Put("deferred", []byte("c"), Deferred); err != nil {
+			t.Fatal(err.Error())
+		}
+		table.BeginBulk()
+		if err := table.Put("bulk", []byte("d"), Durable); err != nil {
+			t.Fatal(err.Error())
+		}
+		table.EndBulk()
+		if err := table.Close(); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		table2, err := New(tf.Name(), 50)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if table2.Len() != 4 {
+			t.Errorf("policy %d: got len %d, wanted %d", policy, table2.Len(), 4)
+		}
+		table2.Close()
+	}
+}
+
+func TestDurabilityInMemory(t *testing.T) {
+	table, err := New("", 10, WithSyncPolicy(SyncNever))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, d := range []Durability{Durable, Deferred} {
+		if err := table.Put("k", []byte("v"), d); err != nil {
+			t.Errorf("got error %v, wanted nil", err)
+		}
+	}
+}
+
+func TestSyncInterval(t *testing.T) {
+	tf, err := os.CreateTemp("", "lash")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tf.Close()
+	defer os.Remove(tf.Name())
+
+	table, err := New(tf.Name(), 50, WithSyncInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	opened := table.DurableOffset()
+	if opened != table.Stats().FileBytes {
+		t.Errorf("got durable offset %d, wanted %d", opened, table.Stats().FileBytes)
+	}
+	table.Put("a", []byte("val"))
+	if got := table.DurableOffset(); got != opened {
+		t.Errorf("got durable offset %d, wanted %d", got, opened)
+	}
+	table.Put("b", []byte("val"), Durable)
+	if got, want := table.DurableOffset(), table.Stats().FileBytes; got != want {
+		t.Errorf("got durable offset %d, wanted %d", got, want)
+	}
+	table.Close()
+
+	table, err = New(tf.Name(), 50, WithSyncInterval(5*time.Millisecond))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+	for i := 0; i < 10; i++ {
+		table.Put("c", []byte("val"))
+	}
+	want := table.Stats().FileBytes
+	deadline := time.Now().Add(5 * time.Second)
+	for table.DurableOffset() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("got durable offset %d, wanted %d", table.DurableOffset(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDeleteLater(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	for _, k := range []string{"a", "b", "c"} {
+		if err := table.Put(k, []byte("val")); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	durable := table.DurableOffset()
+	for _, k := range []string{"a", "b"} {
+		if err := table.DeleteLater(k); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if _, found := table.Get("a"); found {
+		t.Errorf("got value for deleted key, wanted none")
+	}
+	if got := table.DurableOffset(); got != durable {
+		t.Errorf("got durable offset %d, wanted %d", got, durable)
+	}
+
+	// The deletions are committed with the next write
+	if err := table.Put("d", []byte("val")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if got, want := table.DurableOffset(), table.Stats().FileBytes; got != want {
+		t.Errorf("got durable offset %d, wanted %d", got, want)
+	}
+	table.Close()
+
+	table, err = New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+	if table.Len() != 2 {
+		t.Errorf("got len %d, wanted %d", table.Len(), 2)
+	}
+}
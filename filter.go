@@ -0,0 +1,62 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"iter"
+	"regexp"
+)
+
+// Filter returns an iterator over the keys and values of the items in the
+// table for which fn returns true. Soft deleted items are not included and
+// items are visited in no particular order. Each time the iterator is used
+// fn is evaluated against a snapshot of the table while it is read locked,
+// so fn must not call methods of the table that modify it. Only matching
+// items are retained, and the loop body runs after the lock has been
+// released so it may safely call any method of the table.
+func (t *Table) Filter(fn func(k string, v []byte) bool) iter.Seq2[string, []byte] {
+	return func(yield func(string, []byte) bool) {
+		var keys []string
+		var vals [][]byte
+		t.mtx.RLock()
+		for k, p := range t.data {
+			if p.deleted != 0 {
+				continue
+			}
+			v, err := t.value(p)
+			if err != nil {
+				t.logger.Error("lash: failed to read value", "key", t.redactKey(k), "error", err)
+				continue
+			}
+			if !fn(k, v) {
+				continue
+			}
+			keys = append(keys, k)
+			vals = append(vals, v)
+		}
+		t.mtx.RUnlock()
+
+		for i, k := range keys {
+			if !yield(k, vals[i]) {
+				return
+			}
+		}
+	}
+}
+
+// ScanRegexp calls fn with the key and value of each item in the table
+// whose key matches re, stopping early if fn returns false. Soft deleted
+// items are not included and items are visited in no particular order. As
+// with Filter, keys are matched against a snapshot of the table and fn is
+// called after the lock has been released, so it may safely call any method
+// of the table.
+func (t *Table) ScanRegexp(re *regexp.Regexp, fn func(k string, v []byte) bool) {
+	for k, v := range t.Filter(func(k string, _ []byte) bool { return re.MatchString(k) }) {
+		if !fn(k, v) {
+			return
+		}
+	}
+}
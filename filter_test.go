@@ -0,0 +1,85 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	for i := 0; i < 20; i++ {
+		table.Put(fmt.Sprintf("k%02d", i), []byte(fmt.Sprintf("%d", i%2)))
+	}
+	table.SoftDelete("k01")
+
+	odd := table.Filter(func(k string, v []byte) bool { return string(v) == "1" })
+	found := make(map[string]bool)
+	for k, v := range odd {
+		if string(v) != "1" {
+			t.Errorf("got value %q for %s, wanted %q", v, k, "1")
+		}
+		found[k] = true
+		// The table is not locked while the loop body runs
+		table.Put("new"+k, v)
+	}
+	if len(found) != 9 {
+		t.Errorf("got %d items, wanted %d", len(found), 9)
+	}
+	if found["k01"] {
+		t.Errorf("got soft deleted item k01, wanted it excluded")
+	}
+
+	n := 0
+	for range table.Filter(func(k string, v []byte) bool { return strings.HasPrefix(k, "new") }) {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("got %d iterations after break, wanted %d", n, 1)
+	}
+}
+
+func TestScanRegexp(t *testing.T) {
+	table, err := New("", 10)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, k := range []string{"order-17", "order-2", "order-x", "invoice-3"} {
+		table.Put(k, []byte("v "+k))
+	}
+
+	found := make(map[string]bool)
+	table.ScanRegexp(regexp.MustCompile(`^order-\d+$`), func(k string, v []byte) bool {
+		if string(v) != "v "+k {
+			t.Errorf("got value %q for %s, wanted %q", v, k, "v "+k)
+		}
+		found[k] = true
+		return true
+	})
+	if len(found) != 2 || !found["order-17"] || !found["order-2"] {
+		t.Errorf("got %v, wanted order-17 and order-2", found)
+	}
+
+	n := 0
+	table.ScanRegexp(regexp.MustCompile(`.`), func(k string, v []byte) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Errorf("got %d calls after stopping, wanted %d", n, 1)
+	}
+}
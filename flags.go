@@ -0,0 +1,161 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Flags returns a view of the table as a store of feature flags, each held
+// under a key formed by appending the flag's name to prefix.
+func (t *Table) Flags(prefix string) Flags {
+	return Flags{t: t, prefix: prefix}
+}
+
+// Flags is a view of a Table as a store of feature flags. Flags are stored
+// as text so they may be edited by other tools. Each getter reads the table
+// afresh, so a service that opens the flag table using WithReadOnly and
+// WithWatch sees flags change as soon as another process rewrites the file.
+// A flag that is missing or cannot be parsed takes its default value; a
+// flag that cannot be parsed is also logged.
+type Flags struct {
+	t      *Table
+	prefix string
+}
+
+// Bool returns the value of the boolean flag name, or def if it is not set.
+// The flag is parsed by strconv.ParseBool.
+func (f Flags) Bool(name string, def bool) bool {
+	s, ok := f.get(name)
+	if !ok {
+		return def
+	}
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		f.invalid(name, err)
+		return def
+	}
+	return v
+}
+
+// String returns the value of the string flag name, or def if it is not
+// set.
+func (f Flags) String(name string, def string) string {
+	s, ok := f.get(name)
+	if !ok {
+		return def
+	}
+	return s
+}
+
+// Int returns the value of the integer flag name, or def if it is not set.
+func (f Flags) Int(name string, def int64) int64 {
+	s, ok := f.get(name)
+	if !ok {
+		return def
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		f.invalid(name, err)
+		return def
+	}
+	return v
+}
+
+// Enabled reports whether the percentage flag name is enabled for subject,
+// such as a user ID. The flag holds the percentage of subjects for which it
+// is enabled, from 0 to 100 with up to two decimal places. Each subject is
+// assigned a fixed position in the range by hashing it with the flag's
+// name, so a subject's result does not change unless the percentage does,
+// and raising the percentage only enables the flag for more subjects. A
+// flag that is not set is enabled for no subjects.
+func (f Flags) Enabled(name string, subject string) bool {
+	s, ok := f.get(name)
+	if !ok {
+		return false
+	}
+	pct, err := strconv.ParseFloat(s, 64)
+	if err == nil && (pct < 0 || pct > 100) {
+		err = fmt.Errorf("percentage %v out of range", pct)
+	}
+	if err != nil {
+		f.invalid(name, err)
+		return false
+	}
+	bucket := xxhash.Sum64String(name+"\x00"+subject) % 10000
+	return float64(bucket) < pct*100
+}
+
+// JSON decodes the value of the JSON flag name into dst, which must be a
+// pointer, and reports whether the flag is set. dst is unchanged if the
+// flag is not set or an error is returned.
+func (f Flags) JSON(name string, dst any) (bool, error) {
+	s, ok := f.get(name)
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal([]byte(s), dst); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SetBool sets the boolean flag name to v.
+func (f Flags) SetBool(name string, v bool) error {
+	return f.t.PutString(f.prefix+name, strconv.FormatBool(v))
+}
+
+// SetString sets the string flag name to v.
+func (f Flags) SetString(name string, v string) error {
+	return f.t.PutString(f.prefix+name, v)
+}
+
+// SetInt sets the integer flag name to v.
+func (f Flags) SetInt(name string, v int64) error {
+	return f.t.PutString(f.prefix+name, strconv.FormatInt(v, 10))
+}
+
+// SetPercentage sets the percentage flag name to pct, which must be between
+// 0 and 100.
+func (f Flags) SetPercentage(name string, pct float64) error {
+	if pct < 0 || pct > 100 {
+		return fmt.Errorf("lash: percentage %v out of range", pct)
+	}
+	return f.t.PutString(f.prefix+name, strconv.FormatFloat(pct, 'f', -1, 64))
+}
+
+// SetJSON sets the JSON flag name to the JSON encoding of v.
+func (f Flags) SetJSON(name string, v any) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return f.t.Put(f.prefix+name, buf)
+}
+
+// Delete unsets the flag name so that it takes its default value.
+func (f Flags) Delete(name string) error {
+	return f.t.Delete(f.prefix + name)
+}
+
+// Table returns the table underlying the view.
+func (f Flags) Table() *Table {
+	return f.t
+}
+
+// get returns the value of the flag name and reports whether it is set.
+func (f Flags) get(name string) (string, bool) {
+	return f.t.GetString(f.prefix + name)
+}
+
+// invalid logs that the flag name could not be parsed.
+func (f Flags) invalid(name string, err error) {
+	f.t.logger.Warn("lash: invalid flag value", "flag", f.t.redactKey(f.prefix+name), "error", err)
+}
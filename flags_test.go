@@ -0,0 +1,120 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestFlags(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	f := table.Flags("flag:")
+	if f.Bool("dark", true) != true || f.String("theme", "blue") != "blue" || f.Int("limit", 7) != 7 {
+		t.Errorf("got values for unset flags, wanted defaults")
+	}
+	if f.Enabled("beta", "alice") {
+		t.Errorf("got unset percentage flag enabled, wanted disabled")
+	}
+
+	if err := f.SetBool("dark", false); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := f.SetString("theme", "green"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := f.SetInt("limit", 42); err != nil {
+		t.Fatal(err.Error())
+	}
+	if got := f.Bool("dark", true); got != false {
+		t.Errorf("got %v, wanted %v", got, false)
+	}
+	if got := f.String("theme", "blue"); got != "green" {
+		t.Errorf("got %q, wanted %q", got, "green")
+	}
+	if got := f.Int("limit", 7); got != 42 {
+		t.Errorf("got %d, wanted %d", got, 42)
+	}
+	if v, _ := table.GetString("flag:limit"); v != "42" {
+		t.Errorf("got stored value %q, wanted %q", v, "42")
+	}
+
+	if err := table.PutString("flag:dark", "maybe"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if got := f.Bool("dark", true); got != true {
+		t.Errorf("got %v for invalid flag, wanted default %v", got, true)
+	}
+
+	type config struct {
+		Endpoint string
+		Retries  int
+	}
+	var c config
+	if found, err := f.JSON("config", &c); found || err != nil {
+		t.Errorf("got %v, %v for unset JSON flag, wanted false, nil", found, err)
+	}
+	if err := f.SetJSON("config", config{Endpoint: "x", Retries: 3}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if found, err := f.JSON("config", &c); !found || err != nil {
+		t.Fatalf("got %v, %v, wanted true, nil", found, err)
+	}
+	if c.Endpoint != "x" || c.Retries != 3 {
+		t.Errorf("got %+v, wanted %+v", c, config{Endpoint: "x", Retries: 3})
+	}
+
+	if err := f.Delete("limit"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if got := f.Int("limit", 7); got != 7 {
+		t.Errorf("got %d after delete, wanted default %d", got, 7)
+	}
+}
+
+func TestFlagsEnabled(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	f := table.Flags("")
+	if err := f.SetPercentage("beta", 101); err == nil {
+		t.Errorf("got no error for percentage out of range")
+	}
+
+	counts := map[float64]int{}
+	enabled := map[string]bool{}
+	for _, pct := range []float64{0, 25, 100} {
+		if err := f.SetPercentage("beta", pct); err != nil {
+			t.Fatal(err.Error())
+		}
+		for i := 0; i < 1000; i++ {
+			subject := fmt.Sprintf("user%d", i)
+			if f.Enabled("beta", subject) {
+				counts[pct]++
+				enabled[subject] = true
+			} else if enabled[subject] {
+				t.Fatalf("%s: got disabled at %v%%, wanted enabled as at a lower percentage", subject, pct)
+			}
+		}
+	}
+	if counts[0] != 0 || counts[100] != 1000 {
+		t.Errorf("got %d enabled at 0%% and %d at 100%%, wanted 0 and 1000", counts[0], counts[100])
+	}
+	if counts[25] < 200 || counts[25] > 300 {
+		t.Errorf("got %d of 1000 enabled at 25%%, wanted about 250", counts[25])
+	}
+}
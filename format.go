@@ -0,0 +1,141 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"io"
+
+	"github.com/iand/lash/format"
+)
+
+// The layout of the data file is defined by the format package. See its
+// documentation for details of the header and records.
+const (
+	kindPut        = format.KindPut        // value stored under key
+	kindSoftDelete = format.KindSoftDelete // key soft deleted at the time held in value
+	kindMeta       = format.KindMeta       // table metadata value stored under key
+	kindDelta      = format.KindDelta      // counter under key incremented by the delta held in value
+	kindOp         = format.KindOp         // set or list under key modified by the operation held in value
+	kindDelete     = format.KindDelete     // key deleted
+	kindUndelete   = format.KindUndelete   // soft deleted key recovered
+)
+
+// ErrCorrupt is returned when a data file cannot be decoded. It may be
+// wrapped with the operation and offset of the record involved, so should be
+// matched using errors.Is.
+var ErrCorrupt = format.ErrCorrupt
+
+type record struct {
+	kind byte
+	key  string
+	val  []byte
+
+	// created and updated are times in nanoseconds since the epoch, zero
+	// if unknown. writes is the number of times the key has been written.
+	created int64
+	updated int64
+	writes  uint64
+
+	// seq is the sequence number of the write that produced the record,
+	// zero if unknown.
+	seq uint64
+}
+
+// format returns the record in the form used by the format package.
+func (rec record) format() format.Record {
+	return format.Record{
+		Kind:    rec.kind,
+		Key:     rec.key,
+		Value:   rec.val,
+		Created: rec.created,
+		Updated: rec.updated,
+		Writes:  rec.writes,
+		Seq:     rec.seq,
+	}
+}
+
+// appendHeader appends the header of a data file whose records use checksum
+// c and whose values are compressed by codec, which may be nil, to buf.
+func appendHeader(buf []byte, c Checksum, codec *format.Codec) []byte {
+	return codec.AppendHeader(buf, c)
+}
+
+// appendRecord appends rec, encoded with checksum c and with its value
+// compressed by codec, to buf.
+func appendRecord(buf []byte, rec record, c Checksum, codec *format.Codec) []byte {
+	return codec.AppendRecord(buf, rec.format(), c)
+}
+
+// recordSize returns the number of bytes occupied by rec in a data file
+// whose records use checksum c, ignoring any compression of its value.
+// Only the values of put records are compressed.
+func recordSize(rec record, c Checksum) int64 {
+	return format.RecordSize(rec.format(), c)
+}
+
+// decoder reads records sequentially from a data file written in either
+// the current or the legacy format.
+type decoder struct {
+	d        *format.Decoder
+	version  int
+	checksum Checksum
+	codec    *format.Codec // decompresses values, nil if they are not compressed
+}
+
+func newDecoder(r io.Reader) (*decoder, error) {
+	d, err := format.NewDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	return &decoder{d: d, version: d.Version(), checksum: d.Checksum(), codec: d.Codec()}, nil
+}
+
+// offset returns the offset in the file of the next record to be read.
+func (d *decoder) offset() int64 {
+	return d.d.Offset()
+}
+
+// next returns the next record in the file. It returns io.EOF when there
+// are no more records.
+func (d *decoder) next() (record, error) {
+	rec, err := d.d.Next()
+	if err != nil {
+		return record{}, err
+	}
+	return record{
+		kind:    rec.Kind,
+		key:     rec.Key,
+		val:     rec.Value,
+		created: rec.Created,
+		updated: rec.Updated,
+		writes:  rec.Writes,
+		seq:     rec.Seq,
+	}, nil
+}
+
+// corrupt reports whether err indicates that a record could not be decoded
+// because the data file is damaged or incomplete.
+func corrupt(err error) bool {
+	return format.IsCorrupt(err)
+}
+
+// atTail reports whether the remainder of the file read by d, following a
+// corrupt record, holds no valid records. Corruption confined to the end of
+// the file is typical of a crash part way through a write.
+func atTail(d *decoder) bool {
+	for {
+		_, err := d.next()
+		if err == nil {
+			return false
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return true
+		}
+		if !corrupt(err) {
+			return false
+		}
+	}
+}
@@ -0,0 +1,79 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package format
+
+import (
+	"hash"
+	"hash/crc32"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// A Checksum identifies the algorithm used to verify the integrity of each
+// record in a data file. The algorithm is recorded in the header of the
+// data file.
+type Checksum byte
+
+const (
+	// ChecksumNone disables checksums. Records are written without a
+	// checksum and corruption may go undetected.
+	ChecksumNone Checksum = 0
+
+	// ChecksumCRC32C uses CRC-32 with the Castagnoli polynomial, which is
+	// hardware accelerated on most platforms. It adds four bytes to each
+	// record.
+	ChecksumCRC32C Checksum = 1
+
+	// ChecksumXXHash64 uses the 64-bit xxHash algorithm. It adds eight bytes
+	// to each record.
+	ChecksumXXHash64 Checksum = 2
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// String returns the name of the checksum algorithm.
+func (c Checksum) String() string {
+	switch c {
+	case ChecksumNone:
+		return "none"
+	case ChecksumCRC32C:
+		return "crc32c"
+	case ChecksumXXHash64:
+		return "xxhash64"
+	default:
+		return "unknown"
+	}
+}
+
+// Valid reports whether c is a known checksum algorithm.
+func (c Checksum) Valid() bool {
+	return c <= ChecksumXXHash64
+}
+
+// Size returns the number of bytes the checksum adds to each record.
+func (c Checksum) Size() int {
+	switch c {
+	case ChecksumCRC32C:
+		return crc32.Size
+	case ChecksumXXHash64:
+		return 8
+	default:
+		return 0
+	}
+}
+
+// Hash returns a new hash for computing the checksum, or nil if records are
+// not checksummed.
+func (c Checksum) Hash() hash.Hash {
+	switch c {
+	case ChecksumCRC32C:
+		return crc32.New(castagnoli)
+	case ChecksumXXHash64:
+		return xxhash.New()
+	default:
+		return nil
+	}
+}
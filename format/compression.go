@@ -0,0 +1,209 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package format
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrUnsupportedCompression is returned when a data file uses an unknown
+// compression algorithm.
+var ErrUnsupportedCompression = errors.New("lash: unsupported compression algorithm")
+
+// A Compression identifies the algorithm used to compress the values of put
+// records in a data file. The algorithm, and any dictionary it uses, is
+// recorded in the header of the data file.
+type Compression byte
+
+const (
+	// CompressionNone stores values uncompressed.
+	CompressionNone Compression = 0
+
+	// CompressionZstd compresses each value using zstd, optionally with a
+	// dictionary.
+	CompressionZstd Compression = 1
+)
+
+// maxDictSize is the largest dictionary that may be recorded in the header
+// of a data file.
+const maxDictSize = 1 << 20
+
+// String returns the name of the compression algorithm.
+func (c Compression) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionZstd:
+		return "zstd"
+	default:
+		return "unknown"
+	}
+}
+
+// Valid reports whether c is a known compression algorithm.
+func (c Compression) Valid() bool {
+	return c <= CompressionZstd
+}
+
+// A Codec compresses and decompresses the values of put records using the
+// algorithm and dictionary recorded in the header of a data file. A nil
+// Codec stores values uncompressed. A Codec is safe for concurrent use.
+type Codec struct {
+	dict []byte
+	enc  *zstd.Encoder
+	dec  *zstd.Decoder
+}
+
+// NewCodec returns a Codec that compresses values using zstd with the
+// dictionary dict, which may be empty, as produced by TrainDict.
+func NewCodec(dict []byte) (*Codec, error) {
+	encOpts := []zstd.EOption{zstd.WithEncoderCRC(false)}
+	var decOpts []zstd.DOption
+	if len(dict) > 0 {
+		encOpts = append(encOpts, zstd.WithEncoderDict(dict))
+		decOpts = append(decOpts, zstd.WithDecoderDicts(dict))
+	}
+	enc, err := zstd.NewWriter(nil, encOpts...)
+	if err != nil {
+		return nil, err
+	}
+	dec, err := zstd.NewReader(nil, decOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Codec{dict: dict, enc: enc, dec: dec}, nil
+}
+
+// Compression returns the algorithm used by the codec.
+func (c *Codec) Compression() Compression {
+	if c == nil {
+		return CompressionNone
+	}
+	return CompressionZstd
+}
+
+// Dict returns the dictionary used by the codec, which may be empty.
+func (c *Codec) Dict() []byte {
+	if c == nil {
+		return nil
+	}
+	return c.dict
+}
+
+// Compress appends the compressed form of src to dst.
+func (c *Codec) Compress(dst, src []byte) []byte {
+	if c == nil {
+		return append(dst, src...)
+	}
+	return c.enc.EncodeAll(src, dst)
+}
+
+// Decompress appends the value compressed in src to dst. It returns
+// ErrCorrupt if src cannot be decompressed.
+func (c *Codec) Decompress(dst, src []byte) ([]byte, error) {
+	if c == nil {
+		return append(dst, src...), nil
+	}
+	b, err := c.dec.DecodeAll(src, dst)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return b, nil
+}
+
+// limitedDecoder returns a decoder for values compressed by the codec that
+// fails with ErrTooLarge, rather than allocating the memory to hold it, if a
+// value would decompress to more than limit bytes. This prevents a small
+// damaged or crafted value from exhausting memory, since the size recorded
+// in a compressed value cannot be trusted.
+func (c *Codec) limitedDecoder(limit int) (*limitedDecoder, error) {
+	opts := []zstd.DOption{
+		zstd.WithDecoderMaxMemory(uint64(limit)),
+		zstd.WithDecoderMaxWindow(uint64(min(max(limit, zstd.MinWindowSize), zstd.MaxWindowSize))),
+	}
+	if len(c.dict) > 0 {
+		opts = append(opts, zstd.WithDecoderDicts(c.dict))
+	}
+	dec, err := zstd.NewReader(nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &limitedDecoder{dec: dec, limit: limit}, nil
+}
+
+// A limitedDecoder decompresses values that may not exceed a limit.
+type limitedDecoder struct {
+	dec   *zstd.Decoder
+	limit int
+}
+
+// Decompress appends the value compressed in src to dst. It returns
+// ErrTooLarge if the value exceeds the decoder's limit and ErrCorrupt if src
+// cannot be decompressed.
+func (l *limitedDecoder) Decompress(dst, src []byte) ([]byte, error) {
+	b, err := l.dec.DecodeAll(src, dst)
+	if err == zstd.ErrDecoderSizeExceeded || err == zstd.ErrWindowSizeExceeded {
+		return nil, ErrTooLarge
+	}
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return b, nil
+}
+
+// AppendHeader appends the header of a data file in the current version
+// whose records use checksum ck and whose values are compressed by the
+// codec to buf.
+func (c *Codec) AppendHeader(buf []byte, ck Checksum) []byte {
+	buf = append(buf, Magic...)
+	buf = append(buf, Version, byte(ck), byte(c.Compression()))
+	buf = binary.AppendUvarint(buf, uint64(len(c.Dict())))
+	return append(buf, c.Dict()...)
+}
+
+// AppendRecord appends rec, encoded in the current version with checksum
+// ck and with its value compressed by the codec if it is a put record, to
+// buf.
+func (c *Codec) AppendRecord(buf []byte, rec Record, ck Checksum) []byte {
+	if c != nil && rec.Kind == KindPut {
+		rec.Value = c.Compress(nil, rec.Value)
+	}
+	return AppendRecord(buf, rec, ck)
+}
+
+// TrainDict trains a zstd dictionary of at most size bytes over samples,
+// which should be representative of the values to be compressed. The most
+// recent samples should be last since they contribute most to the
+// dictionary. It returns an error if the samples are too small to train a
+// dictionary.
+func TrainDict(samples [][]byte, size int) ([]byte, error) {
+	size = min(size, maxDictSize)
+	start, n := len(samples), 0
+	for start > 0 && n < size {
+		start--
+		n += len(samples[start])
+	}
+	hist := make([]byte, 0, min(n, size))
+	for i, s := range samples[start:] {
+		if i == 0 && n > size {
+			s = s[n-size:]
+		}
+		hist = append(hist, s...)
+	}
+	// Identifiers below 32768 and above 2^31 are reserved by zstd
+	id := 32768 + crc32.Checksum(hist, castagnoli)%(1<<31-32768)
+	return zstd.BuildDict(zstd.BuildDictOptions{
+		ID:       id,
+		Contents: samples,
+		History:  hist,
+		Offsets:  [3]int{1, 4, 8},
+		Level:    zstd.SpeedDefault,
+	})
+}
@@ -0,0 +1,156 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func jsonSamples(n int) [][]byte {
+	samples := make([][]byte, n)
+	for i := range samples {
+		samples[i] = fmt.Appendf(nil, `{"id":%d,"name":"user%d","email":"user%d@example.com","active":true,"roles":["reader","writer"]}`, i, i, i)
+	}
+	return samples
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	samples := jsonSamples(500)
+	dict, err := TrainDict(samples, 16<<10)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, dict := range [][]byte{nil, dict} {
+		codec, err := NewCodec(dict)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		data := codec.AppendHeader(nil, ChecksumCRC32C)
+		plain := AppendHeader(nil, ChecksumCRC32C)
+		recs := []Record{
+			{Kind: KindPut, Key: "a", Value: samples[0], Seq: 1},
+			{Kind: KindPut, Key: "b", Value: []byte{}, Seq: 2},
+			{Kind: KindMeta, Key: "m", Value: []byte("meta"), Seq: 3},
+		}
+		for _, rec := range recs {
+			data = codec.AppendRecord(data, rec, ChecksumCRC32C)
+			plain = AppendRecord(plain, rec, ChecksumCRC32C)
+		}
+		if len(dict) > 0 && len(data)-len(dict) >= len(plain) {
+			t.Errorf("got %d bytes of records, wanted fewer than %d", len(data)-len(dict), len(plain))
+		}
+
+		d, err := NewDecoder(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if d.Codec().Compression() != CompressionZstd {
+			t.Errorf("got compression %s, wanted %s", d.Codec().Compression(), CompressionZstd)
+		}
+		if !bytes.Equal(d.Codec().Dict(), dict) {
+			t.Errorf("got dictionary of %d bytes, wanted %d", len(d.Codec().Dict()), len(dict))
+		}
+		for _, want := range recs {
+			rec, err := d.Next()
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if rec.Key != want.Key || !bytes.Equal(rec.Value, want.Value) {
+				t.Errorf("got %q=%q, wanted %q=%q", rec.Key, rec.Value, want.Key, want.Value)
+			}
+		}
+		if _, err := d.Next(); err != io.EOF {
+			t.Errorf("got error %v, wanted %v", err, io.EOF)
+		}
+	}
+}
+
+func TestCodecDecompressLimit(t *testing.T) {
+	codec, err := NewCodec(nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	data := codec.AppendHeader(nil, ChecksumNone)
+	data = codec.AppendRecord(data, Record{Kind: KindPut, Key: "k", Value: make([]byte, 1000)}, ChecksumNone)
+
+	d, err := NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	d.MaxValueSize = 100
+	if _, err := d.Next(); err != ErrTooLarge {
+		t.Errorf("got error %v, wanted %v", err, ErrTooLarge)
+	}
+}
+
+func TestCodecDecompressLimitMemory(t *testing.T) {
+	codec, err := NewCodec(nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	huge := make([]byte, 256<<20)
+
+	// A value compressed by the codec records its size, while one written
+	// as a stream does not
+	var streamed bytes.Buffer
+	enc, err := zstd.NewWriter(&streamed, zstd.WithEncoderCRC(false))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	enc.Write(huge)
+	enc.Close()
+
+	for name, v := range map[string][]byte{"sized": codec.Compress(nil, huge), "streamed": streamed.Bytes()} {
+		data := AppendHeader(nil, ChecksumNone)
+		data = AppendRecord(data, Record{Kind: KindPut, Key: "k", Value: v}, ChecksumNone)
+		data[len(Magic)+2] = byte(CompressionZstd)
+
+		d, err := NewDecoder(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		d.MaxValueSize = 64 << 10
+		if len(v) >= d.MaxValueSize {
+			t.Fatalf("%s: got compressed value of %d bytes, wanted fewer than %d", name, len(v), d.MaxValueSize)
+		}
+
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, err = d.Next()
+		runtime.ReadMemStats(&after)
+		if err != ErrTooLarge {
+			t.Errorf("%s: got error %v, wanted %v", name, err, ErrTooLarge)
+		}
+		if n := after.TotalAlloc - before.TotalAlloc; n > 16<<20 {
+			t.Errorf("%s: got %d bytes allocated decoding a value of %d bytes, wanted far fewer", name, n, len(v))
+		}
+	}
+
+	// Values within the limit are decompressed
+	data := codec.AppendHeader(nil, ChecksumNone)
+	data = codec.AppendRecord(data, Record{Kind: KindPut, Key: "k", Value: huge[:4096]}, ChecksumNone)
+	d, err := NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	d.MaxValueSize = 4096
+	if rec, err := d.Next(); err != nil || len(rec.Value) != 4096 {
+		t.Errorf("got value of %d bytes and error %v, wanted %d bytes", len(rec.Value), err, 4096)
+	}
+}
+
+func TestTrainDictTooFewSamples(t *testing.T) {
+	if _, err := TrainDict(nil, 16<<10); err == nil {
+		t.Errorf("got no error, wanted one")
+	}
+}
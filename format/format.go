@@ -0,0 +1,518 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+// Package format encodes and decodes the records held in the data files of
+// lash tables, so that tools other than lash itself can read and write them.
+package format
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+	"math"
+)
+
+// The data file begins with a header consisting of Magic followed by a single
+// byte holding the format version and, from version 4, a single byte holding
+// the checksum algorithm used for records. From version 7 these are followed
+// by a single byte holding the compression algorithm used for the values of
+// put records and by the dictionary used by the algorithm, preceded by its
+// length as a uvarint, which is zero if there is no dictionary. Files
+// written by early releases of lash have no header and are read using the
+// legacy format. A legacy file can never begin with Magic since that would
+// imply an empty key followed by a negative value length.
+const (
+	Magic   = "\x1f\x1fLASH"
+	Version = 7
+)
+
+// Each record in a versioned data file is laid out as:
+//
+//	kind | uvarint(len(key)) | key | meta | uvarint(len(value)) | value | checksum
+//
+// where meta, which is absent in version 1 files, is:
+//
+//	varint(created) | varint(updated) | uvarint(writes) | uvarint(seq)
+//
+// and seq is absent in version 2 files. The checksum, which is absent before
+// version 4, covers every byte of the record after the kind and its length
+// depends on the checksum algorithm recorded in the header. Delta and op
+// records appear only in files from version 5 and delete and undelete
+// records only in files from version 6. The value of a put record is
+// compressed if the header records a compression algorithm.
+//
+// The kind is the first byte of the record so that a record can be marked
+// as deleted by overwriting it with KindTomb. Every kind of record shares the
+// same layout so that a deleted record can still be skipped. Deleting a key,
+// or recovering a soft deleted key, also appends a record so that the change
+// can be seen by a reader of only the records appended after some point in
+// the file, such as an incremental backup.
+const (
+	KindPut        = byte('p') // value stored under key
+	KindSoftDelete = byte('s') // key soft deleted at the time held in value
+	KindMeta       = byte('m') // table metadata value stored under key
+	KindDelta      = byte('d') // counter under key incremented by the delta held in value
+	KindOp         = byte('o') // set or list under key modified by the operation held in value
+	KindDelete     = byte('x') // key deleted
+	KindUndelete   = byte('u') // soft deleted key recovered
+	KindTomb       = byte(127) // record that has been deleted
+)
+
+// sep terminates the key of a record in a legacy data file.
+const sep = byte(31)
+
+// allocChunk is the largest allocation made for a key or value before any
+// of its bytes have been read. Longer keys and values are read into a buffer
+// that grows as they arrive so that a damaged or hostile length cannot cause
+// a huge allocation.
+const allocChunk = 64 << 10
+
+var (
+	// ErrCorrupt is returned when a data file cannot be decoded.
+	ErrCorrupt = errors.New("lash: corrupt data file")
+
+	// ErrChecksum is returned when a record in the data file fails checksum
+	// validation.
+	ErrChecksum = errors.New("lash: checksum mismatch")
+
+	// ErrTooLarge is returned when a key or value in the data file is longer
+	// than the limit set on the Decoder.
+	ErrTooLarge = errors.New("lash: record exceeds size limit")
+
+	// ErrUnsupportedVersion is returned when a data file was written using a
+	// later version of the format.
+	ErrUnsupportedVersion = errors.New("lash: unsupported data file version")
+
+	// ErrUnsupportedChecksum is returned when a data file uses an unknown
+	// checksum algorithm.
+	ErrUnsupportedChecksum = errors.New("lash: unsupported checksum algorithm")
+)
+
+// A Record is a single entry in a data file.
+type Record struct {
+	Kind  byte
+	Key   string
+	Value []byte
+
+	// Created and Updated are times in nanoseconds since the epoch, zero
+	// if unknown. Writes is the number of times the key has been written.
+	Created int64
+	Updated int64
+	Writes  uint64
+
+	// Seq is the sequence number of the write that produced the record,
+	// zero if unknown.
+	Seq uint64
+}
+
+// AppendHeader appends the header of a data file in the current version
+// whose records use checksum c and whose values are not compressed to buf.
+// Use Codec.AppendHeader for a data file with compressed values.
+func AppendHeader(buf []byte, c Checksum) []byte {
+	return (*Codec)(nil).AppendHeader(buf, c)
+}
+
+// AppendRecord appends rec, encoded in the current version with checksum c,
+// to buf.
+func AppendRecord(buf []byte, rec Record, c Checksum) []byte {
+	buf = append(buf, rec.Kind)
+	start := len(buf)
+	buf = binary.AppendUvarint(buf, uint64(len(rec.Key)))
+	buf = append(buf, rec.Key...)
+	buf = binary.AppendVarint(buf, rec.Created)
+	buf = binary.AppendVarint(buf, rec.Updated)
+	buf = binary.AppendUvarint(buf, rec.Writes)
+	buf = binary.AppendUvarint(buf, rec.Seq)
+	buf = binary.AppendUvarint(buf, uint64(len(rec.Value)))
+	buf = append(buf, rec.Value...)
+	if h := c.Hash(); h != nil {
+		h.Write(buf[start:])
+		buf = h.Sum(buf)
+	}
+	return buf
+}
+
+// RecordSize returns the number of bytes occupied by rec when encoded in the
+// current version with checksum c.
+func RecordSize(rec Record, c Checksum) int64 {
+	return int64(1 + uvarintLen(uint64(len(rec.Key))) + len(rec.Key) +
+		varintLen(rec.Created) + varintLen(rec.Updated) + uvarintLen(rec.Writes) + uvarintLen(rec.Seq) +
+		uvarintLen(uint64(len(rec.Value))) + len(rec.Value) + c.Size())
+}
+
+func uvarintLen(x uint64) int {
+	n := 1
+	for x >= 0x80 {
+		x >>= 7
+		n++
+	}
+	return n
+}
+
+func varintLen(x int64) int {
+	ux := uint64(x) << 1
+	if x < 0 {
+		ux = ^ux
+	}
+	return uvarintLen(ux)
+}
+
+// A Decoder reads records sequentially from a data file written in any
+// version of the format, including the legacy format.
+type Decoder struct {
+	// MaxKeySize and MaxValueSize limit the length of the keys and values
+	// that may be decoded. Next returns ErrTooLarge for a record that
+	// exceeds either limit. There is no limit if the value is zero.
+	MaxKeySize   int
+	MaxValueSize int
+
+	r        *countingReader
+	version  int
+	checksum Checksum
+	codec    *Codec          // decompresses the values of put records, nil if not compressed
+	limited  *limitedDecoder // decompresses values within MaxValueSize, built when first needed
+	sum      []byte          // scratch space for reading checksums
+}
+
+// NewDecoder returns a Decoder that reads the data file held in r, after
+// reading its header.
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	d := &Decoder{r: &countingReader{r: bufio.NewReader(r)}}
+	hdr, err := d.r.r.Peek(len(Magic) + 2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(hdr) < len(Magic) || string(hdr[:len(Magic)]) != Magic {
+		// legacy format
+		return d, nil
+	}
+	if len(hdr) < len(Magic)+1 {
+		return nil, ErrCorrupt
+	}
+	d.version = int(hdr[len(Magic)])
+	if d.version > Version {
+		return nil, ErrUnsupportedVersion
+	}
+	hdrlen := len(Magic) + 1
+	if d.version >= 4 {
+		if len(hdr) < len(Magic)+2 {
+			return nil, ErrCorrupt
+		}
+		d.checksum = Checksum(hdr[len(Magic)+1])
+		if !d.checksum.Valid() {
+			return nil, ErrUnsupportedChecksum
+		}
+		hdrlen++
+	}
+	_, err = d.r.r.Discard(hdrlen)
+	if err != nil {
+		return nil, err
+	}
+	d.r.n = int64(hdrlen)
+	if d.version >= 7 {
+		if err := d.readCompression(); err != nil {
+			return nil, err
+		}
+	}
+	d.sum = make([]byte, d.checksum.Size())
+	return d, nil
+}
+
+// readCompression reads the compression algorithm and dictionary from the
+// header of the data file.
+func (d *Decoder) readCompression() error {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return ErrCorrupt
+	}
+	comp := Compression(b)
+	if !comp.Valid() {
+		return ErrUnsupportedCompression
+	}
+	dict, err := d.readBytes(maxDictSize)
+	if err != nil {
+		if err == ErrTooLarge || err == io.ErrUnexpectedEOF {
+			return ErrCorrupt
+		}
+		return err
+	}
+	if comp == CompressionNone {
+		if len(dict) > 0 {
+			return ErrCorrupt
+		}
+		return nil
+	}
+	d.codec, err = NewCodec(dict)
+	if err != nil {
+		return ErrCorrupt
+	}
+	return nil
+}
+
+// Version returns the version of the format used by the data file, which is
+// zero for the legacy format.
+func (d *Decoder) Version() int {
+	return d.version
+}
+
+// Checksum returns the checksum algorithm used by records in the data file.
+func (d *Decoder) Checksum() Checksum {
+	return d.checksum
+}
+
+// Codec returns the codec used to compress the values of put records in
+// the data file, or nil if they are not compressed. Next decompresses values
+// before returning them.
+func (d *Decoder) Codec() *Codec {
+	return d.codec
+}
+
+// Offset returns the offset in the file of the next record to be read.
+func (d *Decoder) Offset() int64 {
+	return d.r.n
+}
+
+// Next returns the next record in the file. It returns io.EOF when there
+// are no more records. A record that is incomplete, perhaps because a write
+// was interrupted, is reported as io.ErrUnexpectedEOF, while a damaged record
+// is reported as ErrCorrupt or ErrChecksum.
+func (d *Decoder) Next() (Record, error) {
+	if d.version == 0 {
+		return d.nextLegacy()
+	}
+
+	kind, err := d.r.ReadByte()
+	if err != nil {
+		return Record{}, err
+	}
+	d.r.h = d.checksum.Hash()
+	defer func() { d.r.h = nil }()
+
+	kb, err := d.readBytes(d.MaxKeySize)
+	if err != nil {
+		return Record{}, err
+	}
+	rec := Record{Kind: kind, Key: string(kb)}
+
+	if d.version >= 2 {
+		rec.Created, err = readVarint(d.r)
+		if err != nil {
+			return Record{}, err
+		}
+		rec.Updated, err = readVarint(d.r)
+		if err != nil {
+			return Record{}, err
+		}
+		rec.Writes, err = readUvarint(d.r)
+		if err != nil {
+			return Record{}, err
+		}
+	}
+	if d.version >= 3 {
+		rec.Seq, err = readUvarint(d.r)
+		if err != nil {
+			return Record{}, err
+		}
+	}
+
+	rec.Value, err = d.readBytes(d.MaxValueSize)
+	if err != nil {
+		return Record{}, err
+	}
+
+	if h := d.r.h; h != nil {
+		d.r.h = nil
+		_, err = io.ReadFull(d.r, d.sum)
+		if err != nil {
+			return Record{}, noEOF(err)
+		}
+		if !bytes.Equal(h.Sum(nil), d.sum) {
+			return Record{}, ErrChecksum
+		}
+	}
+	if d.codec != nil && rec.Kind == KindPut {
+		rec.Value, err = d.decompress(rec.Value)
+		if err != nil {
+			return Record{}, err
+		}
+	}
+	return rec, nil
+}
+
+// decompress returns the value compressed in v. The value is not
+// decompressed beyond MaxValueSize, if set, since a value that is small when
+// compressed may be far larger once decompressed.
+func (d *Decoder) decompress(v []byte) ([]byte, error) {
+	if d.MaxValueSize <= 0 {
+		return d.codec.Decompress(nil, v)
+	}
+	if d.limited == nil || d.limited.limit != d.MaxValueSize {
+		var err error
+		d.limited, err = d.codec.limitedDecoder(d.MaxValueSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+	v, err := d.limited.Decompress(nil, v)
+	if err != nil {
+		return nil, err
+	}
+	if len(v) > d.MaxValueSize {
+		return nil, ErrTooLarge
+	}
+	return v, nil
+}
+
+// readBytes reads a length followed by that number of bytes, which may not
+// exceed limit if it is greater than zero.
+func (d *Decoder) readBytes(limit int) ([]byte, error) {
+	n, err := readUvarint(d.r)
+	if err != nil {
+		return nil, err
+	}
+	if n > math.MaxInt {
+		return nil, ErrCorrupt
+	}
+	return d.readN(int(n), limit)
+}
+
+// readN reads n bytes, which may not exceed limit if it is greater than
+// zero. The buffer holding the bytes grows as they are read so that the
+// allocation is bounded by the size of the file rather than by n.
+func (d *Decoder) readN(n int, limit int) ([]byte, error) {
+	if limit > 0 && n > limit {
+		return nil, ErrTooLarge
+	}
+	buf := make([]byte, min(n, allocChunk))
+	read := 0
+	for {
+		m, err := io.ReadFull(d.r, buf[read:])
+		read += m
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		if read == n {
+			return buf, nil
+		}
+		buf = append(buf, make([]byte, min(n-read, read))...)
+	}
+}
+
+// nextLegacy reads a record written as key, sep, varint(len(value)), value.
+// Deleted records have the first byte of the key overwritten with KindTomb.
+func (d *Decoder) nextLegacy() (Record, error) {
+	key, err := d.r.ReadString(sep)
+	if err != nil {
+		// A partially written key is treated as the end of the file
+		return Record{}, err
+	}
+	if d.MaxKeySize > 0 && len(key)-1 > d.MaxKeySize {
+		return Record{}, ErrTooLarge
+	}
+
+	lb, err := readVarint(d.r)
+	if err != nil {
+		return Record{}, err
+	}
+	if lb < 0 || lb > math.MaxInt {
+		return Record{}, ErrCorrupt
+	}
+
+	buf, err := d.readN(int(lb), d.MaxValueSize)
+	if err != nil {
+		return Record{}, err
+	}
+
+	kind := KindPut
+	if key[0] == KindTomb {
+		kind = KindTomb
+	}
+	return Record{Kind: kind, Key: key[:len(key)-1], Value: buf}, nil
+}
+
+// IsCorrupt reports whether err indicates that a record could not be decoded
+// because the data file is damaged or incomplete.
+func IsCorrupt(err error) bool {
+	return err == ErrChecksum || err == ErrCorrupt || err == io.ErrUnexpectedEOF
+}
+
+// noEOF converts io.EOF into io.ErrUnexpectedEOF for reads that occur part way
+// through a record.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// readUvarint reads a uvarint that occurs part way through a record. It
+// returns ErrCorrupt if the uvarint overflows a 64-bit integer.
+func readUvarint(r io.ByteReader) (uint64, error) {
+	var x uint64
+	var s uint
+	for i := 0; i < binary.MaxVarintLen64; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, noEOF(err)
+		}
+		if b < 0x80 {
+			if i == binary.MaxVarintLen64-1 && b > 1 {
+				return 0, ErrCorrupt
+			}
+			return x | uint64(b)<<s, nil
+		}
+		x |= uint64(b&0x7f) << s
+		s += 7
+	}
+	return 0, ErrCorrupt
+}
+
+// readVarint reads a varint that occurs part way through a record. It
+// returns ErrCorrupt if the varint overflows a 64-bit integer.
+func readVarint(r io.ByteReader) (int64, error) {
+	ux, err := readUvarint(r)
+	x := int64(ux >> 1)
+	if ux&1 != 0 {
+		x = ^x
+	}
+	return x, err
+}
+
+// countingReader counts the bytes read from an underlying reader and, when h
+// is not nil, writes them to h.
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+	h hash.Hash
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if c.h != nil {
+		c.h.Write(p[:n])
+	}
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+		if c.h != nil {
+			c.h.Write([]byte{b})
+		}
+	}
+	return b, err
+}
+
+func (c *countingReader) ReadString(delim byte) (string, error) {
+	s, err := c.r.ReadString(delim)
+	c.n += int64(len(s))
+	return s, err
+}
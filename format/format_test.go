@@ -0,0 +1,233 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package format
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	recs := []Record{
+		{Kind: KindPut, Key: "a", Value: []byte("val"), Created: 1, Updated: 2, Writes: 1, Seq: 1},
+		{Kind: KindMeta, Key: "schema", Value: []byte("1"), Seq: 2},
+		{Kind: KindSoftDelete, Key: "a", Value: []byte{}, Created: -5, Seq: 3},
+	}
+	for _, c := range []Checksum{ChecksumNone, ChecksumCRC32C, ChecksumXXHash64} {
+		t.Run(c.String(), func(t *testing.T) {
+			buf := AppendHeader(nil, c)
+			for _, rec := range recs {
+				before := len(buf)
+				buf = AppendRecord(buf, rec, c)
+				if got, want := RecordSize(rec, c), int64(len(buf)-before); got != want {
+					t.Errorf("got size %d, wanted %d", got, want)
+				}
+			}
+
+			d, err := NewDecoder(bytes.NewReader(buf))
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if d.Version() != Version {
+				t.Errorf("got version %d, wanted %d", d.Version(), Version)
+			}
+			if d.Checksum() != c {
+				t.Errorf("got checksum %v, wanted %v", d.Checksum(), c)
+			}
+			for _, want := range recs {
+				got, err := d.Next()
+				if err != nil {
+					t.Fatal(err.Error())
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("got %+v, wanted %+v", got, want)
+				}
+			}
+			if _, err := d.Next(); err != io.EOF {
+				t.Errorf("got error %v, wanted %v", err, io.EOF)
+			}
+			if d.Offset() != int64(len(buf)) {
+				t.Errorf("got offset %d, wanted %d", d.Offset(), len(buf))
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	valid := AppendRecord(AppendHeader(nil, ChecksumCRC32C), Record{Kind: KindPut, Key: "a", Value: []byte("val")}, ChecksumCRC32C)
+
+	testCases := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{
+			name:    "truncated",
+			data:    valid[:len(valid)-2],
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		{
+			name: "checksum mismatch",
+			data: func() []byte {
+				b := bytes.Clone(valid)
+				b[len(b)-5] ^= 0xff
+				return b
+			}(),
+			wantErr: ErrChecksum,
+		},
+		{
+			name:    "varint overflow",
+			data:    append(AppendHeader(nil, ChecksumNone), KindPut, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01),
+			wantErr: ErrCorrupt,
+		},
+		{
+			name:    "huge length",
+			data:    binary.AppendUvarint(append(AppendHeader(nil, ChecksumNone), KindPut), 1<<62),
+			wantErr: io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := NewDecoder(bytes.NewReader(tc.data))
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			_, err = d.Next()
+			if err != tc.wantErr {
+				t.Errorf("got error %v, wanted %v", err, tc.wantErr)
+			}
+			if !IsCorrupt(err) {
+				t.Errorf("got IsCorrupt false, wanted true")
+			}
+		})
+	}
+}
+
+func TestDecodeHeaderErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{name: "later version", data: []byte(Magic + "\x63\x01"), wantErr: ErrUnsupportedVersion},
+		{name: "unknown checksum", data: []byte(Magic + "\x05\x63"), wantErr: ErrUnsupportedChecksum},
+		{name: "missing version", data: []byte(Magic), wantErr: ErrCorrupt},
+		{name: "missing compression", data: []byte(Magic + "\x07\x01"), wantErr: ErrCorrupt},
+		{name: "unknown compression", data: []byte(Magic + "\x07\x01\x63\x00"), wantErr: ErrUnsupportedCompression},
+		{name: "truncated dictionary", data: []byte(Magic + "\x07\x01\x01\x10abc"), wantErr: ErrCorrupt},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewDecoder(bytes.NewReader(tc.data))
+			if err != tc.wantErr {
+				t.Errorf("got error %v, wanted %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecodeHugeLengthAllocation(t *testing.T) {
+	data := binary.AppendUvarint(append(AppendHeader(nil, ChecksumNone), KindPut), 1<<40)
+	data = append(data, make([]byte, 1<<10)...)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	d, err := NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := d.Next(); err != io.ErrUnexpectedEOF {
+		t.Errorf("got error %v, wanted %v", err, io.ErrUnexpectedEOF)
+	}
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("got %d bytes allocated, wanted less than %d", allocated, 1<<20)
+	}
+}
+
+func TestDecodeLimits(t *testing.T) {
+	data := AppendRecord(AppendHeader(nil, ChecksumNone), Record{Kind: KindPut, Key: "key", Value: []byte("value")}, ChecksumNone)
+
+	testCases := []struct {
+		name     string
+		maxKey   int
+		maxValue int
+		wantErr  error
+	}{
+		{name: "within limits", maxKey: 3, maxValue: 5, wantErr: nil},
+		{name: "key too large", maxKey: 2, wantErr: ErrTooLarge},
+		{name: "value too large", maxValue: 4, wantErr: ErrTooLarge},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := NewDecoder(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			d.MaxKeySize = tc.maxKey
+			d.MaxValueSize = tc.maxValue
+			_, err = d.Next()
+			if err != tc.wantErr {
+				t.Errorf("got error %v, wanted %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecodeLegacy(t *testing.T) {
+	// a=old (tombstoned), b=val
+	legacy := "\x7f\x1f\x06old" + "b\x1f\x06val"
+	d, err := NewDecoder(bytes.NewReader([]byte(legacy)))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if d.Version() != 0 {
+		t.Errorf("got version %d, wanted %d", d.Version(), 0)
+	}
+	for _, want := range []Record{
+		{Kind: KindTomb, Key: "\x7f", Value: []byte("old")},
+		{Kind: KindPut, Key: "b", Value: []byte("val")},
+	} {
+		got, err := d.Next()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, wanted %+v", got, want)
+		}
+	}
+}
+
+func FuzzDecoder(f *testing.F) {
+	f.Add(AppendRecord(AppendHeader(nil, ChecksumCRC32C), Record{Kind: KindPut, Key: "a", Value: []byte("val"), Seq: 1}, ChecksumCRC32C))
+	f.Add(AppendRecord(AppendHeader(nil, ChecksumNone), Record{Kind: KindMeta, Key: "m", Value: []byte("1")}, ChecksumNone))
+	f.Add([]byte("a\x1f\x06val"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		d, err := NewDecoder(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		for {
+			rec, err := d.Next()
+			if err != nil {
+				return
+			}
+			if d.Version() != Version || d.Checksum() == ChecksumNone {
+				continue
+			}
+			// The size reported for a decoded record matches its encoding
+			buf := AppendRecord(nil, rec, d.Checksum())
+			if int64(len(buf)) != RecordSize(rec, d.Checksum()) {
+				t.Errorf("got size %d, wanted %d", RecordSize(rec, d.Checksum()), len(buf))
+			}
+		}
+	})
+}
@@ -0,0 +1,47 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"testing"
+)
+
+func TestReadLegacy(t *testing.T) {
+	tf, err := os.CreateTemp("", "lash")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	// a=old (tombstoned), b=val, a=new
+	legacy := "\x7f\x1f\x06old" + "b\x1f\x06val" + "a\x1f\x06new"
+	_, err = tf.WriteString(legacy)
+	tf.Close()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table, err := New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+
+	if table.Len() != 2 {
+		t.Errorf("got len %d, wanted %d", table.Len(), 2)
+	}
+
+	for k, want := range map[string]string{"a": "new", "b": "val"} {
+		v, found := table.Get(k)
+		if !found {
+			t.Fatalf("got not found for %q, wanted found", k)
+		}
+		if string(v) != want {
+			t.Errorf("got %q, wanted %q", v, want)
+		}
+	}
+}
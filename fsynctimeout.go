@@ -0,0 +1,80 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrFsyncTimeout is returned by methods that modify the table once
+// committing the data file to stable storage has taken longer than the
+// period set using WithFsyncTimeout. The table stops accepting changes but
+// continues to serve reads from memory until it is closed and opened again.
+var ErrFsyncTimeout = errors.New("lash: fsync timed out")
+
+// WithFsyncTimeout limits the time the table waits for its data file, or
+// audit log, to be committed to stable storage to d. If the disk hangs, such
+// as when a network filesystem stalls or a drive is failing, then the write
+// that is waiting fails with ErrFsyncTimeout rather than holding the table's
+// lock indefinitely and blocking every other writer behind it. The table
+// stops accepting changes, and the stalled fsync is left to complete in the
+// background. Writes that failed may still reach the disk and be loaded when
+// the table is opened again. A period of zero or less waits indefinitely,
+// which is the default.
+func WithFsyncTimeout(d time.Duration) Option {
+	return func(t *Table) {
+		t.fsyncTimeout = d
+	}
+}
+
+// syncFile commits a file of the table to stable storage by calling sync,
+// which is usually the file's Sync method, within the table's fsync timeout.
+// It returns ErrFsyncTimeout, and stops the table accepting changes, if the
+// timeout expires or the table has already stopped accepting changes
+// because an earlier fsync stalled.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) syncFile(sync func() error) error {
+	if t.stalled {
+		return ErrFsyncTimeout
+	}
+	err := syncWithin(sync, t.fsyncTimeout)
+	if err == ErrFsyncTimeout {
+		t.fsyncStalled()
+	}
+	return err
+}
+
+// fsyncStalled stops the table accepting changes after an fsync did not
+// complete within the table's fsync timeout.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) fsyncStalled() {
+	if !t.stalled {
+		t.logger.Error("lash: fsync timed out, table will not accept changes until reopened", "file", t.filename, "timeout", t.fsyncTimeout)
+	}
+	t.stalled = true
+}
+
+// syncWithin calls sync, returning ErrFsyncTimeout if it does not return
+// within d. If d is zero or less then it waits for sync to return. A call
+// that times out continues in the background.
+func syncWithin(sync func() error, d time.Duration) error {
+	if d <= 0 {
+		return sync()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- sync()
+	}()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return ErrFsyncTimeout
+	}
+}
@@ -0,0 +1,93 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSyncWithin(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	hang := func() error {
+		<-block
+		return nil
+	}
+	if err := syncWithin(hang, time.Millisecond); err != ErrFsyncTimeout {
+		t.Errorf("got error %v, wanted %v", err, ErrFsyncTimeout)
+	}
+
+	errSync := errors.New("sync failed")
+	fail := func() error { return errSync }
+	if err := syncWithin(fail, time.Second); err != errSync {
+		t.Errorf("got error %v, wanted %v", err, errSync)
+	}
+	if err := syncWithin(fail, 0); err != errSync {
+		t.Errorf("got error %v, wanted %v", err, errSync)
+	}
+}
+
+func TestFsyncTimeout(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Close()
+	defer os.Remove(tf.Name())
+
+	table, err = New(tf.Name(), 50, WithFsyncTimeout(time.Minute))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.Put("a", []byte("value")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	block := make(chan struct{})
+	defer close(block)
+	table.mtx.Lock()
+	table.fsyncTimeout = time.Millisecond
+	err = table.syncFile(func() error {
+		<-block
+		return nil
+	})
+	table.mtx.Unlock()
+	if err != ErrFsyncTimeout {
+		t.Fatalf("got error %v, wanted %v", err, ErrFsyncTimeout)
+	}
+	if !table.Stats().FsyncTimedOut {
+		t.Errorf("got fsync timeout not reported, wanted it reported")
+	}
+
+	if err := table.Put("b", []byte("value")); err != ErrFsyncTimeout {
+		t.Errorf("got error %v, wanted %v", err, ErrFsyncTimeout)
+	}
+	if err := table.Compact(); err != ErrFsyncTimeout {
+		t.Errorf("got error %v, wanted %v", err, ErrFsyncTimeout)
+	}
+	if v, _ := table.Get("a"); string(v) != "value" {
+		t.Errorf("got %q, wanted %q", v, "value")
+	}
+	if err := table.Close(); err != ErrFsyncTimeout {
+		t.Errorf("got error %v on close, wanted %v", err, ErrFsyncTimeout)
+	}
+
+	// The table accepts changes again once reopened
+	table, err = New(tf.Name(), 50, WithFsyncTimeout(time.Minute))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+	if err := table.Put("b", []byte("value")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if v, _ := table.Get("a"); string(v) != "value" {
+		t.Errorf("got %q, wanted %q", v, "value")
+	}
+}
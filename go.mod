@@ -1,3 +1,16 @@
 module github.com/iand/lash
 
-go 1.16
+go 1.23
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0
+	github.com/fsnotify/fsnotify v1.9.0
+	github.com/gorilla/sessions v1.4.0
+	github.com/klauspost/compress v1.18.0
+	google.golang.org/protobuf v1.36.9
+)
+
+require (
+	github.com/gorilla/securecookie v1.1.2 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+)
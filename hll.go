@@ -0,0 +1,99 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// hllPrecision is the number of bits of each hash used to select a register
+// of a HyperLogLog sketch, giving a standard error of about 1.6%.
+const (
+	hllPrecision = 12
+	hllRegisters = 1 << hllPrecision
+)
+
+// HLL returns a view of the HyperLogLog sketch stored under key k. The
+// sketch need not exist until an item is added.
+func (t *Table) HLL(k string) HLL {
+	return HLL{t: t, k: k}
+}
+
+// HLL is a view of a HyperLogLog sketch stored under a key in a Table. A
+// sketch estimates the number of distinct items added to it using a fixed
+// 4KiB of space. Adding an item appends a small record to the data file
+// only when it changes the sketch, so most adds of a large stream of items
+// write nothing.
+type HLL struct {
+	t *Table
+	k string
+}
+
+// Add adds item to the sketch.
+func (h HLL) Add(item []byte) error {
+	hash := xxhash.Sum64(item)
+	idx := hash >> (64 - hllPrecision)
+	rho := bits.LeadingZeros64(hash<<hllPrecision|1<<(hllPrecision-1)) + 1
+
+	operand := binary.BigEndian.AppendUint16(nil, uint16(idx))
+	operand = append(operand, byte(rho))
+	_, _, err := h.t.modify(h.k, typeHLL, opHLLAdd, operand)
+	return err
+}
+
+// Count returns the estimated number of distinct items added to the sketch.
+func (h HLL) Count() uint64 {
+	v, found := h.t.Get(h.k)
+	if !found || len(v) != 1+hllRegisters || v[0] != typeHLL {
+		return 0
+	}
+	registers := v[1:]
+
+	sum := 0.0
+	zeros := 0
+	for _, r := range registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+	m := float64(hllRegisters)
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate for small cardinalities
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// applyHLL returns the result of raising a register of the encoded sketch v
+// as described by operand, and reports whether v was changed.
+func applyHLL(v []byte, operand []byte) ([]byte, bool, error) {
+	if len(v) == 0 || v[0] != typeHLL || len(v) != 1 && len(v) != 1+hllRegisters {
+		return nil, false, ErrWrongType
+	}
+	if len(operand) != 3 {
+		return nil, false, ErrCorrupt
+	}
+	idx := int(binary.BigEndian.Uint16(operand))
+	rho := operand[2]
+	if idx >= hllRegisters {
+		return nil, false, ErrCorrupt
+	}
+	if len(v) != 1 && v[1+idx] >= rho {
+		return v, false, nil
+	}
+
+	sketch := make([]byte, 1+hllRegisters)
+	sketch[0] = typeHLL
+	copy(sketch, v)
+	sketch[1+idx] = rho
+	return sketch, true, nil
+}
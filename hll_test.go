@@ -0,0 +1,56 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestHLL(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	h := table.HLL("visitors")
+	if h.Count() != 0 {
+		t.Errorf("got count %d, wanted %d", h.Count(), 0)
+	}
+	for i := 0; i < 20000; i++ {
+		if err := h.Add([]byte(fmt.Sprintf("user%d", i%10000))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	count := h.Count()
+	if count < 9500 || count > 10500 {
+		t.Errorf("got count %d, wanted about %d", count, 10000)
+	}
+
+	// Repeated items do not change the sketch
+	before := table.Stats().FileBytes
+	h.Add([]byte("user1"))
+	if after := table.Stats().FileBytes; after != before {
+		t.Errorf("got file bytes %d after repeated add, wanted %d", after, before)
+	}
+
+	table.PutString("name", "value")
+	if err := table.HLL("name").Add([]byte("x")); err != ErrWrongType {
+		t.Errorf("got error %v, wanted %v", err, ErrWrongType)
+	}
+	table.Close()
+
+	table2, err := New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table2.Close()
+	if got := table2.HLL("visitors").Count(); got != count {
+		t.Errorf("got count %d after reopen, wanted %d", got, count)
+	}
+}
@@ -0,0 +1,237 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+)
+
+// indexSuffix is appended to the name of the data file to form the name of
+// the file in which indexes are saved when the table is closed.
+const indexSuffix = ".idx"
+
+const indexMagic = "\x1f\x1fLASHIDX"
+
+// An index is a secondary structure derived from the values held by a table.
+// It is kept up to date as values are stored and deleted, including soft
+// deleted values, which must be excluded when the index is queried.
+type index interface {
+	add(k string, v []byte)
+	remove(k string, v []byte)
+	clear()
+
+	// marshal and unmarshal convert the index to and from the form in
+	// which it is saved. Keys are obtained from keys when unmarshalling so
+	// that the index shares them with the table.
+	marshal() []byte
+	unmarshal(b []byte, keys interner) error
+}
+
+// An index may also implement bulkIndex if it can be rebuilt from all of a
+// table's values more efficiently than by adding them one at a time.
+type bulkIndex interface {
+	index
+
+	// build replaces the contents of the index with the values passed to
+	// add by each, which are in no particular order.
+	build(each func(add func(k string, v []byte)))
+}
+
+// addIndex registers the index ix under the identifier id.
+func (t *Table) addIndex(id string, ix index) {
+	if t.indexes == nil {
+		t.indexes = make(map[string]index)
+	}
+	t.indexes[id] = ix
+}
+
+// indexValue adds the value v stored under key k to the table's indexes.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) indexValue(k string, v []byte) {
+	for _, ix := range t.indexes {
+		ix.add(k, v)
+	}
+}
+
+// unindexValue removes the value v stored under key k from the table's
+// indexes.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) unindexValue(k string, v []byte) {
+	for _, ix := range t.indexes {
+		ix.remove(k, v)
+	}
+}
+
+// loadIndexes populates the table's indexes once its data file has been
+// loaded. Indexes saved when the table was last closed are used if they
+// match the data file, otherwise the indexes are rebuilt from the table's
+// values. The saved indexes are then removed, unless the table is read
+// only, so that they cannot be mistaken for current ones after a crash.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) loadIndexes() {
+	if len(t.indexes) == 0 {
+		return
+	}
+	fname := t.filename + indexSuffix
+	t.buildIndexes(readIndexes(fname, t.seq))
+	if !t.readonly {
+		os.Remove(fname)
+	}
+}
+
+// buildIndexes populates the table's indexes from saved, which maps index
+// identifiers to their saved form, rebuilding any that are missing from it
+// or cannot be decoded.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) buildIndexes(saved map[string][]byte) {
+	var keys interner
+	if len(saved) > 0 {
+		keys = t.keyInterner()
+	}
+	for id, ix := range t.indexes {
+		ix.clear()
+		if b, ok := saved[id]; ok {
+			if err := ix.unmarshal(b, keys); err == nil {
+				continue
+			}
+			ix.clear()
+		}
+		if bix, ok := ix.(bulkIndex); ok {
+			bix.build(t.eachValue)
+			continue
+		}
+		t.eachValue(ix.add)
+	}
+}
+
+// eachValue calls fn with the key and value of every item in the table,
+// including soft deleted items. Values that cannot be read are logged and
+// skipped.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) eachValue(fn func(k string, v []byte)) {
+	for k, p := range t.data {
+		v, err := t.value(p)
+		if err != nil {
+			t.logger.Error("lash: failed to read value for index", "key", t.redactKey(k), "error", err)
+			continue
+		}
+		fn(k, v)
+	}
+}
+
+// readIndexes reads the indexes saved in the file fname. It returns nil if
+// the file does not exist, is damaged or was saved when the table's last
+// sequence number was not seq.
+func readIndexes(fname string, seq uint64) map[string][]byte {
+	b, err := os.ReadFile(fname)
+	if err != nil || len(b) < len(indexMagic)+crc32.Size || string(b[:len(indexMagic)]) != indexMagic {
+		return nil
+	}
+	sum := binary.BigEndian.Uint32(b[len(b)-crc32.Size:])
+	b = b[:len(b)-crc32.Size]
+	if crc32.Checksum(b, castagnoli) != sum {
+		return nil
+	}
+	b = b[len(indexMagic):]
+
+	saved, err := decodeIndexes(b, seq)
+	if err != nil {
+		return nil
+	}
+	return saved
+}
+
+func decodeIndexes(b []byte, seq uint64) (map[string][]byte, error) {
+	s, b, err := consumeUvarint(b)
+	if err != nil || s != seq {
+		return nil, ErrCorrupt
+	}
+	n, b, err := consumeUvarint(b)
+	if err != nil {
+		return nil, err
+	}
+	saved := make(map[string][]byte)
+	for i := uint64(0); i < n; i++ {
+		var id, payload []byte
+		id, b, err = consumeBytes(b)
+		if err != nil {
+			return nil, err
+		}
+		payload, b, err = consumeBytes(b)
+		if err != nil {
+			return nil, err
+		}
+		saved[string(id)] = payload
+	}
+	return saved, nil
+}
+
+// saveIndexes writes the table's indexes to a file alongside the data file
+// so they can be loaded rather than rebuilt when the table is next opened.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) saveIndexes() error {
+	if len(t.indexes) == 0 || t.filename == "" || t.readonly {
+		return nil
+	}
+
+	buf := []byte(indexMagic)
+	buf = binary.AppendUvarint(buf, t.seq)
+	buf = binary.AppendUvarint(buf, uint64(len(t.indexes)))
+	for id, ix := range t.indexes {
+		buf = appendBytes(buf, []byte(id))
+		buf = appendBytes(buf, ix.marshal())
+	}
+	buf = binary.BigEndian.AppendUint32(buf, crc32.Checksum(buf, castagnoli))
+
+	fname := t.filename + indexSuffix
+	tmpname := fname + compactSuffix
+	err := os.WriteFile(tmpname, buf, 0666)
+	if err == nil {
+		err = os.Rename(tmpname, fname)
+	}
+	if err != nil {
+		os.Remove(tmpname)
+	}
+	return err
+}
+
+// appendBytes appends b to buf, preceded by its length.
+func appendBytes(buf []byte, b []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+// uvarintLen returns the number of bytes occupied by x when encoded as a
+// uvarint.
+func uvarintLen(x uint64) int {
+	var buf [binary.MaxVarintLen64]byte
+	return binary.PutUvarint(buf[:], x)
+}
+
+// consumeUvarint decodes a uvarint from the start of b and returns it along
+// with the remainder of b.
+func consumeUvarint(b []byte) (uint64, []byte, error) {
+	v, n := binary.Uvarint(b)
+	if n <= 0 {
+		return 0, nil, ErrCorrupt
+	}
+	return v, b[n:], nil
+}
+
+// consumeBytes decodes a byte slice written by appendBytes from the start of
+// b and returns it along with the remainder of b.
+func consumeBytes(b []byte) ([]byte, []byte, error) {
+	n, b, err := consumeUvarint(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n > uint64(len(b)) {
+		return nil, nil, ErrCorrupt
+	}
+	return b[:n], b[n:], nil
+}
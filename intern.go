@@ -0,0 +1,32 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+// An interner holds a single copy of each distinct key so that structures
+// referring to the same key, such as the table's items and its indexes,
+// share the key's bytes rather than each holding a copy.
+type interner map[string]string
+
+// keyInterner returns an interner holding the keys of the table's items.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) keyInterner() interner {
+	in := make(interner, len(t.data))
+	for k := range t.data {
+		in[k] = k
+	}
+	return in
+}
+
+// intern returns the copy of the key held in b, adding one if the interner
+// does not hold the key.
+func (in interner) intern(b []byte) string {
+	if s, ok := in[string(b)]; ok {
+		return s
+	}
+	s := string(b)
+	in[s] = s
+	return s
+}
@@ -0,0 +1,62 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"testing"
+	"unsafe"
+)
+
+func TestInternSavedIndexKeys(t *testing.T) {
+	tf, err := os.CreateTemp("", "lash")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tf.Close()
+	defer os.Remove(tf.Name())
+	defer os.Remove(tf.Name() + indexSuffix)
+
+	table, err := New(tf.Name(), 50, WithSearchIndex(nil))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table.PutString("a", "the quick brown fox")
+	table.PutString("b", "the lazy dog")
+	if err := table.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table, err = New(tf.Name(), 50, WithSearchIndex(nil))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+
+	data := make(map[string]*byte)
+	for k := range table.data {
+		data[k] = unsafe.StringData(k)
+	}
+	for tok, keys := range table.search.postings {
+		for k := range keys {
+			if unsafe.StringData(k) != data[k] {
+				t.Errorf("key %q for token %q is not shared with the table", k, tok)
+			}
+		}
+	}
+}
+
+func TestInterner(t *testing.T) {
+	in := make(interner)
+	a := in.intern([]byte("key"))
+	b := in.intern([]byte("key"))
+	if a != "key" {
+		t.Errorf("got %q, wanted %q", a, "key")
+	}
+	if unsafe.StringData(a) != unsafe.StringData(b) {
+		t.Errorf("got distinct copies of the key, wanted one")
+	}
+}
@@ -0,0 +1,74 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrIO is matched, using errors.Is, by the errors returned when reading or
+// writing one of the table's files fails. These errors name the operation
+// that failed and, where there is one, the offset of the record involved and
+// the key of its item, followed by the underlying error, which usually names
+// the file and can itself be matched using errors.Is or errors.As. Corrupt
+// records are reported by errors that match ErrCorrupt or ErrChecksum
+// rather than ErrIO, but which name the operation and offset in the same
+// way.
+var ErrIO = errors.New("lash: i/o error")
+
+// A corruptError reports a corrupt record, as described by desc.
+type corruptError struct {
+	desc string
+	err  error
+}
+
+func (e *corruptError) Error() string {
+	return e.desc + ": " + e.err.Error()
+}
+
+func (e *corruptError) Unwrap() error {
+	return e.err
+}
+
+// ioError wraps err, which was returned while performing the operation op on
+// one of the table's files, as an ErrIO error, or as a corruptError if it
+// reports a corrupt record. It returns err unchanged if it is nil or has
+// already been wrapped.
+func ioError(op string, err error) error {
+	if err == nil || wrappedIOError(err) {
+		return err
+	}
+	if corrupt(err) {
+		return &corruptError{desc: op, err: err}
+	}
+	return fmt.Errorf("%w: %s: %w", ErrIO, op, err)
+}
+
+// recordIOError wraps err, which was returned while performing the operation
+// op on the record at offset pos in one of the table's files, in the same
+// way as ioError. The record holds the item stored under key k unless k is
+// empty, and its offset is not known if pos is negative.
+func (t *Table) recordIOError(op string, pos int64, k string, err error) error {
+	if err == nil || wrappedIOError(err) {
+		return err
+	}
+	desc := op + " record"
+	if k != "" {
+		desc += fmt.Sprintf(" for key %q", t.redactKey(k))
+	}
+	if pos >= 0 {
+		desc += fmt.Sprintf(" at offset %d", pos)
+	}
+	return ioError(desc, err)
+}
+
+// wrappedIOError reports whether err has been wrapped by ioError or
+// recordIOError.
+func wrappedIOError(err error) bool {
+	var cerr *corruptError
+	return errors.Is(err, ErrIO) || errors.As(err, &cerr)
+}
@@ -0,0 +1,89 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestIOError(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	// Writes to a file opened for reading fail
+	ro, err := os.Open(tf.Name())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer ro.Close()
+	table.mtx.Lock()
+	dbfile := table.dbfile
+	table.dbfile = ro
+	table.mtx.Unlock()
+
+	err = table.Put("a", []byte("value"))
+	if !errors.Is(err, ErrIO) {
+		t.Fatalf("got error %v, wanted %v", err, ErrIO)
+	}
+	var perr *fs.PathError
+	if !errors.As(err, &perr) || perr.Path != tf.Name() {
+		t.Errorf("got error %v, wanted it to wrap the error for %s", err, tf.Name())
+	}
+	for _, want := range []string{"write record", `key "a"`, "offset"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("got error %q, wanted it to contain %q", err, want)
+		}
+	}
+
+	table.mtx.Lock()
+	table.dbfile = dbfile
+	table.mtx.Unlock()
+	if err := table.Put("a", []byte("value")); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestIOErrorCorrupt(t *testing.T) {
+	err := ioError("load", ErrCorrupt)
+	if !errors.Is(err, ErrCorrupt) {
+		t.Errorf("got error %v, wanted it to match %v", err, ErrCorrupt)
+	}
+	table, err := New("", 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = table.recordIOError("load", 10, "a", ErrChecksum)
+	if !errors.Is(err, ErrChecksum) || errors.Is(err, ErrIO) {
+		t.Errorf("got error %v, wanted it to match %v and not %v", err, ErrChecksum, ErrIO)
+	}
+	for _, want := range []string{"load record", `key "a"`, "offset 10"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("got error %q, wanted it to contain %q", err, want)
+		}
+	}
+	if again := ioError("load", err); again != err {
+		t.Errorf("got error %v wrapped again, wanted it unchanged", again)
+	}
+	if err := ioError("load", nil); err != nil {
+		t.Errorf("got error %v, wanted nil", err)
+	}
+
+	err = table.recordIOError("read", 10, "", os.ErrClosed)
+	if !errors.Is(err, ErrIO) || !errors.Is(err, os.ErrClosed) {
+		t.Errorf("got error %v, wanted it to match %v and %v", err, ErrIO, os.ErrClosed)
+	}
+	if again := ioError("compact", err); again != err {
+		t.Errorf("got error %v wrapped again, wanted it unchanged", again)
+	}
+}
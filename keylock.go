@@ -0,0 +1,63 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"slices"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// keyLockStripes is the number of locks shared between keys by LockKey and
+// LockKeys.
+const keyLockStripes = 256
+
+// LockKey locks key k and returns a function that unlocks it. It allows a
+// long read-modify-write of a single key, such as a Get followed by
+// expensive processing and a Put, to exclude other callers of LockKey for
+// the same key without blocking writes to other keys as a table-wide lock
+// would. The lock is advisory: it does not prevent methods such as Put from
+// modifying the key, so every writer of the key must call LockKey or
+// LockKeys.
+//
+// Keys share a fixed number of locks so LockKey may occasionally wait for
+// an unrelated key. For the same reason a caller holding the lock of one
+// key must not call LockKey for another, even in a consistent order, since
+// two keys may share a lock and the order of their locks need not match
+// the order of the keys. LockKeys must be used to lock several keys at
+// once. The returned function must be called exactly once.
+func (t *Table) LockKey(k string) (unlock func()) {
+	mu := &t.keylocks[keyStripe(k)]
+	mu.Lock()
+	return mu.Unlock
+}
+
+// LockKeys locks all of the keys ks, which may include duplicates, and
+// returns a function that unlocks them. The locks are acquired in a fixed
+// order, and each shared lock only once, so that callers locking
+// overlapping sets of keys cannot deadlock. Like LockKey, the lock is
+// advisory. The returned function must be called exactly once.
+func (t *Table) LockKeys(ks ...string) (unlock func()) {
+	stripes := make([]uint64, len(ks))
+	for i, k := range ks {
+		stripes[i] = keyStripe(k)
+	}
+	slices.Sort(stripes)
+	stripes = slices.Compact(stripes)
+	for _, s := range stripes {
+		t.keylocks[s].Lock()
+	}
+	return func() {
+		for i := len(stripes) - 1; i >= 0; i-- {
+			t.keylocks[stripes[i]].Unlock()
+		}
+	}
+}
+
+// keyStripe returns the index of the lock shared by key k.
+func keyStripe(k string) uint64 {
+	return xxhash.Sum64String(k) % keyLockStripes
+}
@@ -0,0 +1,114 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+func TestLockKey(t *testing.T) {
+	table, err := New("", 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	const workers, incrs = 8, 50
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < incrs; j++ {
+				unlock := table.LockKey("n")
+				s, _ := table.GetString("n")
+				n, _ := strconv.Atoi(s)
+				if err := table.PutString("n", strconv.Itoa(n+1)); err != nil {
+					t.Error(err.Error())
+				}
+				unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got, _ := table.GetString("n"); got != strconv.Itoa(workers*incrs) {
+		t.Errorf("got %q, wanted %q", got, strconv.Itoa(workers*incrs))
+	}
+}
+
+func TestLockKeyOtherKeys(t *testing.T) {
+	table, err := New("", 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	other := "b"
+	for xxhash.Sum64String(other)%keyLockStripes == xxhash.Sum64String("a")%keyLockStripes {
+		other += "b"
+	}
+
+	unlock := table.LockKey("a")
+	defer unlock()
+
+	done := make(chan struct{})
+	go func() {
+		table.LockKey(other)()
+		table.PutString("a", "v")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("locking another key blocked")
+	}
+}
+
+func TestLockKeys(t *testing.T) {
+	table, err := New("", 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Keys that share a lock, locked in opposite orders by different callers
+	same := "b"
+	for keyStripe(same) != keyStripe("a") {
+		same += "b"
+	}
+	sets := [][]string{{"a", same, "c"}, {"c", same, "a", "a"}}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for _, ks := range sets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				unlock := table.LockKeys(ks...)
+				s, _ := table.GetString("n")
+				n, _ := strconv.Atoi(s)
+				table.PutString("n", strconv.Itoa(n+1))
+				unlock()
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("locking keys deadlocked")
+	}
+	if got, _ := table.GetString("n"); got != "400" {
+		t.Errorf("got %q, wanted %q", got, "400")
+	}
+}
@@ -0,0 +1,109 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// The key helpers in this file produce encodings whose bytewise ordering
+// matches the natural ordering of the values they encode, so keys built with
+// them sort correctly when compared as strings.
+
+// ErrInvalidKey is returned when a key cannot be decoded by one of the key
+// helpers.
+var ErrInvalidKey = errors.New("lash: invalid key encoding")
+
+// Uint64Key returns an order-preserving key encoding of n.
+func Uint64Key(n uint64) string {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], n)
+	return string(buf[:])
+}
+
+// ParseUint64Key decodes a key produced by Uint64Key.
+func ParseUint64Key(k string) (uint64, error) {
+	if len(k) != 8 {
+		return 0, ErrInvalidKey
+	}
+	return binary.BigEndian.Uint64([]byte(k)), nil
+}
+
+// TimeKey returns an order-preserving key encoding of tm with nanosecond
+// precision. The location of tm is not preserved.
+func TimeKey(tm time.Time) string {
+	// Flipping the sign bit makes negative times sort before positive ones
+	return Uint64Key(uint64(tm.UnixNano()) ^ (1 << 63))
+}
+
+// ParseTimeKey decodes a key produced by TimeKey. The returned time is in
+// the UTC location.
+func ParseTimeKey(k string) (time.Time, error) {
+	n, err := ParseUint64Key(k)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, int64(n^(1<<63))).UTC(), nil
+}
+
+// CompositeKey returns an order-preserving key encoding of a sequence of parts.
+// Keys compare first by their first part, then by their second and so on, with
+// a shorter sequence sorting before any longer sequence that it prefixes. Each
+// part may contain arbitrary bytes. Parts may themselves be keys produced by
+// Uint64Key or TimeKey.
+func CompositeKey(parts ...[]byte) string {
+	n := 0
+	for _, p := range parts {
+		n += len(p) + 2
+	}
+	buf := make([]byte, 0, n)
+	for _, p := range parts {
+		// Zero bytes are escaped as 0x00 0xff and each part is terminated
+		// by 0x00 0x01, which sorts before any escaped zero byte.
+		for _, b := range p {
+			buf = append(buf, b)
+			if b == 0x00 {
+				buf = append(buf, 0xff)
+			}
+		}
+		buf = append(buf, 0x00, 0x01)
+	}
+	return string(buf)
+}
+
+// SplitCompositeKey decodes a key produced by CompositeKey into its parts.
+func SplitCompositeKey(k string) ([][]byte, error) {
+	var parts [][]byte
+	var part []byte
+	for i := 0; i < len(k); i++ {
+		if k[i] != 0x00 {
+			part = append(part, k[i])
+			continue
+		}
+		if i+1 >= len(k) {
+			return nil, ErrInvalidKey
+		}
+		i++
+		switch k[i] {
+		case 0xff:
+			part = append(part, 0x00)
+		case 0x01:
+			if part == nil {
+				part = []byte{}
+			}
+			parts = append(parts, part)
+			part = nil
+		default:
+			return nil, ErrInvalidKey
+		}
+	}
+	if part != nil {
+		return nil, ErrInvalidKey
+	}
+	return parts, nil
+}
@@ -0,0 +1,132 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"bytes"
+	"os"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestUint64KeyOrder(t *testing.T) {
+	nums := []uint64{0, 1, 30, 31, 255, 256, 1 << 32, 1<<64 - 1}
+	for i := 1; i < len(nums); i++ {
+		a, b := Uint64Key(nums[i-1]), Uint64Key(nums[i])
+		if a >= b {
+			t.Errorf("key for %d does not sort before key for %d", nums[i-1], nums[i])
+		}
+	}
+
+	for _, n := range nums {
+		got, err := ParseUint64Key(Uint64Key(n))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != n {
+			t.Errorf("got %d, wanted %d", got, n)
+		}
+	}
+}
+
+func TestTimeKeyOrder(t *testing.T) {
+	times := []time.Time{
+		time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Unix(0, 0),
+		time.Unix(0, 1),
+		time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC),
+		time.Date(2020, 6, 1, 12, 0, 0, 1, time.UTC),
+	}
+	for i := 1; i < len(times); i++ {
+		a, b := TimeKey(times[i-1]), TimeKey(times[i])
+		if a >= b {
+			t.Errorf("key for %v does not sort before key for %v", times[i-1], times[i])
+		}
+	}
+
+	for _, tm := range times {
+		got, err := ParseTimeKey(TimeKey(tm))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !got.Equal(tm) {
+			t.Errorf("got %v, wanted %v", got, tm)
+		}
+	}
+}
+
+func TestCompositeKeyOrder(t *testing.T) {
+	tuples := [][][]byte{
+		{[]byte("a")},
+		{[]byte("a"), []byte("")},
+		{[]byte("a"), []byte("\x00")},
+		{[]byte("a"), []byte("b")},
+		{[]byte("a\x00")},
+		{[]byte("a\x00b")},
+		{[]byte("ab")},
+		{[]byte("b"), []byte(Uint64Key(1))},
+		{[]byte("b"), []byte(Uint64Key(256))},
+	}
+
+	keys := make([]string, len(tuples))
+	for i, tup := range tuples {
+		keys[i] = CompositeKey(tup...)
+	}
+	if !sort.StringsAreSorted(keys) {
+		t.Errorf("composite keys do not sort in tuple order")
+	}
+
+	for i, k := range keys {
+		parts, err := SplitCompositeKey(k)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(parts) != len(tuples[i]) {
+			t.Fatalf("got %d parts, wanted %d", len(parts), len(tuples[i]))
+		}
+		for j := range parts {
+			if !bytes.Equal(parts[j], tuples[i][j]) {
+				t.Errorf("got part %q, wanted %q", parts[j], tuples[i][j])
+			}
+		}
+	}
+
+	_, err := SplitCompositeKey("a\x00")
+	if err != ErrInvalidKey {
+		t.Errorf("got error %v, wanted %v", err, ErrInvalidKey)
+	}
+}
+
+func TestBinaryKeyPersist(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	// Keys containing the legacy separator byte must survive a reload
+	k := Uint64Key(31)
+	err = table.Put(k, []byte("val"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Close()
+
+	table2, err := New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table2.Close()
+
+	v, found := table2.Get(k)
+	if !found {
+		t.Fatalf("got not found, wanted found")
+	}
+	if string(v) != "val" {
+		t.Errorf("got %q, wanted %q", v, "val")
+	}
+}
@@ -0,0 +1,301 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+// Package lashbackup uploads backups of a lash Table to an object store, such
+// as one implementing the S3 API, and restores tables from them. Backups are
+// made in chains, each consisting of a full backup followed by incremental
+// backups holding the writes made since the previous backup in the chain.
+package lashbackup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iand/lash"
+)
+
+// ErrNoBackups is returned by Restore when the store holds no suitable
+// backups.
+var ErrNoBackups = errors.New("lashbackup: no backups found")
+
+// DefaultKeyPrefix is prepended to the names of the objects holding backups,
+// unless changed using WithKeyPrefix.
+const DefaultKeyPrefix = "lash/"
+
+// Defaults for the options of an Uploader.
+const (
+	defaultFullEvery = 24
+	defaultRetain    = 7
+	defaultInterval  = time.Hour
+)
+
+// chainTimeFormat formats the time at which a chain was started so that the
+// names of chains sort in time order.
+const chainTimeFormat = "20060102T150405.000000000Z"
+
+// An ObjectStore holds objects under string keys. It is typically a thin
+// adapter over the client for an S3 compatible service.
+type ObjectStore interface {
+	// Put stores the size bytes read from r under key.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+
+	// Get returns a reader for the object stored under key.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// List returns the keys of every object whose key begins with prefix,
+	// in any order.
+	List(ctx context.Context, prefix string) ([]string, error)
+
+	// Delete removes the object stored under key.
+	Delete(ctx context.Context, key string) error
+}
+
+// An Option configures an Uploader when it is created by New.
+type Option func(*Uploader)
+
+// WithKeyPrefix sets the prefix prepended to the names of the objects that
+// hold backups. The default is DefaultKeyPrefix.
+func WithKeyPrefix(prefix string) Option {
+	return func(u *Uploader) {
+		u.prefix = prefix
+	}
+}
+
+// WithFullEvery sets the number of incremental backups made after each full
+// backup before another full backup is made. The default is 24.
+func WithFullEvery(n int) Option {
+	return func(u *Uploader) {
+		u.fullEvery = n
+	}
+}
+
+// WithRetention sets the number of chains of backups retained in the store.
+// Older chains are deleted once a new full backup has been uploaded. The
+// default is 7.
+func WithRetention(n int) Option {
+	return func(u *Uploader) {
+		u.retain = n
+	}
+}
+
+// WithInterval sets the period between the backups made by Run. The default
+// is one hour.
+func WithInterval(d time.Duration) Option {
+	return func(u *Uploader) {
+		u.interval = d
+	}
+}
+
+// WithErrorHandler sets a function that is passed the errors encountered by
+// Run, which continues making backups after an error.
+func WithErrorHandler(fn func(error)) Option {
+	return func(u *Uploader) {
+		u.onError = fn
+	}
+}
+
+// An Uploader makes backups of a Table and uploads them to an ObjectStore.
+type Uploader struct {
+	mu        sync.Mutex // serialises backups
+	t         *lash.Table
+	store     ObjectStore
+	prefix    string
+	fullEvery int
+	retain    int
+	interval  time.Duration
+	onError   func(error)
+	now       func() time.Time
+
+	chain  string // name of the current chain, empty before the first full backup
+	next   int    // position of the next backup in the chain
+	offset int64  // offset to pass to BackupSince for the next incremental backup
+}
+
+// New returns an Uploader that uploads backups of t to store.
+func New(t *lash.Table, store ObjectStore, opts ...Option) *Uploader {
+	u := &Uploader{
+		t:         t,
+		store:     store,
+		prefix:    DefaultKeyPrefix,
+		fullEvery: defaultFullEvery,
+		retain:    defaultRetain,
+		interval:  defaultInterval,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
+}
+
+// Run makes a backup immediately and then once every interval until ctx is
+// cancelled, when it returns the context's error. Errors encountered while
+// making backups are passed to the error handler, if any.
+func (u *Uploader) Run(ctx context.Context) error {
+	ticker := time.NewTicker(u.interval)
+	defer ticker.Stop()
+	for {
+		if err := u.Backup(ctx); err != nil && u.onError != nil {
+			u.onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Backup makes a single backup and uploads it. A full backup is made if there
+// is no current chain, if the chain already holds the configured number of
+// incremental backups or if the table's data file has been compacted since
+// the last backup. Otherwise an incremental backup is made. Chains beyond
+// the retention limit are deleted after a full backup has been uploaded.
+func (u *Uploader) Backup(ctx context.Context) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	f, err := os.CreateTemp("", "lashbackup")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	chain, next := u.chain, u.next
+	var offset int64
+	if chain != "" && next <= u.fullEvery {
+		offset, err = u.t.BackupSince(u.offset, f)
+		if err != nil && err != lash.ErrBackupOffset {
+			return err
+		}
+		if err == lash.ErrBackupOffset {
+			chain = ""
+			if err := f.Truncate(0); err != nil {
+				return err
+			}
+		}
+	}
+	if chain == "" || next > u.fullEvery {
+		chain, next = u.now().UTC().Format(chainTimeFormat), 0
+		offset, err = u.t.BackupSince(0, f)
+		if err != nil {
+			return err
+		}
+	}
+
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := u.store.Put(ctx, u.key(chain, next), f, size); err != nil {
+		return err
+	}
+	u.chain, u.next, u.offset = chain, next+1, offset
+
+	if next == 0 {
+		return u.prune(ctx)
+	}
+	return nil
+}
+
+// key returns the name of the object holding the backup at position i in
+// the chain.
+func (u *Uploader) key(chain string, i int) string {
+	return fmt.Sprintf("%s%s/%06d", u.prefix, chain, i)
+}
+
+// prune deletes chains beyond the retention limit.
+func (u *Uploader) prune(ctx context.Context) error {
+	chains, err := listChains(ctx, u.store, u.prefix)
+	if err != nil {
+		return err
+	}
+	if len(chains) <= u.retain {
+		return nil
+	}
+	for _, c := range chains[:len(chains)-u.retain] {
+		for _, key := range c.keys {
+			if err := u.store.Delete(ctx, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type chain struct {
+	name    string
+	started time.Time
+	keys    []string // keys of the backups in the chain, in order
+}
+
+// listChains returns the chains of backups held in store under prefix, in
+// the order in which they were started.
+func listChains(ctx context.Context, store ObjectStore, prefix string) ([]chain, error) {
+	keys, err := store.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+
+	var chains []chain
+	for _, key := range keys {
+		name, _, ok := strings.Cut(strings.TrimPrefix(key, prefix), "/")
+		if !ok {
+			continue
+		}
+		started, err := time.Parse(chainTimeFormat, name)
+		if err != nil {
+			continue
+		}
+		if len(chains) == 0 || chains[len(chains)-1].name != name {
+			chains = append(chains, chain{name: name, started: started})
+		}
+		c := &chains[len(chains)-1]
+		c.keys = append(c.keys, key)
+	}
+	return chains, nil
+}
+
+// Restore creates a data file named fname from the backups held in store
+// under prefix, applying the writes they hold up to the restore point p as
+// lash.RestoreBackups does. It uses the latest chain of backups, or if p
+// specifies a time then the latest chain started at or before that time.
+// It returns the sequence number of the last write applied.
+func Restore(ctx context.Context, store ObjectStore, prefix string, fname string, p lash.RestorePoint) (uint64, error) {
+	chains, err := listChains(ctx, store, prefix)
+	if err != nil {
+		return 0, err
+	}
+	for !p.Time.IsZero() && len(chains) > 0 && chains[len(chains)-1].started.After(p.Time) {
+		chains = chains[:len(chains)-1]
+	}
+	if len(chains) == 0 {
+		return 0, ErrNoBackups
+	}
+
+	c := chains[len(chains)-1]
+	backups := make([]io.Reader, 0, len(c.keys))
+	for _, key := range c.keys {
+		rc, err := store.Get(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		defer rc.Close()
+		backups = append(backups, rc)
+	}
+	return lash.RestoreBackups(fname, p, backups...)
+}
@@ -0,0 +1,192 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lashbackup
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iand/lash"
+)
+
+// memStore is an ObjectStore that holds objects in memory.
+type memStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newMemStore() *memStore {
+	return &memStore{objects: make(map[string][]byte)}
+}
+
+func (m *memStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = data
+	return nil
+}
+
+func (m *memStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *memStore) List(ctx context.Context, prefix string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []string
+	for k := range m.objects {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+func (m *memStore) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *memStore) keys() []string {
+	keys, _ := m.List(context.Background(), "")
+	sort.Strings(keys)
+	return keys
+}
+
+func makeTable(t *testing.T) *lash.Table {
+	table, err := lash.New(filepath.Join(t.TempDir(), "lash"), 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	t.Cleanup(func() { table.Close() })
+	return table
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	ctx := context.Background()
+	table := makeTable(t)
+	store := newMemStore()
+
+	// The table records the current time for each write, so backups are
+	// taken at hourly intervals ending after the writes were made
+	start := time.Now().UTC().Add(-5 * time.Hour)
+	clock := start
+	u := New(table, store, WithFullEvery(2), WithRetention(2))
+	u.now = func() time.Time { return clock }
+
+	// Each chain holds a full backup followed by two incrementals
+	for _, k := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		if err := table.Put(k, []byte(k)); err != nil {
+			t.Fatal(err.Error())
+		}
+		if err := u.Backup(ctx); err != nil {
+			t.Fatal(err.Error())
+		}
+		clock = clock.Add(time.Hour)
+	}
+
+	// The first chain has been removed by the retention policy
+	second := start.Add(3 * time.Hour).Format(chainTimeFormat)
+	third := start.Add(6 * time.Hour).Format(chainTimeFormat)
+	want := []string{
+		"lash/" + second + "/000000",
+		"lash/" + second + "/000001",
+		"lash/" + second + "/000002",
+		"lash/" + third + "/000000",
+	}
+	if got := store.keys(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("got objects %q, wanted %q", got, want)
+	}
+
+	fname := filepath.Join(t.TempDir(), "restored")
+	if _, err := Restore(ctx, store, DefaultKeyPrefix, fname, lash.RestorePoint{}); err != nil {
+		t.Fatal(err.Error())
+	}
+	restored, err := lash.New(fname, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer restored.Close()
+	for _, k := range []string{"a", "g"} {
+		v, ok := restored.Get(k)
+		if !ok || string(v) != k {
+			t.Errorf("got %q, wanted %q", v, k)
+		}
+	}
+
+	// Restoring to a time before the latest chain uses the earlier one
+	fname = filepath.Join(t.TempDir(), "restored")
+	p := lash.RestorePoint{Time: start.Add(5*time.Hour + 30*time.Minute)}
+	if _, err := Restore(ctx, store, DefaultKeyPrefix, fname, p); err != nil {
+		t.Fatal(err.Error())
+	}
+	restored, err = lash.New(fname, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer restored.Close()
+	if _, ok := restored.Get("g"); ok {
+		t.Errorf("got value for %q, wanted none", "g")
+	}
+	for _, k := range []string{"a", "f"} {
+		v, ok := restored.Get(k)
+		if !ok || string(v) != k {
+			t.Errorf("got %q, wanted %q", v, k)
+		}
+	}
+
+	p = lash.RestorePoint{Time: start}
+	if _, err := Restore(ctx, store, DefaultKeyPrefix, fname+"x", p); err != ErrNoBackups {
+		t.Errorf("got %v, wanted %v", err, ErrNoBackups)
+	}
+}
+
+func TestBackupAfterCompact(t *testing.T) {
+	ctx := context.Background()
+	table := makeTable(t)
+	store := newMemStore()
+	u := New(table, store)
+
+	if err := table.Put("a", []byte("a")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := u.Backup(ctx); err != nil {
+		t.Fatal(err.Error())
+	}
+	chain := u.chain
+
+	// Compaction invalidates the offset so a new chain is started
+	if err := table.Compact(); err != nil {
+		t.Fatal(err.Error())
+	}
+	u.now = func() time.Time { return time.Now().Add(time.Hour) }
+	if err := u.Backup(ctx); err != nil {
+		t.Fatal(err.Error())
+	}
+	if u.chain == chain || u.next != 1 {
+		t.Errorf("got chain %q at %d, wanted a new chain", u.chain, u.next)
+	}
+}
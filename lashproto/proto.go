@@ -0,0 +1,50 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+// Package lashproto provides helpers for storing protocol buffer messages
+// in a lash Table.
+package lashproto
+
+import (
+	"github.com/iand/lash"
+	"google.golang.org/protobuf/proto"
+)
+
+// Table wraps a lash Table with methods that marshal and unmarshal protocol
+// buffer messages. All of the methods of the underlying table remain available.
+type Table struct {
+	*lash.Table
+}
+
+// Wrap returns a Table that stores protocol buffer messages in t.
+func Wrap(t *lash.Table) *Table {
+	return &Table{Table: t}
+}
+
+// PutProto marshals the message m and stores it under key k in the table.
+// Any error encountered while marshalling or persisting the data will be
+// returned.
+func (t *Table) PutProto(k string, m proto.Message) error {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return t.Put(k, b)
+}
+
+// GetProto retrieves the value stored under key k and unmarshals it into m.
+// It returns a boolean that indicates whether the value was found in the table
+// or not and any error encountered while unmarshalling. If the value was not
+// found then m is left unchanged.
+func (t *Table) GetProto(k string, m proto.Message) (bool, error) {
+	b, found := t.Get(k)
+	if !found {
+		return false, nil
+	}
+	if err := proto.Unmarshal(b, m); err != nil {
+		return true, err
+	}
+	return true, nil
+}
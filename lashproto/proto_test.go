@@ -0,0 +1,70 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lashproto
+
+import (
+	"testing"
+
+	"github.com/iand/lash"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestPutProto(t *testing.T) {
+	lt, err := lash.New("", 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer lt.Close()
+
+	table := Wrap(lt)
+	err = table.PutProto("a", wrapperspb.String("val"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var m wrapperspb.StringValue
+	found, err := table.GetProto("a", &m)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !found {
+		t.Fatalf("got not found, wanted found")
+	}
+	if m.GetValue() != "val" {
+		t.Errorf("got %q, wanted %q", m.GetValue(), "val")
+	}
+
+	found, err = table.GetProto("b", &m)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if found {
+		t.Errorf("got found, wanted not found")
+	}
+}
+
+func TestGetProtoInvalid(t *testing.T) {
+	lt, err := lash.New("", 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer lt.Close()
+
+	table := Wrap(lt)
+	err = table.Put("a", []byte{0xff, 0xff})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var m wrapperspb.StringValue
+	found, err := table.GetProto("a", &m)
+	if !found {
+		t.Errorf("got not found, wanted found")
+	}
+	if err == nil {
+		t.Errorf("got no error, wanted unmarshal error")
+	}
+}
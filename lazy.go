@@ -0,0 +1,138 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/iand/lash/format"
+)
+
+// WithMaxLoadBytes limits the total size of the values loaded into memory
+// when the table is opened to n bytes. Once the limit is reached the values
+// of later records are left in the data file and only their positions are
+// held in memory. Such values are read from the data file whenever they are
+// needed, such as by Get, so opening a data file much larger than memory
+// does not exhaust it. Values written after the table is opened are held in
+// memory. Values left in the data file cannot be read once the table has
+// been closed. There is no limit by default or if n is less than one.
+// WithMaxLoadBytes cannot be used with WithReadOnly.
+func WithMaxLoadBytes(n int64) Option {
+	return func(t *Table) {
+		t.maxLoad = n
+	}
+}
+
+// lazy reports whether a value of n bytes should be left in the data file
+// rather than loaded into memory, counting it against the table's load
+// budget if not.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) lazy(n int) bool {
+	if t.maxLoad <= 0 {
+		return false
+	}
+	if t.loaded+int64(n) > t.maxLoad {
+		return true
+	}
+	t.loaded += int64(n)
+	return false
+}
+
+// value returns the value of the item p, reading it from the data file if
+// it is not held in memory or encoding it if it is held as a collection.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) value(p item) ([]byte, error) {
+	if p.coll != nil {
+		return p.coll.encode(), nil
+	}
+	if !p.onDisk {
+		return p.val, nil
+	}
+	f, checksum, codec := t.dbfile, t.checksum, t.codec
+	if p.cold {
+		f, checksum, codec = t.coldfile, t.coldChecksum, t.coldCodec
+	}
+	if f == nil {
+		return nil, errors.New("database not open")
+	}
+
+	// Records are decoded with a header that omits any compression so the
+	// codec is not rebuilt for every read
+	hdr := appendHeader(nil, checksum, nil)
+	r := io.MultiReader(bytes.NewReader(hdr), io.NewSectionReader(f, p.pos, p.size))
+	d, err := format.NewDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := d.Next()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, t.recordIOError("read", p.pos, "", err)
+	}
+	if rec.Kind != kindPut {
+		return nil, ErrCorrupt
+	}
+	if codec == nil {
+		return rec.Value, nil
+	}
+	return codec.Decompress(nil, rec.Value)
+}
+
+// itemSize returns the number of bytes occupied by the record of the item p
+// stored under key k in the data file, which is zero if the record is in the
+// cold file.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) itemSize(k string, p item) int64 {
+	if p.cold {
+		return 0
+	}
+	if p.size != 0 {
+		return p.size
+	}
+	// The value is not held by a record, so the size of the record that
+	// would hold it is found from its length, ignoring any compression, so
+	// that it need not be compressed or, for a collection, encoded
+	rec := record{kind: kindPut, key: k, val: p.val, created: p.created, updated: p.updated, writes: p.writes, seq: p.seq}
+	if p.coll == nil {
+		return recordSize(rec, t.checksum)
+	}
+	n := p.coll.size
+	return recordSize(rec, t.checksum) - int64(uvarintLen(0)) + int64(uvarintLen(uint64(n))+n)
+}
+
+// indexItem adds the value of the item p stored under key k to the table's
+// indexes, reading it from the data file if necessary.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) indexItem(k string, p item) {
+	if len(t.indexes) == 0 {
+		return
+	}
+	v, err := t.value(p)
+	if err != nil {
+		t.logger.Error("lash: failed to read value for index", "key", t.redactKey(k), "error", err)
+		return
+	}
+	t.indexValue(k, v)
+}
+
+// unindexItem removes the value of the item p stored under key k from the
+// table's indexes, reading it from the data file if necessary.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) unindexItem(k string, p item) {
+	if len(t.indexes) == 0 {
+		return
+	}
+	v, err := t.value(p)
+	if err != nil {
+		t.logger.Error("lash: failed to read value for index", "key", t.redactKey(k), "error", err)
+		return
+	}
+	t.unindexValue(k, v)
+}
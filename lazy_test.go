@@ -0,0 +1,120 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestMaxLoadBytes(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compress=%v", compress), func(t *testing.T) {
+			var opts []Option
+			if compress {
+				opts = append(opts, WithCompression())
+			}
+			table, tf, err := makeTable(50)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			defer os.Remove(tf.Name())
+
+			want := make(map[string][]byte)
+			for i := 0; i < 10; i++ {
+				k := fmt.Sprintf("key%d", i)
+				want[k] = bytes.Repeat([]byte{byte('a' + i)}, 100)
+				if err := table.Put(k, want[k]); err != nil {
+					t.Fatal(err.Error())
+				}
+			}
+			if _, err := table.Counters().Incr("counter", 5); err != nil {
+				t.Fatal(err.Error())
+			}
+			table.Close()
+
+			table, err = New(tf.Name(), 50, append(opts, WithMaxLoadBytes(300))...)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			lazy := 0
+			for _, p := range table.data {
+				if p.onDisk {
+					lazy++
+				}
+			}
+			if lazy < 7 {
+				t.Errorf("got %d values left on disk, wanted at least %d", lazy, 7)
+			}
+			for k, v := range want {
+				if got, ok := table.Get(k); !ok || !bytes.Equal(got, v) {
+					t.Errorf("got %q for %q, wanted %q", got, k, v)
+				}
+			}
+
+			// Values on disk may be modified, deleted and compacted
+			if n, err := table.Counters().Incr("counter", 1); err != nil || n != 6 {
+				t.Errorf("got counter %d (error %v), wanted %d", n, err, 6)
+			}
+			want["key8"] = []byte("new value")
+			if err := table.Put("key8", want["key8"]); err != nil {
+				t.Fatal(err.Error())
+			}
+			delete(want, "key9")
+			if err := table.Delete("key9"); err != nil {
+				t.Fatal(err.Error())
+			}
+			if err := table.Compact(); err != nil {
+				t.Fatal(err.Error())
+			}
+			if s := table.Stats(); s.GarbageBytes != 0 {
+				t.Errorf("got %d garbage bytes after compaction, wanted %d", s.GarbageBytes, 0)
+			}
+			for k, v := range want {
+				if got, ok := table.Get(k); !ok || !bytes.Equal(got, v) {
+					t.Errorf("got %q for %q, wanted %q", got, k, v)
+				}
+			}
+			if err := table.Delete("key7"); err != nil {
+				t.Fatal(err.Error())
+			}
+			delete(want, "key7")
+			table.Close()
+
+			table, err = New(tf.Name(), 50)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			defer table.Close()
+			if table.Len() != len(want)+1 {
+				t.Errorf("got length %d, wanted %d", table.Len(), len(want)+1)
+			}
+			for k, v := range want {
+				if got, ok := table.Get(k); !ok || !bytes.Equal(got, v) {
+					t.Errorf("got %q for %q, wanted %q", got, k, v)
+				}
+			}
+			if n, _ := table.Counters().Get("counter"); n != 6 {
+				t.Errorf("got counter %d, wanted %d", n, 6)
+			}
+		})
+	}
+}
+
+func TestMaxLoadBytesReadOnly(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	table.Close()
+
+	if _, err := New(tf.Name(), 50, WithReadOnly(), WithMaxLoadBytes(100)); err == nil {
+		t.Errorf("got no error, wanted one")
+	}
+}
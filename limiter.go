@@ -0,0 +1,96 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// Limiter returns a view of the token bucket stored under key k, which
+// admits rate events per second on average with bursts of up to burst
+// events. The bucket need not exist until an event is admitted, and starts
+// full.
+func (t *Table) Limiter(k string, rate float64, burst int) Limiter {
+	return Limiter{t: t, k: k, rate: rate, burst: float64(burst)}
+}
+
+// Limiter is a view of a token bucket stored under a key in a Table, such
+// as one limiting the requests made by a client of a service. The state of
+// the bucket is written to the table each time an event is admitted so the
+// limit holds across restarts and is shared by all views of the same key.
+// Events that are refused write nothing.
+type Limiter struct {
+	t     *Table
+	k     string
+	rate  float64
+	burst float64
+}
+
+// Allow reports whether an event may happen now, taking a token from the
+// bucket if so.
+func (l Limiter) Allow() (bool, error) {
+	return l.AllowN(1)
+}
+
+// AllowN reports whether n events may happen now, taking n tokens from the
+// bucket if so. Either all n events are admitted or none are.
+func (l Limiter) AllowN(n int) (bool, error) {
+	t := l.t
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	now := t.now()
+	tokens, err := l.tokens(now)
+	if err != nil {
+		return false, err
+	}
+	if tokens < float64(n) {
+		return false, nil
+	}
+	if err := t.put(l.k, encodeBucket(tokens-float64(n), now), 0); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Tokens returns the number of tokens currently in the bucket, which is the
+// number of events that would be admitted now.
+func (l Limiter) Tokens() (float64, error) {
+	l.t.mtx.RLock()
+	defer l.t.mtx.RUnlock()
+	return l.tokens(l.t.now())
+}
+
+// tokens returns the number of tokens in the bucket at time now.
+// It is the responsibility of the caller to acquire locks.
+func (l Limiter) tokens(now time.Time) (float64, error) {
+	cur, found := l.t.data[l.k]
+	if !found || cur.deleted != 0 {
+		return l.burst, nil
+	}
+	v, err := l.t.value(cur)
+	if err != nil {
+		return 0, err
+	}
+	if len(v) != 16 {
+		return 0, ErrWrongType
+	}
+	tokens := math.Float64frombits(binary.BigEndian.Uint64(v))
+	last := time.Unix(0, int64(binary.BigEndian.Uint64(v[8:])))
+	if elapsed := now.Sub(last); elapsed > 0 {
+		tokens += elapsed.Seconds() * l.rate
+	}
+	return min(tokens, l.burst), nil
+}
+
+// encodeBucket returns the encoding of a token bucket holding the given
+// number of tokens at time last.
+func encodeBucket(tokens float64, last time.Time) []byte {
+	buf := binary.BigEndian.AppendUint64(nil, math.Float64bits(tokens))
+	return binary.BigEndian.AppendUint64(buf, uint64(last.UnixNano()))
+}
@@ -0,0 +1,70 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	tf, err := os.CreateTemp("", "lash")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tf.Close()
+	defer os.Remove(tf.Name())
+
+	now := time.Unix(1000, 0)
+	clock := withClock(func() time.Time { return now })
+	table, err := New(tf.Name(), 50, clock)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	l := table.Limiter("client", 2, 3)
+	for i := 0; i < 3; i++ {
+		if ok, err := l.Allow(); err != nil || !ok {
+			t.Fatalf("event %d: got %v, %v, wanted event to be allowed", i, ok, err)
+		}
+	}
+	if ok, _ := l.Allow(); ok {
+		t.Errorf("got event allowed when bucket was empty, wanted refused")
+	}
+
+	// Tokens are replenished at the rate, and the state survives reopening
+	table.Close()
+	table, err = New(tf.Name(), 50, clock)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+	l = table.Limiter("client", 2, 3)
+
+	now = now.Add(time.Second)
+	if n, _ := l.Tokens(); n != 2 {
+		t.Errorf("got %v tokens, wanted %v", n, 2)
+	}
+	if ok, _ := l.AllowN(3); ok {
+		t.Errorf("got 3 events allowed with 2 tokens, wanted refused")
+	}
+	if ok, _ := l.AllowN(2); !ok {
+		t.Errorf("got 2 events refused with 2 tokens, wanted allowed")
+	}
+
+	now = now.Add(time.Hour)
+	if n, _ := l.Tokens(); n != 3 {
+		t.Errorf("got %v tokens, wanted burst of %v", n, 3)
+	}
+
+	if err := table.Put("other", []byte("x")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := table.Limiter("other", 1, 1).Allow(); err != ErrWrongType {
+		t.Errorf("got error %v, wanted %v", err, ErrWrongType)
+	}
+}
@@ -0,0 +1,84 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import "errors"
+
+var (
+	// ErrEmptyKey is returned when a value is stored under an empty key.
+	ErrEmptyKey = errors.New("lash: key is empty")
+
+	// ErrKeyTooLarge is returned when a value is stored under a key longer
+	// than the limit set using WithMaxKeySize.
+	ErrKeyTooLarge = errors.New("lash: key exceeds maximum size")
+
+	// ErrValueTooLarge is returned when a value longer than the limit set
+	// using WithMaxValueSize is stored.
+	ErrValueTooLarge = errors.New("lash: value exceeds maximum size")
+)
+
+// WithMaxKeySize limits the length of keys under which values may be stored
+// to n bytes. Writes that exceed the limit fail with ErrKeyTooLarge. There
+// is no limit by default or if n is less than one.
+func WithMaxKeySize(n int) Option {
+	return func(t *Table) {
+		t.maxKey = n
+	}
+}
+
+// WithMaxValueSize limits the length of values that may be stored to n
+// bytes. Writes that exceed the limit, including modifications of a set or
+// list that would grow it beyond the limit, fail with ErrValueTooLarge.
+// There is no limit by default or if n is less than one.
+func WithMaxValueSize(n int) Option {
+	return func(t *Table) {
+		t.maxValue = n
+	}
+}
+
+// WithValidator sets a function that is called with the key and value of
+// every write before it is persisted, including writes made by bulk loads,
+// restores and modifications of counters, sets and lists. A write for which
+// fn returns an error fails with that error and the data file is not
+// changed. The function must not call methods of the table.
+func WithValidator(fn func(k string, v []byte) error) Option {
+	return func(t *Table) {
+		t.validator = fn
+	}
+}
+
+// checkKey returns an error if a value may not be stored under key k.
+func (t *Table) checkKey(k string) error {
+	if k == "" {
+		return ErrEmptyKey
+	}
+	if t.maxKey > 0 && len(k) > t.maxKey {
+		return ErrKeyTooLarge
+	}
+	return nil
+}
+
+// checkValue returns an error if the value v may not be stored.
+func (t *Table) checkValue(v []byte) error {
+	if t.maxValue > 0 && len(v) > t.maxValue {
+		return ErrValueTooLarge
+	}
+	return nil
+}
+
+// checkPut returns an error if the value v may not be stored under key k.
+func (t *Table) checkPut(k string, v []byte) error {
+	if err := t.checkKey(k); err != nil {
+		return err
+	}
+	if err := t.checkValue(v); err != nil {
+		return err
+	}
+	if t.validator != nil {
+		return t.validator(k, v)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSizeLimits(t *testing.T) {
+	table, err := New("", 50, WithMaxKeySize(4), WithMaxValueSize(8))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+
+	testCases := []struct {
+		name    string
+		key     string
+		val     string
+		wantErr error
+	}{
+		{name: "within limits", key: "abcd", val: "12345678", wantErr: nil},
+		{name: "empty key", key: "", val: "v", wantErr: ErrEmptyKey},
+		{name: "key too large", key: "abcde", val: "v", wantErr: ErrKeyTooLarge},
+		{name: "value too large", key: "a", val: "123456789", wantErr: ErrValueTooLarge},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := table.PutString(tc.key, tc.val)
+			if err != tc.wantErr {
+				t.Errorf("Put: got error %v, wanted %v", err, tc.wantErr)
+			}
+			err = table.PutAsync(tc.key, []byte(tc.val)).Err()
+			if err != tc.wantErr {
+				t.Errorf("PutAsync: got error %v, wanted %v", err, tc.wantErr)
+			}
+		})
+	}
+	if _, found := table.Get("abcde"); found {
+		t.Errorf("got found for oversized key, wanted not found")
+	}
+
+	// Growing a set beyond the limit fails and leaves it unchanged
+	set := table.Set("s")
+	if _, err := set.Add("abc"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := set.Add("def"); err != ErrValueTooLarge {
+		t.Errorf("got error %v, wanted %v", err, ErrValueTooLarge)
+	}
+	if got, want := strings.Join(set.Members(), ","), "abc"; got != want {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+}
+
+func TestEmptyKeyWithoutLimits(t *testing.T) {
+	table, err := New("", 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.PutString("", "v"); err != ErrEmptyKey {
+		t.Errorf("got error %v, wanted %v", err, ErrEmptyKey)
+	}
+	if err := table.PutString(strings.Repeat("k", 1<<16), "v"); err != nil {
+		t.Errorf("got error %v, wanted none", err)
+	}
+}
+
+func TestValidator(t *testing.T) {
+	errNotJSON := errors.New("value is not JSON")
+	tf, err := os.CreateTemp("", "lash")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tf.Close()
+	defer os.Remove(tf.Name())
+
+	table, err := New(tf.Name(), 50, WithValidator(func(k string, v []byte) error {
+		if strings.HasPrefix(k, "json:") && !json.Valid(v) {
+			return errNotJSON
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+
+	if err := table.PutString("json:a", `{"a":1}`); err != nil {
+		t.Fatal(err.Error())
+	}
+	size := table.Stats().FileBytes
+	if err := table.PutString("json:b", "{"); err != errNotJSON {
+		t.Errorf("Put: got error %v, wanted %v", err, errNotJSON)
+	}
+	if err := table.PutAsync("json:b", []byte("{")).Err(); err != errNotJSON {
+		t.Errorf("PutAsync: got error %v, wanted %v", err, errNotJSON)
+	}
+	if got := table.Stats().FileBytes; got != size {
+		t.Errorf("got file size %d, wanted %d", got, size)
+	}
+	if _, found := table.Get("json:b"); found {
+		t.Errorf("got found for invalid value, wanted not found")
+	}
+	if err := table.PutString("other", "{"); err != nil {
+		t.Errorf("got error %v, wanted none", err)
+	}
+}
@@ -0,0 +1,146 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Match returns the keys of the items in the table that match the glob
+// pattern, in sorted order. Soft deleted items are not included. In the
+// pattern '*' matches any sequence of characters including none, '?'
+// matches any single character and '[...]' matches any single character
+// in the class, which may contain ranges such as 'a-z' and is negated if it
+// starts with '^'. A backslash matches the character that follows it
+// literally. A '[' without a closing ']' is matched literally. For example
+// "user:*:email" matches "user:42:email". Every key is tested against the
+// pattern except when it starts with literal characters, in which case keys
+// without that prefix are skipped cheaply.
+func (t *Table) Match(pattern string) []string {
+	prefix := pattern
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		prefix = pattern[:i]
+	}
+
+	t.mtx.RLock()
+	var keys []string
+	for k, p := range t.data {
+		if p.deleted != 0 || !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		if globMatch(pattern, k) {
+			keys = append(keys, k)
+		}
+	}
+	t.mtx.RUnlock()
+	sort.Strings(keys)
+	return keys
+}
+
+// globMatch reports whether s matches the glob pattern described by Match.
+func globMatch(pattern, s string) bool {
+	p, i := 0, 0
+	// Position in the pattern after the most recent '*' and the position
+	// in s from which it is currently assumed to match
+	star, starS := -1, 0
+	for i < len(s) {
+		if p < len(pattern) {
+			if pattern[p] == '*' {
+				p++
+				star, starS = p, i
+				continue
+			}
+			if pn, sn, ok := matchChar(pattern[p:], s[i:]); ok {
+				p += pn
+				i += sn
+				continue
+			}
+		}
+		if star < 0 {
+			return false
+		}
+		// Let the most recent '*' consume one more character
+		_, n := utf8.DecodeRuneInString(s[starS:])
+		starS += n
+		p, i = star, starS
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchChar reports whether the first character of s, which must not be
+// empty, matches the single character pattern at the start of pattern,
+// along with the bytes occupied by the pattern and the character.
+func matchChar(pattern, s string) (int, int, bool) {
+	r, n := utf8.DecodeRuneInString(s)
+	switch pattern[0] {
+	case '?':
+		return 1, n, true
+	case '\\':
+		if len(pattern) > 1 {
+			pr, pn := utf8.DecodeRuneInString(pattern[1:])
+			return 1 + pn, n, pr == r
+		}
+	case '[':
+		if pn, ok := matchClass(pattern, r); pn > 0 {
+			return pn, n, ok
+		}
+	}
+	pr, pn := utf8.DecodeRuneInString(pattern)
+	return pn, n, pr == r
+}
+
+// matchClass reports whether r is in the character class at the start of
+// pattern, along with the bytes occupied by the class. It returns zero if
+// the class has no closing ']'.
+func matchClass(pattern string, r rune) (int, bool) {
+	i := 1
+	negate := i < len(pattern) && pattern[i] == '^'
+	if negate {
+		i++
+	}
+	matched := false
+	for i < len(pattern) {
+		if pattern[i] == ']' {
+			return i + 1, matched != negate
+		}
+		lo, n := classChar(pattern[i:])
+		if n == 0 {
+			break
+		}
+		i += n
+		hi := lo
+		if i+1 < len(pattern) && pattern[i] == '-' && pattern[i+1] != ']' {
+			hi, n = classChar(pattern[i+1:])
+			if n == 0 {
+				break
+			}
+			i += 1 + n
+		}
+		if lo <= r && r <= hi {
+			matched = true
+		}
+	}
+	return 0, false
+}
+
+// classChar returns the character at the start of a character class entry,
+// which may be escaped with a backslash, and the bytes it occupies. It
+// returns zero if the entry is incomplete.
+func classChar(s string) (rune, int) {
+	if s[0] != '\\' {
+		return utf8.DecodeRuneInString(s)
+	}
+	if len(s) < 2 {
+		return 0, 0
+	}
+	r, n := utf8.DecodeRuneInString(s[1:])
+	return r, 1 + n
+}
@@ -0,0 +1,72 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	table, err := New("", 10)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, k := range []string{"user:1:email", "user:22:email", "user:3:name", "user:4:email", "admin:1:email"} {
+		table.Put(k, []byte("v"))
+	}
+	table.SoftDelete("user:4:email")
+
+	got := table.Match("user:*:email")
+	want := []string{"user:1:email", "user:22:email"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+	if got := table.Match("*:1:*"); !reflect.DeepEqual(got, []string{"admin:1:email", "user:1:email"}) {
+		t.Errorf("got %q, wanted keys for id 1", got)
+	}
+	if got := table.Match("nomatch*"); got != nil {
+		t.Errorf("got %q, wanted none", got)
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{"", "", true},
+		{"", "a", false},
+		{"abc", "abc", true},
+		{"abc", "abd", false},
+		{"*", "", true},
+		{"*", "anything", true},
+		{"a*c", "abbbc", true},
+		{"a*c", "abbbd", false},
+		{"a*b*c", "axbxbxc", true},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"?", "é", true},
+		{"[abc]x", "bx", true},
+		{"[abc]x", "dx", false},
+		{"[a-c]", "b", true},
+		{"[^a-c]", "b", false},
+		{"[^a-c]", "z", true},
+		{"[a-]", "-", true},
+		{`[\]]`, "]", true},
+		{`a\*`, "a*", true},
+		{`a\*`, "ab", false},
+		{"a[b", "a[b", true},
+		{"**a", "xa", true},
+	}
+
+	for _, tc := range testCases {
+		if got := globMatch(tc.pattern, tc.s); got != tc.want {
+			t.Errorf("globMatch(%q, %q): got %v, wanted %v", tc.pattern, tc.s, got, tc.want)
+		}
+	}
+}
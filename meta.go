@@ -0,0 +1,115 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"time"
+)
+
+// Meta describes the history of an item in the table.
+type Meta struct {
+	// Created is the time the key was first written. It is the zero time
+	// if the item was loaded from a data file written by a release of lash
+	// that did not record timestamps.
+	Created time.Time
+
+	// Updated is the time the value was last written. It is the zero time
+	// if unknown.
+	Updated time.Time
+
+	// Writes is the number of times a value has been written under the key.
+	// It is zero if unknown.
+	Writes uint64
+}
+
+// GetMeta retrieves the metadata for the item stored under key k and
+// returns it along with a boolean that indicates whether the item was
+// found in the table or not.
+func (t *Table) GetMeta(k string) (Meta, bool) {
+	t.mtx.RLock()
+	cur, found := t.data[k]
+	t.mtx.RUnlock()
+	if !found || cur.deleted != 0 {
+		return Meta{}, false
+	}
+	return Meta{
+		Created: unixTime(cur.created),
+		Updated: unixTime(cur.updated),
+		Writes:  cur.writes,
+	}, true
+}
+
+// unixTime converts a time in nanoseconds since the epoch to a time.Time,
+// treating zero as the zero time.
+func unixTime(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+type metaItem struct {
+	val  string
+	pos  int64
+	size int64
+	seq  uint64
+}
+
+// record returns the record that persists the metadata value under key k.
+func (m metaItem) record(k string) record {
+	return record{kind: kindMeta, key: k, val: []byte(m.val), seq: m.seq}
+}
+
+// SetMetadata stores the value v under key k in the table's metadata and
+// writes it to persistent storage. Table metadata is held separately from
+// the items in the table and is intended for information about the table
+// itself such as application schema versions, migration markers and
+// provenance. Metadata keys are never returned by methods that operate
+// on the items in the table.
+func (t *Table) SetMetadata(k string, v string) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	old, exists := t.meta[k]
+	err := t.putmeta(k, v)
+	if err != nil {
+		return err
+	}
+	if exists {
+		err = t.mark(old.pos, old.size)
+		if err != nil {
+			t.meta[k] = old
+			return err
+		}
+	}
+	return nil
+}
+
+// Metadata retrieves the value stored under key k in the table's metadata
+// and returns it along with a boolean that indicates whether the value was
+// found or not.
+func (t *Table) Metadata(k string) (string, bool) {
+	t.mtx.RLock()
+	cur, found := t.meta[k]
+	t.mtx.RUnlock()
+	return cur.val, found
+}
+
+// putmeta writes a metadata value without checking whether it is overwriting
+// any existing value.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) putmeta(k string, v string) error {
+	m := metaItem{val: v, seq: t.nextSeq()}
+	rec := m.record(k)
+	pos, err := t.write(rec)
+	if err != nil {
+		return err
+	}
+	m.pos = pos
+	m.size = recordSize(rec, t.checksum)
+	t.meta[k] = m
+	return nil
+}
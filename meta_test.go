@@ -0,0 +1,110 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestGetMeta(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	tf, err := os.CreateTemp("", "lash")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tf.Close()
+	defer os.Remove(tf.Name())
+
+	table, err := New(tf.Name(), 50, withClock(clock))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	created := now
+	err = table.Put("a", []byte("val"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	now = now.Add(time.Minute)
+	err = table.Put("a", []byte("val2"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Close()
+
+	table2, err := New(tf.Name(), 50, withClock(clock))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table2.Close()
+
+	m, found := table2.GetMeta("a")
+	if !found {
+		t.Fatalf("got not found, wanted found")
+	}
+	if !m.Created.Equal(created) {
+		t.Errorf("got created %v, wanted %v", m.Created, created)
+	}
+	if !m.Updated.Equal(now) {
+		t.Errorf("got updated %v, wanted %v", m.Updated, now)
+	}
+	if m.Writes != 2 {
+		t.Errorf("got writes %d, wanted %d", m.Writes, 2)
+	}
+
+	_, found = table2.GetMeta("b")
+	if found {
+		t.Errorf("got found, wanted not found")
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	err = table.SetMetadata("schema", "1")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = table.SetMetadata("schema", "2")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = table.Put("a", []byte("val"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Close()
+
+	table2, err := New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table2.Close()
+
+	v, found := table2.Metadata("schema")
+	if !found {
+		t.Fatalf("got not found, wanted found")
+	}
+	if v != "2" {
+		t.Errorf("got %q, wanted %q", v, "2")
+	}
+
+	_, found = table2.Get("schema")
+	if found {
+		t.Errorf("got metadata key in table, wanted not found")
+	}
+	if table2.Len() != 1 {
+		t.Errorf("got len %d, wanted %d", table2.Len(), 1)
+	}
+}
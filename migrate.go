@@ -0,0 +1,49 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// MigrationKey is the key of the table metadata under which Migrate records
+// the version of the last migration applied to the table.
+const MigrationKey = "lash.migration"
+
+// Migrate runs the migration fn against the table unless a migration with
+// the same or a later version has already been applied, in which case it
+// does nothing. Once fn succeeds its version is recorded in the table's
+// metadata under MigrationKey so it is not run again, even after the table
+// is reopened. Applications evolving the format of their values call
+// Migrate once for each migration, in increasing order of version, each
+// time the table is opened. Calls to Migrate for the same table are
+// serialised but other writes to the table may continue while fn runs.
+//
+// The writes made by fn are not applied atomically. If fn fails, or the
+// process crashes before its version is recorded, then those writes that
+// were made remain and fn is run again by the next call to Migrate, so
+// migrations should be written so that they may safely be repeated.
+func (t *Table) Migrate(version int, fn func(t *Table) error) error {
+	t.migrateMtx.Lock()
+	defer t.migrateMtx.Unlock()
+
+	applied := 0
+	if v, found := t.Metadata(MigrationKey); found {
+		var err error
+		applied, err = strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("lash: invalid migration version %q", v)
+		}
+	}
+	if version <= applied {
+		return nil
+	}
+	if err := fn(t); err != nil {
+		return err
+	}
+	return t.SetMetadata(MigrationKey, strconv.Itoa(version))
+}
@@ -0,0 +1,66 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	var ran []int
+	migrations := []func(t *Table) error{
+		func(t *Table) error { return t.Put("a", []byte("v1")) },
+		func(t *Table) error { return t.Put("a", []byte("v2")) },
+	}
+	migrate := func(table *Table) {
+		for i, fn := range migrations {
+			version := i + 1
+			err := table.Migrate(version, func(t *Table) error {
+				ran = append(ran, version)
+				return fn(t)
+			})
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+	}
+
+	migrate(table)
+	if len(ran) != 2 {
+		t.Errorf("got migrations %v run, wanted %v", ran, []int{1, 2})
+	}
+	table.Close()
+
+	// Applied migrations are not run again after reopening
+	table, err = New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+	ran = nil
+	migrate(table)
+	if len(ran) != 0 {
+		t.Errorf("got migrations %v run, wanted none", ran)
+	}
+
+	// A failed migration is not recorded
+	failed := errors.New("failed")
+	err = table.Migrate(3, func(t *Table) error { return failed })
+	if err != failed {
+		t.Errorf("got error %v, wanted %v", err, failed)
+	}
+	if v, _ := table.Metadata(MigrationKey); v != "2" {
+		t.Errorf("got version %q, wanted %q", v, "2")
+	}
+}
@@ -0,0 +1,35 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"errors"
+)
+
+// ErrExists is returned by PutOnce when a value is already stored under
+// the key.
+var ErrExists = errors.New("lash: key already exists")
+
+// PutOnce stores the value v under key k in the same way as Put unless a
+// value is already stored under k, in which case it returns ErrExists and
+// leaves the table unchanged. It suits keys that must only ever be written
+// once, such as those of content addressed or append-only data, where an
+// overwrite would be a bug. A soft deleted value counts as stored since it
+// may still be recovered by Undelete; a key removed by Delete may be
+// written again.
+func (t *Table) PutOnce(k string, v []byte, d ...Durability) error {
+	var dur Durability
+	if len(d) > 0 {
+		dur = d[len(d)-1]
+	}
+	t.putLimit.wait()
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if _, exists := t.data[k]; exists {
+		return ErrExists
+	}
+	return t.put(k, v, dur)
+}
@@ -0,0 +1,47 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPutOnce(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	if err := table.PutOnce("a", []byte("first")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.PutOnce("a", []byte("second")); err != ErrExists {
+		t.Errorf("got error %v, wanted %v", err, ErrExists)
+	}
+	if v, _ := table.Get("a"); string(v) != "first" {
+		t.Errorf("got %q, wanted %q", v, "first")
+	}
+
+	if err := table.SoftDelete("a"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.PutOnce("a", []byte("second")); err != ErrExists {
+		t.Errorf("got error %v for soft deleted key, wanted %v", err, ErrExists)
+	}
+
+	if err := table.Delete("a"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.PutOnce("a", []byte("third"), Durable); err != nil {
+		t.Errorf("got error %v after delete, wanted none", err)
+	}
+	if v, _ := table.Get("a"); string(v) != "third" {
+		t.Errorf("got %q, wanted %q", v, "third")
+	}
+}
@@ -0,0 +1,98 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenFile(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "data")
+
+	if _, err := openFile(fname, os.O_RDWR, 0); !os.IsNotExist(err) {
+		t.Errorf("got error %v, wanted not exist", err)
+	}
+
+	f, err := openFile(fname, os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.FileMode(0666))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := f.Write([]byte("data")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// The file may be renamed and removed while it is open
+	other := fname + oldSuffix
+	if err := os.Rename(fname, other); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err.Error())
+	}
+	if b, err := io.ReadAll(f); err != nil || string(b) != "data" {
+		t.Errorf("got %q, %v, wanted %q", b, err, "data")
+	}
+	if err := os.Remove(other); err != nil {
+		t.Fatal(err.Error())
+	}
+	f.Close()
+
+	f, err = openFile(fname, os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.FileMode(0666))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	f.Write([]byte("data"))
+	f.Close()
+	f, err = openFile(fname, os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.FileMode(0666))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if fi.Size() != 0 {
+		t.Errorf("got size %d after truncating, wanted %d", fi.Size(), 0)
+	}
+}
+
+func TestCompactRepeatedly(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	// Each compaction renames the open data file aside and renames the new
+	// one into place while it is open
+	for i := 0; i < 3; i++ {
+		if err := table.Put("a", []byte{byte(i)}); err != nil {
+			t.Fatal(err.Error())
+		}
+		if err := table.Compact(); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := table.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := os.Stat(tf.Name() + oldSuffix); !os.IsNotExist(err) {
+		t.Errorf("got old data file left behind, wanted it removed")
+	}
+
+	table, err = New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+	if v, _ := table.Get("a"); len(v) != 1 || v[0] != 2 {
+		t.Errorf("got %v, wanted %v", v, []byte{2})
+	}
+}
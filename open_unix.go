@@ -0,0 +1,18 @@
+//go:build !windows
+
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+)
+
+// openFile opens the named data file. Open files may be renamed and removed
+// on Unix so it is equivalent to os.OpenFile.
+func openFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	return os.OpenFile(name, flag, perm)
+}
@@ -0,0 +1,64 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"syscall"
+)
+
+// openFile opens the named data file like os.OpenFile but allows it to be
+// renamed and removed while it is open, which compaction relies on to move
+// the old data file aside and rename the new one into place. os.OpenFile
+// does not share delete access so renaming an open file fails on Windows.
+// Only the flags used by lash are supported: O_RDONLY, O_WRONLY, O_RDWR,
+// O_CREATE, O_EXCL and O_TRUNC. The permissions are ignored other than to
+// make a newly created file read-only if perm has no write permission.
+func openFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	namep, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	var access uint32
+	switch flag & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR) {
+	case os.O_RDONLY:
+		access = syscall.GENERIC_READ
+	case os.O_WRONLY:
+		access = syscall.GENERIC_WRITE
+	case os.O_RDWR:
+		access = syscall.GENERIC_READ | syscall.GENERIC_WRITE
+	}
+	if flag&os.O_CREATE != 0 {
+		access |= syscall.GENERIC_WRITE
+	}
+
+	var mode uint32
+	switch {
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		mode = syscall.CREATE_NEW
+	case flag&(os.O_CREATE|os.O_TRUNC) == os.O_CREATE|os.O_TRUNC:
+		mode = syscall.CREATE_ALWAYS
+	case flag&os.O_CREATE != 0:
+		mode = syscall.OPEN_ALWAYS
+	case flag&os.O_TRUNC != 0:
+		mode = syscall.TRUNCATE_EXISTING
+	default:
+		mode = syscall.OPEN_EXISTING
+	}
+
+	attrs := uint32(syscall.FILE_ATTRIBUTE_NORMAL)
+	if flag&os.O_CREATE != 0 && perm&0200 == 0 {
+		attrs = syscall.FILE_ATTRIBUTE_READONLY
+	}
+
+	share := uint32(syscall.FILE_SHARE_READ | syscall.FILE_SHARE_WRITE | syscall.FILE_SHARE_DELETE)
+	h, err := syscall.CreateFile(namep, access, share, nil, mode, attrs, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	return os.NewFile(uintptr(h), name), nil
+}
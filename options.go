@@ -0,0 +1,45 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"log/slog"
+	"time"
+)
+
+// An Option configures a Table when it is created by New.
+type Option func(*Table)
+
+const defaultUndeleteWindow = 24 * time.Hour
+
+// WithUndeleteWindow sets the period for which a soft deleted item may be
+// recovered using Undelete. Once the window has passed the item can no longer
+// be recovered and it is removed permanently the next time the data file is
+// compacted. The default window is 24 hours.
+func WithUndeleteWindow(d time.Duration) Option {
+	return func(t *Table) {
+		t.window = d
+	}
+}
+
+// WithStrictOpen causes New to fail if the data file contains anything it
+// does not expect, such as corrupt records at the end of the file or records
+// of an unknown kind. By default corrupt records at the end of the file are
+// discarded, since they are typically left by a crash part way through a
+// write, and unknown records are skipped.
+func WithStrictOpen() Option {
+	return func(t *Table) {
+		t.strict = true
+	}
+}
+
+// WithLogger sets the logger used to report events such as the recovery
+// of a damaged data file. The default is slog.Default().
+func WithLogger(l *slog.Logger) Option {
+	return func(t *Table) {
+		t.logger = l
+	}
+}
@@ -0,0 +1,167 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"encoding/binary"
+	"slices"
+)
+
+// keyIndexID identifies the ordered key index among a table's indexes.
+const keyIndexID = "keys"
+
+// WithOrderedKeys maintains an ordered index of the table's keys, which
+// allows the boundaries of a keyspace, such as the earliest and latest keys
+// built using TimeKey, to be found using First, Last, SeekGE and SeekLE
+// without examining every key.
+//
+// Like the search index, the ordered index is saved to a file alongside
+// the data file when the table is closed and loaded from it when the table
+// is next opened.
+func WithOrderedKeys() Option {
+	return func(t *Table) {
+		t.addIndex(keyIndexID, &keyIndex{})
+	}
+}
+
+// First returns the smallest key in the table and reports whether the table
+// holds any keys. Soft deleted items are not included. First reports false
+// if the table was not created using WithOrderedKeys.
+func (t *Table) First() (string, bool) {
+	// Every key is greater than or equal to the empty key
+	return t.SeekGE("")
+}
+
+// Last returns the largest key in the table and reports whether the table
+// holds any keys. Soft deleted items are not included. Last reports false if
+// the table was not created using WithOrderedKeys.
+func (t *Table) Last() (string, bool) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	ix, ok := t.indexes[keyIndexID].(*keyIndex)
+	if !ok {
+		return "", false
+	}
+	b, i := ix.keys.end()
+	return t.firstLive(ix.keys.scanBack, b, i)
+}
+
+// SeekGE returns the smallest key in the table that is greater than or
+// equal to k and reports whether there is one. Soft deleted items are not
+// included. SeekGE reports false if the table was not created using
+// WithOrderedKeys.
+func (t *Table) SeekGE(k string) (string, bool) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	ix, ok := t.indexes[keyIndexID].(*keyIndex)
+	if !ok {
+		return "", false
+	}
+	b, i := ix.find(k)
+	return t.firstLive(ix.keys.scan, b, i)
+}
+
+// SeekLE returns the largest key in the table that is less than or equal to
+// k and reports whether there is one. Soft deleted items are not included.
+// SeekLE reports false if the table was not created using WithOrderedKeys.
+func (t *Table) SeekLE(k string) (string, bool) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	ix, ok := t.indexes[keyIndexID].(*keyIndex)
+	if !ok {
+		return "", false
+	}
+	b, i := ix.keys.search(func(e string) bool { return e > k })
+	return t.firstLive(ix.keys.scanBack, b, i)
+}
+
+// firstLive returns the first key passed by scan from position (b, i) that
+// is not soft deleted and reports whether there is one.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) firstLive(scan func(b, i int, fn func(string) bool), b, i int) (string, bool) {
+	var found string
+	var ok bool
+	scan(b, i, func(k string) bool {
+		if t.data[k].deleted != 0 {
+			return true
+		}
+		found, ok = k, true
+		return false
+	})
+	return found, ok
+}
+
+// keyIndex holds the keys of a table's items, including those that are
+// soft deleted, in order.
+type keyIndex struct {
+	keys blockList[string]
+}
+
+// find returns the position in the index at which k is, or would be, held.
+func (x *keyIndex) find(k string) (int, int) {
+	return x.keys.search(func(e string) bool { return e >= k })
+}
+
+func (x *keyIndex) add(k string, v []byte) {
+	b, i := x.find(k)
+	if e, ok := x.keys.get(b, i); ok && e == k {
+		return
+	}
+	x.keys.insert(b, i, k)
+}
+
+func (x *keyIndex) remove(k string, v []byte) {
+	b, i := x.find(k)
+	if e, ok := x.keys.get(b, i); ok && e == k {
+		x.keys.remove(b, i)
+	}
+}
+
+func (x *keyIndex) clear() {
+	x.keys.clear()
+}
+
+// build replaces the index's keys with those passed to add by each, sorting
+// them once rather than adding them one at a time.
+func (x *keyIndex) build(each func(add func(k string, v []byte))) {
+	var keys []string
+	each(func(k string, v []byte) {
+		keys = append(keys, k)
+	})
+	slices.Sort(keys)
+	x.keys.set(slices.Compact(keys))
+}
+
+func (x *keyIndex) marshal() []byte {
+	buf := binary.AppendUvarint(nil, uint64(x.keys.len()))
+	x.keys.scan(0, 0, func(k string) bool {
+		buf = appendBytes(buf, []byte(k))
+		return true
+	})
+	return buf
+}
+
+func (x *keyIndex) unmarshal(b []byte, keys interner) error {
+	n, b, err := consumeUvarint(b)
+	if err != nil {
+		return err
+	}
+	var ks []string
+	for i := uint64(0); i < n; i++ {
+		var k []byte
+		k, b, err = consumeBytes(b)
+		if err != nil {
+			return err
+		}
+		if len(ks) > 0 && ks[len(ks)-1] >= string(k) {
+			// Keys are saved in order
+			return ErrCorrupt
+		}
+		ks = append(ks, keys.intern(k))
+	}
+	x.keys.set(ks)
+	return nil
+}
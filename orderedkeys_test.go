@@ -0,0 +1,104 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestOrderedKeys(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "data")
+	table, err := New(fname, 50, WithOrderedKeys())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, k := range []string{"b", "d", "a", "ab", "c"} {
+		if err := table.PutString(k, "value"); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	table.Delete("d")
+	table.SoftDelete("c")
+
+	check := func(when string, table *Table) {
+		seeks := []struct {
+			name string
+			fn   func() (string, bool)
+			want string
+		}{
+			{"First", table.First, "a"},
+			{"Last", table.Last, "b"},
+			{"SeekGE(aa)", func() (string, bool) { return table.SeekGE("aa") }, "ab"},
+			{"SeekGE(ab)", func() (string, bool) { return table.SeekGE("ab") }, "ab"},
+			{"SeekGE(bb)", func() (string, bool) { return table.SeekGE("bb") }, ""},
+			{"SeekLE(aa)", func() (string, bool) { return table.SeekLE("aa") }, "a"},
+			{"SeekLE(ab)", func() (string, bool) { return table.SeekLE("ab") }, "ab"},
+			{"SeekLE(z)", func() (string, bool) { return table.SeekLE("z") }, "b"},
+			{"SeekLE(0)", func() (string, bool) { return table.SeekLE("0") }, ""},
+		}
+		for _, s := range seeks {
+			got, ok := s.fn()
+			if got != s.want || ok != (s.want != "") {
+				t.Errorf("%s: %s: got %q, %v, wanted %q", when, s.name, got, ok, s.want)
+			}
+		}
+	}
+	check("before reopen", table)
+	table.Close()
+
+	table, err = New(fname, 50, WithOrderedKeys())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+	check("after reopen", table)
+
+	if err := table.Undelete("c"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if k, _ := table.Last(); k != "c" {
+		t.Errorf("got last key %q after undelete, wanted %q", k, "c")
+	}
+}
+
+func TestOrderedKeysMany(t *testing.T) {
+	table, err := New("", 0, WithOrderedKeys())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	// Enough keys to split the index into several blocks
+	for i := 0; i < 4*blockLen; i++ {
+		table.PutString(Uint64Key(uint64(i*2)), "value")
+	}
+	for i := 0; i < 4*blockLen-1; i++ {
+		table.Delete(Uint64Key(uint64(i * 2)))
+		want := Uint64Key(uint64(i*2 + 2))
+		if k, _ := table.First(); k != want {
+			t.Fatalf("got first key %x, wanted %x", k, want)
+		}
+		if k, _ := table.SeekLE(Uint64Key(uint64(i*2 + 3))); k != want {
+			t.Fatalf("got key %x at or before %d, wanted %x", k, i*2+3, want)
+		}
+	}
+	if k, _ := table.Last(); k != Uint64Key(uint64(8*blockLen-2)) {
+		t.Errorf("got last key %x, wanted %x", k, Uint64Key(uint64(8*blockLen-2)))
+	}
+}
+
+func TestOrderedKeysNotIndexed(t *testing.T) {
+	table, err := New("", 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table.PutString("a", "value")
+	if k, ok := table.First(); ok {
+		t.Errorf("got first key %q without ordered keys, wanted none", k)
+	}
+	if k, ok := table.SeekLE("z"); ok {
+		t.Errorf("got key %q without ordered keys, wanted none", k)
+	}
+}
@@ -0,0 +1,60 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"sync"
+)
+
+// A Pinned holds a value returned by GetPinned and keeps the mapping of the
+// snapshot file it refers to valid until it is released.
+type Pinned struct {
+	a    *Attached
+	val  []byte
+	once sync.Once
+}
+
+// GetPinned returns the value stored under key k and reports whether it was
+// found, like Get, but the value remains valid after the snapshot is closed
+// until Release is called on the returned handle, so it may be used without
+// copying by code that cannot know when the snapshot will be closed. Every
+// handle returned must be released; the mapping of the snapshot file is not
+// released until they all are.
+func (a *Attached) GetPinned(k string) (*Pinned, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	v, ok := a.get(k)
+	if !ok {
+		return nil, false
+	}
+	a.pins.Add(1)
+	return &Pinned{a: a, val: v}, true
+}
+
+// Value returns the pinned value. It must not be modified and must not be
+// used after Release.
+func (p *Pinned) Value() []byte {
+	return p.val
+}
+
+// Release unpins the value. If the snapshot has been closed and this was
+// the last value pinned then the mapping of the snapshot file is released.
+// Calling Release more than once has no further effect.
+func (p *Pinned) Release() {
+	p.once.Do(func() {
+		p.val = nil
+		if p.a.pins.Add(-1) > 0 {
+			return
+		}
+		a := p.a
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		// Pins taken since the count fell to zero keep the mapping
+		if a.closed && a.pins.Load() == 0 {
+			a.release()
+		}
+	})
+}
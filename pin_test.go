@@ -0,0 +1,70 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetPinned(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	if err := table.Put("a", []byte("value a")); err != nil {
+		t.Fatal(err.Error())
+	}
+	fname := filepath.Join(t.TempDir(), "snapshot")
+	f, err := os.Create(fname)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := table.WriteTo(f); err != nil {
+		t.Fatal(err.Error())
+	}
+	f.Close()
+
+	a, err := AttachSnapshot(fname)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, ok := a.GetPinned("b"); ok {
+		t.Errorf("got pinned value for %q, wanted none", "b")
+	}
+	p, ok := a.GetPinned("a")
+	if !ok {
+		t.Fatalf("got no pinned value for %q", "a")
+	}
+	q, _ := a.GetPinned("a")
+	q.Release()
+	q.Release()
+
+	if err := a.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if a.data == nil {
+		t.Fatalf("mapping released while a value is pinned")
+	}
+	if got := string(p.Value()); got != "value a" {
+		t.Errorf("got %q after close, wanted %q", got, "value a")
+	}
+	if _, ok := a.GetPinned("a"); ok {
+		t.Errorf("got pinned value after close, wanted none")
+	}
+
+	p.Release()
+	if a.data != nil {
+		t.Errorf("mapping not released after last value was released")
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+}
@@ -0,0 +1,155 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/iand/lash/format"
+)
+
+// restoreSuffix is appended to the name of a data file being created by
+// RestoreBackups until it is complete.
+const restoreSuffix = ".restore"
+
+// ErrRestorePoint is returned by RestoreBackups when the restore point falls
+// part way through the writes recorded by one of the backups or before those
+// recorded by the first.
+var ErrRestorePoint = errors.New("lash: restore point falls within a backup")
+
+// A RestorePoint selects the last write applied by RestoreBackups. The zero
+// RestorePoint applies every write.
+type RestorePoint struct {
+	// Seq, if not zero, is the sequence number of the last write to apply.
+	Seq uint64
+
+	// Time, if not zero, is the time of the last write to apply. Writes
+	// are ordered by sequence number so no writes are applied after the
+	// first that was made after Time.
+	Time time.Time
+}
+
+// includes reports whether the write that produced rec is at or before the
+// restore point.
+func (p RestorePoint) includes(rec record) bool {
+	if p.Seq != 0 && rec.seq > p.Seq {
+		return false
+	}
+	if !p.Time.IsZero() {
+		ts := rec.updated
+		if rec.kind == kindSoftDelete {
+			ts, _ = binary.Varint(rec.val)
+		}
+		if ts > p.Time.UnixNano() {
+			return false
+		}
+	}
+	return true
+}
+
+// RestoreBackups creates a data file named fname holding the writes recorded
+// in a full backup followed by a sequence of incremental backups, both made
+// by BackupSince and supplied in the order in which they were made, up to
+// the restore point p. It returns the sequence number of the last write
+// applied. The file, which must not already exist, may then be opened using
+// New. Every backup must be complete and undamaged.
+//
+// A table can only be restored to its state when one of the backups was
+// made. A backup holds only the writes that were current when it was made,
+// since a write that has been replaced or deleted is marked as deleted in
+// the data file, so the state of the table between two backups cannot be
+// recovered. The restore point must therefore include every write recorded
+// by the first backup and by the last backup that is applied, and none of
+// those recorded by the next, otherwise RestoreBackups returns
+// ErrRestorePoint.
+func RestoreBackups(fname string, p RestorePoint, backups ...io.Reader) (uint64, error) {
+	if len(backups) == 0 {
+		return 0, errors.New("lash: no backups to restore")
+	}
+	if _, err := os.Stat(fname); err == nil {
+		return 0, os.ErrExist
+	} else if !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	tmpname := fname + restoreSuffix
+	f, err := os.OpenFile(tmpname, os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.FileMode(0666))
+	if err != nil {
+		return 0, err
+	}
+	last, err := writeRestore(f, p, backups)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmpname, fname)
+	}
+	if err != nil {
+		os.Remove(tmpname)
+		return 0, err
+	}
+	return last, syncDir(filepath.Dir(fname))
+}
+
+// writeRestore writes a data file to w holding the writes in backups up to
+// the restore point p and returns the sequence number of the last write.
+func writeRestore(w io.Writer, p RestorePoint, backups []io.Reader) (uint64, error) {
+	bw := bufio.NewWriter(w)
+	var c Checksum
+	var codec *format.Codec
+	var last uint64
+	var buf []byte
+	for i, r := range backups {
+		d, err := newDecoder(r)
+		if err != nil {
+			return 0, err
+		}
+		if i == 0 {
+			c, codec = d.checksum, d.codec
+			if _, err := bw.Write(appendHeader(nil, c, codec)); err != nil {
+				return 0, err
+			}
+		}
+		applied := false
+		for {
+			rec, err := d.next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return 0, err
+			}
+			// Writes marked as deleted still count towards the backup
+			// since they were made after the previous backup. There is
+			// no state before the first backup to restore the table to.
+			if !p.includes(rec) {
+				if applied || i == 0 {
+					return 0, ErrRestorePoint
+				}
+				return last, bw.Flush()
+			}
+			applied = true
+			if rec.kind == tomb {
+				continue
+			}
+			buf = appendRecord(buf[:0], rec, c, codec)
+			if _, err := bw.Write(buf); err != nil {
+				return 0, err
+			}
+			last = rec.seq
+		}
+	}
+	return last, bw.Flush()
+}
@@ -0,0 +1,192 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRestoreBackups(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	table.now = func() time.Time { return clock }
+	tick := func() time.Time {
+		clock = clock.Add(time.Minute)
+		return clock
+	}
+
+	table.PutString("a", "1")
+	table.PutString("b", "2")
+	var full bytes.Buffer
+	offset, err := table.BackupSince(0, &full)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	afterFull := table.seq
+	fullTime := clock
+
+	tick()
+	table.PutString("a", "2")
+	afterPut := table.seq
+	putTime := clock
+	tick()
+	table.Delete("b")
+	tick()
+	table.PutString("c", "3")
+	afterIncr1 := table.seq
+	var incr1 bytes.Buffer
+	offset, err = table.BackupSince(offset, &incr1)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	tick()
+	table.PutString("c", "4")
+	var incr2 bytes.Buffer
+	if _, err := table.BackupSince(offset, &incr2); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	testCases := []struct {
+		name    string
+		point   RestorePoint
+		want    map[string]string
+		wantErr error
+	}{
+		{
+			name:  "all",
+			point: RestorePoint{},
+			want:  map[string]string{"a": "2", "c": "4"},
+		},
+		{
+			name:  "after full",
+			point: RestorePoint{Seq: afterFull},
+			want:  map[string]string{"a": "1", "b": "2"},
+		},
+		{
+			name:  "after incremental",
+			point: RestorePoint{Seq: afterIncr1},
+			want:  map[string]string{"a": "2", "c": "3"},
+		},
+		{
+			name:  "time of full",
+			point: RestorePoint{Time: fullTime},
+			want:  map[string]string{"a": "1", "b": "2"},
+		},
+		{
+			name:    "after put",
+			point:   RestorePoint{Seq: afterPut},
+			wantErr: ErrRestorePoint,
+		},
+		{
+			name:    "time of put",
+			point:   RestorePoint{Time: putTime},
+			wantErr: ErrRestorePoint,
+		},
+		{
+			name:    "before full",
+			point:   RestorePoint{Time: fullTime.Add(-time.Minute)},
+			wantErr: ErrRestorePoint,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fname := filepath.Join(t.TempDir(), "restored")
+			backups := []io.Reader{
+				bytes.NewReader(full.Bytes()),
+				bytes.NewReader(incr1.Bytes()),
+				bytes.NewReader(incr2.Bytes()),
+			}
+			last, err := RestoreBackups(fname, tc.point, backups...)
+			if tc.wantErr != nil {
+				if err != tc.wantErr {
+					t.Errorf("got error %v, wanted %v", err, tc.wantErr)
+				}
+				if _, err := os.Stat(fname); !os.IsNotExist(err) {
+					t.Errorf("got error %v for restored file, wanted it not to exist", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if tc.point.Seq != 0 && last != tc.point.Seq {
+				t.Errorf("got last sequence number %d, wanted %d", last, tc.point.Seq)
+			}
+
+			restored, err := New(fname, 50)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			defer restored.Close()
+			if restored.Len() != len(tc.want) {
+				t.Errorf("got len %d, wanted %d", restored.Len(), len(tc.want))
+			}
+			for k, v := range tc.want {
+				if got, _ := restored.GetString(k); got != v {
+					t.Errorf("%s: got %q, wanted %q", k, got, v)
+				}
+			}
+		})
+	}
+}
+
+func TestRestoreBackupsSuperseded(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	table.PutString("a", "1")
+	var full bytes.Buffer
+	offset, err := table.BackupSince(0, &full)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table.PutString("b", "1")
+	first := table.seq
+	table.PutString("b", "2")
+	var incr bytes.Buffer
+	if _, err := table.BackupSince(offset, &incr); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// The first write to b is marked as deleted in the incremental backup
+	// but still places the restore point within it
+	fname := filepath.Join(t.TempDir(), "restored")
+	_, err = RestoreBackups(fname, RestorePoint{Seq: first}, bytes.NewReader(full.Bytes()), bytes.NewReader(incr.Bytes()))
+	if err != ErrRestorePoint {
+		t.Errorf("got error %v, wanted %v", err, ErrRestorePoint)
+	}
+}
+
+func TestRestoreBackupsExisting(t *testing.T) {
+	tf, err := os.CreateTemp("", "lash")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tf.Close()
+	defer os.Remove(tf.Name())
+
+	if _, err := RestoreBackups(tf.Name(), RestorePoint{}, bytes.NewReader(nil)); !os.IsExist(err) {
+		t.Errorf("got error %v, wanted file exists error", err)
+	}
+}
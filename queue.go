@@ -0,0 +1,205 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A Message is an entry in a Queue.
+type Message struct {
+	// ID identifies the message within its queue. IDs are assigned in
+	// increasing order as messages are enqueued.
+	ID    uint64
+	Value []byte
+}
+
+// Queue is a durable first-in first-out queue of messages held in a Table.
+// Messages are delivered by Dequeue and remain in the table until they are
+// acknowledged using Ack, so any message that has not been acknowledged is
+// delivered again after the table is reopened. The queue records the ID of
+// its oldest unacknowledged message, its consumer offset, in the table so
+// that message IDs are never reused.
+type Queue struct {
+	t    *Table
+	name []byte
+
+	mu       sync.Mutex
+	next     uint64              // ID of the next message to be enqueued
+	ready    []uint64            // IDs of messages awaiting delivery, oldest first
+	inflight map[uint64]struct{} // IDs of delivered messages awaiting acknowledgement
+}
+
+// Queue returns the queue with the given name, loading its state from the
+// table if necessary. Messages are stored under keys formed by CompositeKey
+// from the name of the queue. Every call with the same name returns the same
+// queue.
+func (t *Table) Queue(name string) (*Queue, error) {
+	t.mtx.RLock()
+	q, ok := t.queues[name]
+	t.mtx.RUnlock()
+	if ok {
+		return q, nil
+	}
+
+	q = &Queue{
+		t:        t,
+		name:     []byte(name),
+		inflight: make(map[uint64]struct{}),
+	}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if existing, ok := t.queues[name]; ok {
+		return existing, nil
+	}
+	if t.queues == nil {
+		t.queues = make(map[string]*Queue)
+	}
+	t.queues[name] = q
+	return q, nil
+}
+
+// load reads the queue's messages and consumer offset from the table.
+func (q *Queue) load() error {
+	if v, found := q.t.Get(q.offsetKey()); found {
+		offset, err := ParseUint64Key(string(v))
+		if err != nil {
+			return err
+		}
+		q.next = offset
+	}
+
+	prefix := CompositeKey(q.name, []byte("m"))
+	for k := range q.t.Filter(func(k string, v []byte) bool { return strings.HasPrefix(k, prefix) }) {
+		parts, err := SplitCompositeKey(k)
+		if err != nil || len(parts) != 3 {
+			return ErrInvalidKey
+		}
+		id, err := ParseUint64Key(string(parts[2]))
+		if err != nil {
+			return err
+		}
+		q.ready = append(q.ready, id)
+		if id >= q.next {
+			q.next = id + 1
+		}
+	}
+	sort.Slice(q.ready, func(i, j int) bool { return q.ready[i] < q.ready[j] })
+	return nil
+}
+
+// Enqueue adds a message holding v to the end of the queue and returns its
+// ID.
+func (q *Queue) Enqueue(v []byte) (uint64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	id := q.next
+	if err := q.t.Put(q.messageKey(id), v); err != nil {
+		return 0, err
+	}
+	q.next++
+	q.ready = append(q.ready, id)
+	return id, nil
+}
+
+// Dequeue delivers the message at the front of the queue along with a
+// boolean that indicates whether the queue held a message awaiting delivery.
+// The message is not delivered again unless it is returned to the queue
+// using Nack or the table is reopened before it is acknowledged using Ack.
+func (q *Queue) Dequeue() (Message, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.ready) > 0 {
+		id := q.ready[0]
+		q.ready = q.ready[1:]
+		v, found := q.t.Get(q.messageKey(id))
+		if !found {
+			continue
+		}
+		q.inflight[id] = struct{}{}
+		return Message{ID: id, Value: v}, true
+	}
+	return Message{}, false
+}
+
+// Peek returns the message at the front of the queue without delivering it,
+// along with a boolean that indicates whether the queue held a message
+// awaiting delivery.
+func (q *Queue) Peek() (Message, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, id := range q.ready {
+		if v, found := q.t.Get(q.messageKey(id)); found {
+			return Message{ID: id, Value: v}, true
+		}
+	}
+	return Message{}, false
+}
+
+// Ack acknowledges the delivered message with the given ID, removing it from
+// the queue. It returns ErrNotFound if no such message is awaiting
+// acknowledgement.
+func (q *Queue) Ack(id uint64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.inflight[id]; !ok {
+		return ErrNotFound
+	}
+	if err := q.t.Delete(q.messageKey(id)); err != nil {
+		return err
+	}
+	delete(q.inflight, id)
+
+	offset := q.next
+	if len(q.ready) > 0 {
+		offset = q.ready[0]
+	}
+	for id := range q.inflight {
+		if id < offset {
+			offset = id
+		}
+	}
+	return q.t.Put(q.offsetKey(), []byte(Uint64Key(offset)))
+}
+
+// Nack returns the delivered message with the given ID to the queue so that
+// it is delivered again before any later message. It returns ErrNotFound if no such
+// message is awaiting acknowledgement.
+func (q *Queue) Nack(id uint64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.inflight[id]; !ok {
+		return ErrNotFound
+	}
+	delete(q.inflight, id)
+	i := sort.Search(len(q.ready), func(i int) bool { return q.ready[i] > id })
+	q.ready = append(q.ready, 0)
+	copy(q.ready[i+1:], q.ready[i:])
+	q.ready[i] = id
+	return nil
+}
+
+// Len returns the number of messages in the queue that have not been
+// acknowledged, including those that have been delivered.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.ready) + len(q.inflight)
+}
+
+func (q *Queue) messageKey(id uint64) string {
+	return CompositeKey(q.name, []byte("m"), []byte(Uint64Key(id)))
+}
+
+func (q *Queue) offsetKey() string {
+	return CompositeKey(q.name, []byte("offset"))
+}
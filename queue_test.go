@@ -0,0 +1,101 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestQueue(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	q, err := table.Queue("jobs")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if q2, _ := table.Queue("jobs"); q2 != q {
+		t.Errorf("got different queue for same name, wanted same")
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := q.Enqueue([]byte(fmt.Sprintf("job%d", i))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	m, ok := q.Peek()
+	if !ok || string(m.Value) != "job0" {
+		t.Errorf("got %q, wanted %q", m.Value, "job0")
+	}
+	m0, _ := q.Dequeue()
+	m1, _ := q.Dequeue()
+	if string(m0.Value) != "job0" || string(m1.Value) != "job1" {
+		t.Errorf("got %q and %q, wanted %q and %q", m0.Value, m1.Value, "job0", "job1")
+	}
+	if err := q.Ack(m0.ID); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := q.Ack(m0.ID); err != ErrNotFound {
+		t.Errorf("got error %v, wanted %v", err, ErrNotFound)
+	}
+	if err := q.Nack(m1.ID); err != nil {
+		t.Fatal(err.Error())
+	}
+	if m, _ := q.Dequeue(); m.ID != m1.ID {
+		t.Errorf("got message %d, wanted redelivery of %d", m.ID, m1.ID)
+	}
+	if q.Len() != 4 {
+		t.Errorf("got len %d, wanted %d", q.Len(), 4)
+	}
+	table.Close()
+
+	// Unacknowledged messages are delivered again after reopening
+	table2, err := New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table2.Close()
+	q, err = table2.Queue("jobs")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if q.Len() != 4 {
+		t.Errorf("got len %d, wanted %d", q.Len(), 4)
+	}
+	for i := 1; i < 5; i++ {
+		m, ok := q.Dequeue()
+		if !ok || string(m.Value) != fmt.Sprintf("job%d", i) {
+			t.Fatalf("got %q, wanted %q", m.Value, fmt.Sprintf("job%d", i))
+		}
+		if err := q.Ack(m.ID); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if _, ok := q.Dequeue(); ok {
+		t.Errorf("got message from empty queue, wanted none")
+	}
+
+	// IDs are not reused once every message has been acknowledged
+	table2.Close()
+	table3, err := New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table3.Close()
+	q, _ = table3.Queue("jobs")
+	id, err := q.Enqueue([]byte("job5"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if id != 5 {
+		t.Errorf("got id %d, wanted %d", id, 5)
+	}
+}
@@ -0,0 +1,169 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"encoding/binary"
+	"math"
+	"slices"
+	"sort"
+)
+
+// rangeIndexPrefix is prepended to the name of a range index to form its
+// identifier among a table's indexes.
+const rangeIndexPrefix = "range/"
+
+// An Extractor derives the number under which a value is indexed by a range
+// index. It returns false if the value should not be indexed.
+type Extractor func(v []byte) (float64, bool)
+
+// WithRangeIndex maintains an ordered index, called name, of the numbers
+// produced by applying extract to each value in the table, which allows
+// keys to be looked up by a range of values using QueryRange. It may be used
+// more than once to create several indexes with different names.
+//
+// Like the search index, range indexes are saved to a file alongside the
+// data file when the table is closed and loaded from it when the table is
+// next opened. The file must be removed if extract is changed.
+func WithRangeIndex(name string, extract Extractor) Option {
+	return func(t *Table) {
+		t.addIndex(rangeIndexPrefix+name, &rangeIndex{extract: extract})
+	}
+}
+
+// QueryRange returns the keys of the items whose values are indexed by the
+// range index called name under a number between min and max inclusive,
+// ordered by that number and then by key. Soft deleted items are not
+// included. QueryRange returns nil if the table has no range index called
+// name.
+func (t *Table) QueryRange(name string, min, max float64) []string {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	ix, ok := t.indexes[rangeIndexPrefix+name].(*rangeIndex)
+	if !ok {
+		return nil
+	}
+
+	var keys []string
+	b, i := ix.find(rangeEntry{n: min})
+	ix.entries.scan(b, i, func(e rangeEntry) bool {
+		if e.n > max {
+			return false
+		}
+		if t.data[e.k].deleted == 0 {
+			keys = append(keys, e.k)
+		}
+		return true
+	})
+	return keys
+}
+
+type rangeEntry struct {
+	n float64
+	k string
+}
+
+// less reports whether e is ordered before x, by number and then by key.
+func (e rangeEntry) less(x rangeEntry) bool {
+	return e.n < x.n || e.n == x.n && e.k < x.k
+}
+
+// rangeIndex holds the indexed numbers and their keys, ordered by number and
+// then by key.
+type rangeIndex struct {
+	extract Extractor
+	entries blockList[rangeEntry]
+}
+
+// find returns the position in the index at which e is, or would be, held.
+func (r *rangeIndex) find(e rangeEntry) (int, int) {
+	return r.entries.search(func(x rangeEntry) bool { return !x.less(e) })
+}
+
+func (r *rangeIndex) entry(k string, v []byte) (rangeEntry, bool) {
+	n, ok := r.extract(v)
+	if !ok || math.IsNaN(n) {
+		return rangeEntry{}, false
+	}
+	return rangeEntry{n: n, k: k}, true
+}
+
+func (r *rangeIndex) add(k string, v []byte) {
+	e, ok := r.entry(k, v)
+	if !ok {
+		return
+	}
+	b, i := r.find(e)
+	if x, ok := r.entries.get(b, i); ok && x == e {
+		return
+	}
+	r.entries.insert(b, i, e)
+}
+
+func (r *rangeIndex) remove(k string, v []byte) {
+	e, ok := r.entry(k, v)
+	if !ok {
+		return
+	}
+	b, i := r.find(e)
+	if x, ok := r.entries.get(b, i); ok && x == e {
+		r.entries.remove(b, i)
+	}
+}
+
+func (r *rangeIndex) clear() {
+	r.entries.clear()
+}
+
+// build replaces the index's entries with those of the values passed to
+// add by each, sorting them once rather than adding them one at a time.
+func (r *rangeIndex) build(each func(add func(k string, v []byte))) {
+	var entries []rangeEntry
+	each(func(k string, v []byte) {
+		if e, ok := r.entry(k, v); ok {
+			entries = append(entries, e)
+		}
+	})
+	sort.Slice(entries, func(i, j int) bool { return entries[i].less(entries[j]) })
+	r.entries.set(slices.Compact(entries))
+}
+
+func (r *rangeIndex) marshal() []byte {
+	buf := binary.AppendUvarint(nil, uint64(r.entries.len()))
+	r.entries.scan(0, 0, func(e rangeEntry) bool {
+		buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(e.n))
+		buf = appendBytes(buf, []byte(e.k))
+		return true
+	})
+	return buf
+}
+
+func (r *rangeIndex) unmarshal(b []byte, keys interner) error {
+	n, b, err := consumeUvarint(b)
+	if err != nil {
+		return err
+	}
+	var entries []rangeEntry
+	for i := uint64(0); i < n; i++ {
+		if len(b) < 8 {
+			return ErrCorrupt
+		}
+		e := rangeEntry{n: math.Float64frombits(binary.BigEndian.Uint64(b))}
+		var k []byte
+		k, b, err = consumeBytes(b[8:])
+		if err != nil {
+			return err
+		}
+		e.k = keys.intern(k)
+		if len(entries) > 0 && !entries[len(entries)-1].less(e) {
+			// Entries are saved in order
+			return ErrCorrupt
+		}
+		entries = append(entries, e)
+	}
+	r.entries.set(entries)
+	return nil
+}
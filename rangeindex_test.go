@@ -0,0 +1,138 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func priceExtractor(v []byte) (float64, bool) {
+	var p struct {
+		Price *float64 `json:"price"`
+	}
+	if err := json.Unmarshal(v, &p); err != nil || p.Price == nil {
+		return 0, false
+	}
+	return *p.Price, true
+}
+
+func TestQueryRange(t *testing.T) {
+	tf, err := os.CreateTemp("", "lash")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tf.Close()
+	defer os.Remove(tf.Name())
+	defer os.Remove(tf.Name() + indexSuffix)
+
+	table, err := New(tf.Name(), 50, WithRangeIndex("price", priceExtractor))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table.PutString("apple", `{"price":1.5}`)
+	table.PutString("bread", `{"price":3}`)
+	table.PutString("cheese", `{"price":7.25}`)
+	table.PutString("dates", `{"price":3}`)
+	table.PutString("eggs", `{"name":"eggs"}`)
+
+	testCases := []struct {
+		min, max float64
+		want     []string
+	}{
+		{min: 0, max: 10, want: []string{"apple", "bread", "dates", "cheese"}},
+		{min: 3, max: 3, want: []string{"bread", "dates"}},
+		{min: 2, max: 7.25, want: []string{"bread", "dates", "cheese"}},
+		{min: 8, max: 10, want: nil},
+	}
+	for _, tc := range testCases {
+		if got := table.QueryRange("price", tc.min, tc.max); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v-%v: got %q, wanted %q", tc.min, tc.max, got, tc.want)
+		}
+	}
+	if got := table.QueryRange("weight", 0, 10); got != nil {
+		t.Errorf("got %q for unknown index, wanted nil", got)
+	}
+
+	table.PutString("apple", `{"price":9}`)
+	table.Delete("bread")
+	table.SoftDelete("dates")
+	if got, want := table.QueryRange("price", 0, 10), []string{"cheese", "apple"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+	table.Close()
+
+	table2, err := New(tf.Name(), 50, WithRangeIndex("price", priceExtractor))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table2.Close()
+	if got, want := table2.QueryRange("price", 0, 10), []string{"cheese", "apple"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q after reopen, wanted %q", got, want)
+	}
+	table2.Undelete("dates")
+	if got, want := table2.QueryRange("price", 0, 5), []string{"dates"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+}
+
+func TestQueryRangeMany(t *testing.T) {
+	tf, err := os.CreateTemp("", "lash")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tf.Close()
+	defer os.Remove(tf.Name())
+	defer os.Remove(tf.Name() + indexSuffix)
+
+	const n = 5000
+	table, err := New(tf.Name(), n, WithRangeIndex("price", priceExtractor))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	// Added in an order that inserts into the middle of existing blocks
+	for i := 0; i < n; i++ {
+		j := (i * 7919) % n
+		if err := table.PutString(fmt.Sprintf("k%05d", j), fmt.Sprintf(`{"price":%d}`, j)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	for i := 0; i < n; i += 2 {
+		table.Delete(fmt.Sprintf("k%05d", i))
+	}
+
+	check := func(table *Table, when string) {
+		got := table.QueryRange("price", 1000, 3999)
+		if len(got) != 1500 {
+			t.Fatalf("%s: got %d keys, wanted %d", when, len(got), 1500)
+		}
+		for i, k := range got {
+			if want := fmt.Sprintf("k%05d", 1001+2*i); k != want {
+				t.Fatalf("%s: got key %q at %d, wanted %q", when, k, i, want)
+			}
+		}
+	}
+	check(table, "after puts")
+	table.Close()
+
+	// Rebuilt from the data file rather than loaded from the saved index
+	os.Remove(tf.Name() + indexSuffix)
+	table2, err := New(tf.Name(), n, WithRangeIndex("price", priceExtractor))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table2.Close()
+	check(table2, "after rebuild")
+	for i := 0; i < n; i += 2 {
+		table2.PutString(fmt.Sprintf("k%05d", i), fmt.Sprintf(`{"price":%d}`, i))
+	}
+	if got := table2.QueryRange("price", 0, n); len(got) != n {
+		t.Errorf("got %d keys, wanted %d", len(got), n)
+	}
+}
@@ -0,0 +1,66 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"sync"
+	"time"
+)
+
+// WithRateLimit limits the rate at which the table accepts writes to
+// putsPerSec and reads to getsPerSec, allowing bursts of up to one second's
+// worth of operations. Calls that exceed the rate wait until they are
+// allowed to proceed, so a runaway loop is slowed rather than failed. Writes
+// include Put, PutAsync, Delete, SoftDelete, Undelete and the writes made
+// by views such as Counters and Set. Reads include Get and the reads made by
+// views. Bulk loads, restores and scans such as Filter are not limited.
+// There is no limit if a rate is zero or less.
+func WithRateLimit(putsPerSec, getsPerSec float64) Option {
+	return func(t *Table) {
+		t.putLimit = newLimiter(putsPerSec)
+		t.getLimit = newLimiter(getsPerSec)
+	}
+}
+
+// limiter is a token bucket that admits operations at a fixed rate.
+type limiter struct {
+	mu     sync.Mutex
+	rate   float64   // tokens added per second
+	burst  float64   // maximum number of tokens held
+	tokens float64   // tokens available at last, negative if operations are waiting
+	last   time.Time // time at which tokens was calculated
+}
+
+// newLimiter returns a limiter admitting rate operations per second, or nil
+// if rate is zero or less.
+func newLimiter(rate float64) *limiter {
+	if rate <= 0 {
+		return nil
+	}
+	burst := max(rate, 1)
+	return &limiter{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// wait blocks until an operation is admitted by the limiter. A nil limiter
+// admits every operation immediately.
+func (l *limiter) wait() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	// Take a token now, waiting for the deficit to be replenished if there
+	// are none available
+	l.tokens--
+	deficit := -l.tokens
+	l.mu.Unlock()
+
+	if deficit > 0 {
+		time.Sleep(time.Duration(deficit / l.rate * float64(time.Second)))
+	}
+}
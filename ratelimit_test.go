@@ -0,0 +1,53 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	table, err := New("", 50, WithRateLimit(20, 40))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+
+	// A burst of one second's worth of writes is admitted immediately and
+	// the remainder are paced at the configured rate
+	start := time.Now()
+	for i := 0; i < 25; i++ {
+		if err := table.PutString("k", "v"); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("got 25 writes in %s, wanted at least %s", elapsed, 250*time.Millisecond)
+	}
+
+	start = time.Now()
+	for i := 0; i < 50; i++ {
+		table.Get("k")
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("got 50 reads in %s, wanted at least %s", elapsed, 250*time.Millisecond)
+	}
+}
+
+func TestRateLimitUnlimited(t *testing.T) {
+	l := newLimiter(0)
+	if l != nil {
+		t.Fatalf("got limiter for zero rate, wanted nil")
+	}
+	start := time.Now()
+	for i := 0; i < 1000; i++ {
+		l.wait()
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("got 1000 operations in %s, wanted no waiting", elapsed)
+	}
+}
@@ -0,0 +1,43 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+// RecoveryReport describes what happened when a table's data file was read
+// while opening the table.
+type RecoveryReport struct {
+	// RecordsLoaded is the number of records read from the data file,
+	// including those superseded by later records.
+	RecordsLoaded int
+
+	// TombstonesSkipped is the number of records that had been marked as
+	// deleted. Unless the table is read only these are evicted from the
+	// data file when it is compacted during opening.
+	TombstonesSkipped int
+
+	// TruncatedBytes is the number of bytes of corrupt records, typically
+	// left by a write that was interrupted by a crash, that were discarded
+	// from the end of the data file.
+	TruncatedBytes int64
+
+	// BytesReclaimed is the number of bytes by which the data file shrank
+	// when it was compacted during opening.
+	BytesReclaimed int64
+
+	// InterruptedCompaction reports whether files left by an interrupted
+	// compaction were found and resolved.
+	InterruptedCompaction bool
+}
+
+// NewWithReport is like New but also returns a report describing what
+// happened while the table's data file was read. The report is zero for
+// tables that do not persist data or whose data file did not exist.
+func NewWithReport(fname string, n int, opts ...Option) (*Table, RecoveryReport, error) {
+	t, err := New(fname, n, opts...)
+	if t == nil {
+		return nil, RecoveryReport{}, err
+	}
+	return t, t.recovery, err
+}
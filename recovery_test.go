@@ -0,0 +1,88 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRecoveryReport(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	for _, k := range []string{"a", "b"} {
+		err = table.Put(k, []byte("value"))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	err = table.Delete("b")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = table.Put("c", []byte("value"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Close()
+
+	data, err := os.ReadFile(tf.Name())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = os.WriteFile(tf.Name(), data[:len(data)-3], 0o666)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = os.WriteFile(tf.Name()+compactSuffix, []byte("partial"), 0o666)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table, report, err := NewWithReport(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+
+	// The put for a and the delete record for b
+	if report.RecordsLoaded != 2 {
+		t.Errorf("got %d records loaded, wanted %d", report.RecordsLoaded, 2)
+	}
+	if report.TombstonesSkipped != 1 {
+		t.Errorf("got %d tombstones skipped, wanted %d", report.TombstonesSkipped, 1)
+	}
+	if report.TruncatedBytes <= 0 {
+		t.Errorf("got %d bytes truncated, wanted more than zero", report.TruncatedBytes)
+	}
+	if report.BytesReclaimed <= 0 {
+		t.Errorf("got %d bytes reclaimed, wanted more than zero", report.BytesReclaimed)
+	}
+	fi, err := os.Stat(tf.Name())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if got, want := fi.Size()+report.TruncatedBytes+report.BytesReclaimed, int64(len(data)-3); got != want {
+		t.Errorf("got %d bytes accounted for, wanted %d", got, want)
+	}
+	if !report.InterruptedCompaction {
+		t.Errorf("got no interrupted compaction, wanted one")
+	}
+
+	table.Close()
+	table, report, err = NewWithReport(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+	if report != (RecoveryReport{RecordsLoaded: 1}) {
+		t.Errorf("got %+v, wanted only one record loaded", report)
+	}
+}
@@ -0,0 +1,29 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+// WithKeyRedaction sets a function that is applied to keys before they
+// appear in the table's log messages, in the errors it returns and in the
+// sample of keys reported by DebugHandler, so that deployments that hold
+// personal or other sensitive data in keys can enable logging and debugging
+// safely. The function might return a hash of the key, a truncated key or a
+// fixed placeholder. Keys returned by methods of the table, such as Match,
+// and those recorded in the audit log are not redacted. The function must
+// not call methods of the table.
+func WithKeyRedaction(fn func(k string) string) Option {
+	return func(t *Table) {
+		t.redact = fn
+	}
+}
+
+// redactKey returns the key k as it should appear in log messages and
+// errors.
+func (t *Table) redactKey(k string) string {
+	if t.redact == nil {
+		return k
+	}
+	return t.redact(k)
+}
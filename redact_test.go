@@ -0,0 +1,72 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKeyRedaction(t *testing.T) {
+	tf, err := os.CreateTemp("", "lash")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tf.Close()
+	defer os.Remove(tf.Name())
+
+	var logbuf bytes.Buffer
+	redact := func(k string) string { return "<redacted>" }
+	table, err := New(tf.Name(), 50, WithKeyRedaction(redact), WithLogger(slog.New(slog.NewTextHandler(&logbuf, nil))))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+
+	if err := table.PutString("user:alice@example.com", "value"); err != nil {
+		t.Fatal(err.Error())
+	}
+	want := []string{"<redacted>"}
+	if keys := table.sampleKeys(10); !reflect.DeepEqual(keys, want) {
+		t.Errorf("got sample keys %v, wanted %v", keys, want)
+	}
+	if keys := table.Match("user:*"); !reflect.DeepEqual(keys, []string{"user:alice@example.com"}) {
+		t.Errorf("got keys %v from Match, wanted them unredacted", keys)
+	}
+
+	// Writes to a file opened for reading fail
+	ro, err := os.Open(tf.Name())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer ro.Close()
+	table.mtx.Lock()
+	dbfile := table.dbfile
+	table.dbfile = ro
+	table.mtx.Unlock()
+	err = table.PutString("user:bob@example.com", "value")
+	table.mtx.Lock()
+	table.dbfile = dbfile
+	table.mtx.Unlock()
+	if err == nil {
+		t.Fatal("got no error writing to read only file, wanted error")
+	}
+	if strings.Contains(err.Error(), "bob") || !strings.Contains(err.Error(), "<redacted>") {
+		t.Errorf("got error %q, wanted key redacted", err)
+	}
+
+	if err := table.Flags("flag.").SetString("mode", "x"); err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Flags("flag.").Int("mode", 0)
+	if strings.Contains(logbuf.String(), "flag.mode") || !strings.Contains(logbuf.String(), "<redacted>") {
+		t.Errorf("got log %q, wanted key redacted", logbuf.String())
+	}
+}
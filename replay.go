@@ -0,0 +1,77 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"sort"
+)
+
+// An Entry is an item in the table together with the sequence number of
+// the write that stored it.
+type Entry struct {
+	// Seq is the sequence number of the write that stored the value. Each
+	// write to the table is assigned a sequence number that is greater
+	// than that of any earlier write.
+	Seq uint64
+
+	Key   string
+	Value []byte
+
+	// Meta holds the metadata for the item.
+	Meta Meta
+}
+
+// LastSeq returns the sequence number of the most recent write to the
+// table, or zero if nothing has been written. Sequence numbers are stored
+// with each record in the data file so they continue from the same point
+// when the table is reopened.
+func (t *Table) LastSeq() uint64 {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	return t.seq
+}
+
+// EachBySeq calls fn for each item in the table in the order of the
+// sequence numbers of the writes that stored their current values. It
+// describes a snapshot of the table rather than its history: soft deleted
+// items, deleted items and superseded values are not included since the
+// data file does not retain them, so it cannot be used to replay every
+// change made to the table. EachBySeq operates on a snapshot of the table
+// taken when it is called so fn may safely call other methods of the table.
+// EachBySeq stops and returns the first error returned by fn.
+func (t *Table) EachBySeq(fn func(e Entry) error) error {
+	t.mtx.RLock()
+	entries := make([]Entry, 0, len(t.data)-t.trashed)
+	for k, p := range t.data {
+		if p.deleted != 0 {
+			continue
+		}
+		v, err := t.value(p)
+		if err != nil {
+			t.mtx.RUnlock()
+			return err
+		}
+		entries = append(entries, Entry{
+			Seq:   p.seq,
+			Key:   k,
+			Value: v,
+			Meta: Meta{
+				Created: unixTime(p.created),
+				Updated: unixTime(p.updated),
+				Writes:  p.writes,
+			},
+		})
+	}
+	t.mtx.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+	for _, e := range entries {
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestEachBySeq(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	for _, k := range []string{"c", "a", "d", "b", "a"} {
+		err = table.Put(k, []byte(k))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	err = table.Delete("d")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Close()
+
+	// Sequence numbers must survive compaction during initialisation
+	table2, err := New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table2.Close()
+
+	var keys string
+	var last uint64
+	err = table2.EachBySeq(func(e Entry) error {
+		if e.Seq <= last {
+			t.Errorf("got seq %d after %d, wanted increasing sequence", e.Seq, last)
+		}
+		last = e.Seq
+		keys += e.Key
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if keys != "cba" {
+		t.Errorf("got keys %q, wanted %q", keys, "cba")
+	}
+
+	errStop := errors.New("stop")
+	n := 0
+	err = table2.EachBySeq(func(e Entry) error {
+		n++
+		return errStop
+	})
+	if err != errStop {
+		t.Errorf("got error %v, wanted %v", err, errStop)
+	}
+	if n != 1 {
+		t.Errorf("got %d calls, wanted %d", n, 1)
+	}
+}
+
+func TestLastSeq(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	if seq := table.LastSeq(); seq != 0 {
+		t.Errorf("got seq %d for empty table, wanted %d", seq, 0)
+	}
+	for _, k := range []string{"a", "b", "a"} {
+		err = table.Put(k, []byte(k))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	err = table.Delete("b")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if seq := table.LastSeq(); seq != 4 {
+		t.Errorf("got seq %d, wanted %d", seq, 4)
+	}
+	table.Close()
+
+	table2, err := New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table2.Close()
+	if seq := table2.LastSeq(); seq != 4 {
+		t.Errorf("got seq %d after reopening, wanted %d", seq, 4)
+	}
+	err = table2.Put("c", []byte("c"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if seq := table2.LastSeq(); seq != 5 {
+		t.Errorf("got seq %d, wanted %d", seq, 5)
+	}
+}
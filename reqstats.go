@@ -0,0 +1,118 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+)
+
+// RequestStats accumulates the work done by a table on behalf of a single
+// request. Attach it to a context using WithRequestStats and pass the
+// context to the Ctx variants of the table's methods, such as GetCtx, which
+// record their work in it. The counters may be updated concurrently and
+// should be read once the request has completed.
+type RequestStats struct {
+	Gets         atomic.Int64 // number of values requested
+	Hits         atomic.Int64 // number of values found
+	Puts         atomic.Int64 // number of values stored
+	Deletes      atomic.Int64 // number of keys deleted
+	BytesRead    atomic.Int64 // bytes of values found
+	BytesWritten atomic.Int64 // bytes of keys and values stored
+}
+
+// LogValue returns the counters as a group so that the stats may be added
+// directly to a request log using the slog package.
+func (s *RequestStats) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int64("gets", s.Gets.Load()),
+		slog.Int64("hits", s.Hits.Load()),
+		slog.Int64("puts", s.Puts.Load()),
+		slog.Int64("deletes", s.Deletes.Load()),
+		slog.Int64("bytes_read", s.BytesRead.Load()),
+		slog.Int64("bytes_written", s.BytesWritten.Load()),
+	)
+}
+
+type requestStatsKey struct{}
+
+// WithRequestStats returns a copy of ctx to which s is attached.
+func WithRequestStats(ctx context.Context, s *RequestStats) context.Context {
+	return context.WithValue(ctx, requestStatsKey{}, s)
+}
+
+// RequestStatsFrom returns the stats attached to ctx, or nil if there are
+// none.
+func RequestStatsFrom(ctx context.Context) *RequestStats {
+	s, _ := ctx.Value(requestStatsKey{}).(*RequestStats)
+	return s
+}
+
+// GetCtx is like Get but records its work in any RequestStats attached to
+// ctx.
+func (t *Table) GetCtx(ctx context.Context, k string) ([]byte, bool) {
+	v, found := t.Get(k)
+	if s := RequestStatsFrom(ctx); s != nil {
+		s.Gets.Add(1)
+		if found {
+			s.Hits.Add(1)
+			s.BytesRead.Add(int64(len(v)))
+		}
+	}
+	return v, found
+}
+
+// PutCtx is like Put but records its work in any RequestStats attached to
+// ctx and records any actor attached to ctx by WithActor in the table's
+// audit log. It returns the context's error without storing the value if
+// ctx has been cancelled.
+func (t *Table) PutCtx(ctx context.Context, k string, v []byte, d ...Durability) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var dur Durability
+	if len(d) > 0 {
+		dur = d[len(d)-1]
+	}
+	t.putLimit.wait()
+	t.mtx.Lock()
+	t.actor = ActorFrom(ctx)
+	err := t.put(k, v, dur)
+	t.actor = ""
+	t.mtx.Unlock()
+	if err != nil {
+		return err
+	}
+	if s := RequestStatsFrom(ctx); s != nil {
+		s.Puts.Add(1)
+		s.BytesWritten.Add(int64(len(k) + len(v)))
+	}
+	return nil
+}
+
+// DeleteCtx is like Delete but records its work in any RequestStats
+// attached to ctx and records any actor attached to ctx by WithActor in the
+// table's audit log. It returns the context's error without deleting the key
+// if ctx has been cancelled.
+func (t *Table) DeleteCtx(ctx context.Context, k string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	t.putLimit.wait()
+	t.mtx.Lock()
+	t.actor = ActorFrom(ctx)
+	err := t.delete(k, 0)
+	t.actor = ""
+	t.mtx.Unlock()
+	if err != nil {
+		return err
+	}
+	if s := RequestStatsFrom(ctx); s != nil {
+		s.Deletes.Add(1)
+	}
+	return nil
+}
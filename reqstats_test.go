@@ -0,0 +1,77 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRequestStats(t *testing.T) {
+	table, err := New("", 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+
+	var stats RequestStats
+	ctx := WithRequestStats(context.Background(), &stats)
+	if RequestStatsFrom(ctx) != &stats {
+		t.Fatalf("got different stats from context, wanted those attached")
+	}
+
+	if err := table.PutCtx(ctx, "a", []byte("value")); err != nil {
+		t.Fatal(err.Error())
+	}
+	table.GetCtx(ctx, "a")
+	table.GetCtx(ctx, "b")
+	if err := table.DeleteCtx(ctx, "a"); err != nil {
+		t.Fatal(err.Error())
+	}
+	// Work done without the context is not recorded
+	table.Get("a")
+
+	checks := []struct {
+		name string
+		got  int64
+		want int64
+	}{
+		{name: "gets", got: stats.Gets.Load(), want: 2},
+		{name: "hits", got: stats.Hits.Load(), want: 1},
+		{name: "puts", got: stats.Puts.Load(), want: 1},
+		{name: "deletes", got: stats.Deletes.Load(), want: 1},
+		{name: "bytes read", got: stats.BytesRead.Load(), want: 5},
+		{name: "bytes written", got: stats.BytesWritten.Load(), want: 6},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("got %d %s, wanted %d", c.got, c.name, c.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("request", "lash", &stats)
+	if !strings.Contains(buf.String(), "lash.gets=2") {
+		t.Errorf("got log %q, wanted it to include the stats", buf.String())
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := table.PutCtx(cancelled, "c", []byte("value")); err != context.Canceled {
+		t.Errorf("got error %v, wanted %v", err, context.Canceled)
+	}
+	if _, found := table.Get("c"); found {
+		t.Errorf("got value stored with cancelled context, wanted none")
+	}
+
+	// A context without stats is accepted
+	if err := table.PutCtx(context.Background(), "d", []byte("value")); err != nil {
+		t.Fatal(err.Error())
+	}
+}
@@ -0,0 +1,160 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// maxScrubChunk is the largest number of bytes the scrubber reads at once.
+const maxScrubChunk = 64 << 10
+
+// errScrubStopped is returned by a scrub that was interrupted because the
+// table is being closed.
+var errScrubStopped = errors.New("lash: scrub stopped")
+
+// WithScrub starts a scrubber that re-reads the table's data file every
+// period d, decoding every record and verifying its checksum, so that damage
+// to the file such as bit rot is discovered before the table is next opened.
+// The file is read at no more than bytesPerSec bytes per second, or without
+// limit if bytesPerSec is zero or less, so that scrubbing does not compete
+// with the table's own use of the disk. Damage is logged, counted in
+// Stats.ScrubErrors and passed to onCorrupt, which may be nil. A scrub that
+// is overtaken by a compaction is abandoned and the next starts after the
+// following period. WithScrub has no effect on tables opened using
+// WithReadOnly or that do not persist data.
+func WithScrub(d time.Duration, bytesPerSec int, onCorrupt func(error)) Option {
+	return func(t *Table) {
+		t.scrub = &scrubber{interval: d, rate: bytesPerSec, onCorrupt: onCorrupt}
+	}
+}
+
+// scrubber periodically verifies a table's data file.
+type scrubber struct {
+	interval  time.Duration
+	rate      int // bytes per second, no limit if zero or less
+	onCorrupt func(error)
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// startScrubber starts a goroutine that scrubs the table's data file every
+// scrub interval.
+func (t *Table) startScrubber() {
+	s := t.scrub
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go t.runScrubber(s)
+}
+
+func (t *Table) runScrubber(s *scrubber) {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			err := t.scrubFile(s)
+			if err == errScrubStopped {
+				return
+			}
+			t.mtx.Lock()
+			if err == nil {
+				t.scrubbed = t.now()
+			} else {
+				t.scrubErrors++
+			}
+			t.mtx.Unlock()
+			if err != nil {
+				t.logger.Error("lash: scrub found damage in data file", "file", t.filename, "error", err)
+				if s.onCorrupt != nil {
+					s.onCorrupt(err)
+				}
+			}
+		}
+	}
+}
+
+// stopScrubber stops the table's scrubber, if any, and waits for it to exit.
+func (t *Table) stopScrubber() {
+	if t.scrub == nil || t.scrub.stop == nil {
+		return
+	}
+	close(t.scrub.stop)
+	<-t.scrub.done
+	t.scrub.stop = nil
+}
+
+// scrubFile reads every record in the table's data file, returning an error
+// describing the first that cannot be decoded. It returns nil if the data
+// file was replaced by a compaction while it was being read.
+func (t *Table) scrubFile(s *scrubber) error {
+	t.mtx.RLock()
+	f, end := t.dbfile, t.size
+	t.mtx.RUnlock()
+	if f == nil {
+		return nil
+	}
+
+	r := &scrubReader{r: io.NewSectionReader(f, 0, end), stop: s.stop, chunk: maxScrubChunk}
+	if s.rate > 0 {
+		r.chunk = min(maxScrubChunk, s.rate)
+		r.limit = newLimiter(float64(s.rate) / float64(r.chunk))
+	}
+	d, err := newDecoder(bufio.NewReaderSize(r, r.chunk))
+	for err == nil {
+		pos := d.offset()
+		if _, err = d.next(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			err = fmt.Errorf("lash: damaged record at offset %d: %w", pos, err)
+		}
+	}
+
+	// The error may be due to the scrub being interrupted or the file
+	// being closed rather than damage
+	select {
+	case <-s.stop:
+		return errScrubStopped
+	default:
+	}
+	t.mtx.RLock()
+	replaced := t.dbfile != f
+	t.mtx.RUnlock()
+	if replaced {
+		return nil
+	}
+	return err
+}
+
+// scrubReader reads from r in chunks, waiting for the limiter, if any,
+// before each, until stop is closed.
+type scrubReader struct {
+	r     io.Reader
+	stop  chan struct{}
+	chunk int
+	limit *limiter
+}
+
+func (s *scrubReader) Read(p []byte) (int, error) {
+	select {
+	case <-s.stop:
+		return 0, errScrubStopped
+	default:
+	}
+	s.limit.wait()
+	if len(p) > s.chunk {
+		p = p[:s.chunk]
+	}
+	return s.r.Read(p)
+}
@@ -0,0 +1,64 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScrub(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Close()
+	defer os.Remove(tf.Name())
+
+	damage := make(chan error, 10)
+	table, err = New(tf.Name(), 50, WithScrub(5*time.Millisecond, 1<<20, func(err error) { damage <- err }))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+	if err := table.Put("a", []byte(strings.Repeat("value", 100))); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for table.Stats().LastScrub.IsZero() {
+		if time.Now().After(deadline) {
+			t.Fatalf("got no scrub, wanted one to complete")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Flip a bit in the middle of the value
+	f, err := os.OpenFile(tf.Name(), os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	size := table.Stats().FileBytes
+	b := make([]byte, 1)
+	f.ReadAt(b, size-100)
+	b[0] ^= 1
+	f.WriteAt(b, size-100)
+	f.Close()
+
+	select {
+	case err := <-damage:
+		if !strings.Contains(err.Error(), "damaged record") {
+			t.Errorf("got error %v, wanted damaged record", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("got no damage reported, wanted it reported")
+	}
+	if table.Stats().ScrubErrors == 0 {
+		t.Errorf("got no scrub errors counted, wanted at least one")
+	}
+}
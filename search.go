@@ -0,0 +1,156 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"encoding/binary"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// searchIndexID identifies the search index among a table's indexes.
+const searchIndexID = "search"
+
+// A Tokenizer splits a value into the tokens under which it is indexed for
+// Search.
+type Tokenizer func(s string) []string
+
+// DefaultTokenizer splits s into words separated by any character that is
+// not a letter or a number and converts them to lower case.
+func DefaultTokenizer(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// WithSearchIndex maintains an inverted index of the tokens produced by
+// applying tokenize to each value in the table, which allows keys to be
+// looked up by the content of their values using Search. If tokenize is nil
+// then DefaultTokenizer is used.
+//
+// The index is held in memory and saved to a file alongside the data file,
+// with the suffix .idx, when the table is closed. It is loaded from that
+// file when the table is next opened, or rebuilt from the table's values if
+// the file is missing or out of date. The file must be removed if tokenize
+// is changed.
+func WithSearchIndex(tokenize Tokenizer) Option {
+	return func(t *Table) {
+		if tokenize == nil {
+			tokenize = DefaultTokenizer
+		}
+		t.search = &searchIndex{
+			tokenize: tokenize,
+			postings: make(map[string]map[string]struct{}),
+		}
+		t.addIndex(searchIndexID, t.search)
+	}
+}
+
+// Search returns the keys of the items whose values contain every token
+// produced by applying the table's tokenizer to token, in sorted order.
+// Soft deleted items are not included. Search returns nil if the table was
+// not created using WithSearchIndex.
+func (t *Table) Search(token string) []string {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	if t.search == nil {
+		return nil
+	}
+
+	var keys []string
+	tokens := t.search.tokenize(token)
+	if len(tokens) == 0 {
+		return nil
+	}
+	for k := range t.search.postings[tokens[0]] {
+		match := true
+		for _, tok := range tokens[1:] {
+			if _, ok := t.search.postings[tok][k]; !ok {
+				match = false
+				break
+			}
+		}
+		if match && t.data[k].deleted == 0 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// searchIndex maps each token to the set of keys whose values contain it.
+type searchIndex struct {
+	tokenize Tokenizer
+	postings map[string]map[string]struct{}
+}
+
+func (s *searchIndex) add(k string, v []byte) {
+	for _, tok := range s.tokenize(string(v)) {
+		keys, ok := s.postings[tok]
+		if !ok {
+			keys = make(map[string]struct{})
+			s.postings[tok] = keys
+		}
+		keys[k] = struct{}{}
+	}
+}
+
+func (s *searchIndex) remove(k string, v []byte) {
+	for _, tok := range s.tokenize(string(v)) {
+		keys := s.postings[tok]
+		delete(keys, k)
+		if len(keys) == 0 {
+			delete(s.postings, tok)
+		}
+	}
+}
+
+func (s *searchIndex) clear() {
+	s.postings = make(map[string]map[string]struct{})
+}
+
+func (s *searchIndex) marshal() []byte {
+	buf := binary.AppendUvarint(nil, uint64(len(s.postings)))
+	for tok, keys := range s.postings {
+		buf = appendBytes(buf, []byte(tok))
+		buf = binary.AppendUvarint(buf, uint64(len(keys)))
+		for k := range keys {
+			buf = appendBytes(buf, []byte(k))
+		}
+	}
+	return buf
+}
+
+func (s *searchIndex) unmarshal(b []byte, in interner) error {
+	n, b, err := consumeUvarint(b)
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < n; i++ {
+		var tok []byte
+		var count uint64
+		tok, b, err = consumeBytes(b)
+		if err != nil {
+			return err
+		}
+		count, b, err = consumeUvarint(b)
+		if err != nil {
+			return err
+		}
+		keys := make(map[string]struct{})
+		for j := uint64(0); j < count; j++ {
+			var k []byte
+			k, b, err = consumeBytes(b)
+			if err != nil {
+				return err
+			}
+			keys[in.intern(k)] = struct{}{}
+		}
+		s.postings[string(tok)] = keys
+	}
+	return nil
+}
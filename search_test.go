@@ -0,0 +1,125 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	tf, err := os.CreateTemp("", "lash")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tf.Close()
+	defer os.Remove(tf.Name())
+	defer os.Remove(tf.Name() + indexSuffix)
+
+	table, err := New(tf.Name(), 50, WithSearchIndex(nil))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table.PutString("a", "The quick brown fox")
+	table.PutString("b", "the lazy dog")
+	table.PutString("c", "Quick thinking")
+	table.PutString("d", "a brown dog")
+
+	testCases := []struct {
+		token string
+		want  []string
+	}{
+		{token: "quick", want: []string{"a", "c"}},
+		{token: "THE", want: []string{"a", "b"}},
+		{token: "brown dog", want: []string{"d"}},
+		{token: "cat", want: nil},
+	}
+	for _, tc := range testCases {
+		if got := table.Search(tc.token); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %q, wanted %q", tc.token, got, tc.want)
+		}
+	}
+
+	table.PutString("a", "slow fox")
+	table.Delete("b")
+	table.SoftDelete("c")
+	if got, want := table.Search("quick"), []string(nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+	if got, want := table.Search("the"), []string(nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+	table.Undelete("c")
+
+	if err := table.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := os.Stat(tf.Name() + indexSuffix); err != nil {
+		t.Fatalf("got error %v, wanted index file", err)
+	}
+
+	// The saved index is used rather than tokenizing every value
+	calls := 0
+	counting := func(s string) []string {
+		calls++
+		return DefaultTokenizer(s)
+	}
+	table2, err := New(tf.Name(), 50, WithSearchIndex(counting))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if calls != 0 {
+		t.Errorf("got %d calls to tokenizer, wanted %d", calls, 0)
+	}
+	if _, err := os.Stat(tf.Name() + indexSuffix); !os.IsNotExist(err) {
+		t.Errorf("got error %v, wanted index file to be removed", err)
+	}
+	if got, want := table2.Search("fox"), []string{"a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+	table2.PutString("e", "extra")
+	table2.Close()
+	saved, err := os.ReadFile(tf.Name() + indexSuffix)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// A saved index that is out of date is rebuilt
+	table3, err := New(tf.Name(), 50, WithSearchIndex(nil))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table3.PutString("f", "another fox")
+	table3.dbfile.Close()
+	table3.dbfile = nil
+	if err := os.WriteFile(tf.Name()+indexSuffix, saved, 0666); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table4, err := New(tf.Name(), 50, WithSearchIndex(nil))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table4.Close()
+	if got, want := table4.Search("fox"), []string{"a", "f"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+}
+
+func TestSearchWithoutIndex(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	table.PutString("a", "quick")
+	if got := table.Search("quick"); got != nil {
+		t.Errorf("got %q, wanted nil", got)
+	}
+}
@@ -0,0 +1,63 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+// sequenceBatch is the number of IDs reserved by each durable write made by
+// NextSequence.
+const sequenceBatch = 1000
+
+// sequence tracks the IDs issued by a named sequence.
+type sequence struct {
+	last     uint64 // last ID issued
+	reserved uint64 // highest ID reserved in the table
+}
+
+// NextSequence returns the next ID from the sequence with the given name.
+// IDs start at one and increase by one with each call, and are never
+// reissued, even after the table is reopened. To avoid an fsync for every
+// ID, IDs are reserved in batches whose upper bound is committed to stable
+// storage under a key formed by CompositeKey from the name of the sequence.
+// Any IDs reserved but not issued before the table is closed are skipped,
+// so the IDs issued over the life of the table may have gaps.
+func (t *Table) NextSequence(name string) (uint64, error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	k := sequenceKey(name)
+	s, ok := t.sequences[name]
+	if !ok {
+		s = &sequence{}
+		if cur, exists := t.data[k]; exists && cur.deleted == 0 {
+			v, err := t.value(cur)
+			if err != nil {
+				return 0, err
+			}
+			n, err := ParseUint64Key(string(v))
+			if err != nil {
+				return 0, err
+			}
+			s.last, s.reserved = n, n
+		}
+		if t.sequences == nil {
+			t.sequences = make(map[string]*sequence)
+		}
+		t.sequences[name] = s
+	}
+
+	if s.last == s.reserved {
+		reserved := s.reserved + sequenceBatch
+		if err := t.put(k, []byte(Uint64Key(reserved)), Durable); err != nil {
+			return 0, err
+		}
+		s.reserved = reserved
+	}
+	s.last++
+	return s.last, nil
+}
+
+func sequenceKey(name string) string {
+	return CompositeKey([]byte(name), []byte("sequence"))
+}
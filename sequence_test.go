@@ -0,0 +1,56 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"testing"
+)
+
+func TestNextSequence(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	for want := uint64(1); want <= sequenceBatch+5; want++ {
+		id, err := table.NextSequence("orders")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if id != want {
+			t.Fatalf("got id %d, wanted %d", id, want)
+		}
+	}
+	id, err := table.NextSequence("invoices")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if id != 1 {
+		t.Errorf("got id %d for new sequence, wanted %d", id, 1)
+	}
+	// Only one write is needed for each batch of IDs
+	if m, _ := table.GetMeta(sequenceKey("orders")); m.Writes != 2 {
+		t.Errorf("got %d writes, wanted %d", m.Writes, 2)
+	}
+	table.Close()
+
+	table, err = New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+
+	// The unissued IDs of the reserved batch are skipped
+	id, err = table.NextSequence("orders")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if id != 2*sequenceBatch+1 {
+		t.Errorf("got id %d after reopening, wanted %d", id, 2*sequenceBatch+1)
+	}
+}
@@ -0,0 +1,99 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package sessions
+
+import (
+	"net/http"
+
+	gsessions "github.com/gorilla/sessions"
+)
+
+// GorillaStore adapts a Store to the gorilla/sessions Store interface. The
+// session cookie holds only the session ID, which is unguessable, and the
+// session values are held in the underlying store. Session values are of
+// type map[interface{}]interface{}, which requires the store to use
+// GobCodec.
+type GorillaStore struct {
+	Store *Store
+
+	// Options are the default options for new sessions.
+	Options *gsessions.Options
+}
+
+var _ gsessions.Store = (*GorillaStore)(nil)
+
+// NewGorillaStore returns a GorillaStore that holds sessions in s. The
+// session cookies are HTTP only, apply to all paths and expire at the same
+// time as the sessions.
+func NewGorillaStore(s *Store) *GorillaStore {
+	return &GorillaStore{
+		Store: s,
+		Options: &gsessions.Options{
+			Path:     "/",
+			MaxAge:   int(s.ttl.Seconds()),
+			HttpOnly: true,
+		},
+	}
+}
+
+// Get returns the named session for the request, which is cached in the
+// request's session registry.
+func (g *GorillaStore) Get(r *http.Request, name string) (*gsessions.Session, error) {
+	return gsessions.GetRegistry(r).Get(g, name)
+}
+
+// New returns the named session for the request without adding it to the
+// registry. If the request has no session cookie or the session has expired
+// then a new, empty session is returned.
+func (g *GorillaStore) New(r *http.Request, name string) (*gsessions.Session, error) {
+	session := gsessions.NewSession(g, name)
+	opts := *g.Options
+	session.Options = &opts
+	session.IsNew = true
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	err = g.Store.Load(c.Value, &session.Values)
+	if err != nil {
+		if err == ErrNotFound {
+			return session, nil
+		}
+		return session, err
+	}
+	session.ID = c.Value
+	session.IsNew = false
+	return session, nil
+}
+
+// Save stores the session and adds its cookie to the response. If the
+// session's MaxAge option is negative or zero then the session is deleted
+// and its cookie is cleared.
+func (g *GorillaStore) Save(r *http.Request, w http.ResponseWriter, session *gsessions.Session) error {
+	if session.Options.MaxAge <= 0 {
+		if session.ID != "" {
+			if err := g.Store.Delete(session.ID); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, gsessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	if session.ID == "" {
+		id, err := NewID()
+		if err != nil {
+			return err
+		}
+		session.ID = id
+	}
+	if err := g.Store.Save(session.ID, session.Values); err != nil {
+		return err
+	}
+	http.SetCookie(w, gsessions.NewCookie(session.Name(), session.ID, session.Options))
+	return nil
+}
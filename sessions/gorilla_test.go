@@ -0,0 +1,69 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package sessions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGorillaStore(t *testing.T) {
+	s, _ := makeStore(t)
+	g := NewGorillaStore(s)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	session, err := g.Get(r, "sid")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !session.IsNew {
+		t.Errorf("got existing session, wanted new")
+	}
+	session.Values["user"] = "alice"
+	w := httptest.NewRecorder()
+	if err := session.Save(r, w); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("got %d cookies, wanted %d", len(cookies), 1)
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(cookies[0])
+	session, err = g.Get(r, "sid")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if session.IsNew {
+		t.Errorf("got new session, wanted existing")
+	}
+	if session.Values["user"] != "alice" {
+		t.Errorf("got %v, wanted %q", session.Values["user"], "alice")
+	}
+
+	// Deleting the session clears the cookie
+	session.Options.MaxAge = -1
+	w = httptest.NewRecorder()
+	if err := session.Save(r, w); err != nil {
+		t.Fatal(err.Error())
+	}
+	if c := w.Result().Cookies(); len(c) != 1 || c[0].MaxAge >= 0 {
+		t.Errorf("got cookies %v, wanted an expired cookie", c)
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "sid", Value: cookies[0].Value})
+	session, err = g.Get(r, "sid")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !session.IsNew {
+		t.Errorf("got existing session, wanted new after deletion")
+	}
+}
@@ -0,0 +1,266 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+// Package sessions provides a store for web sessions held in a lash Table.
+// Sessions are identified by random opaque IDs and expire after a period of
+// time, which may optionally be extended each time a session is loaded.
+package sessions
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"iter"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iand/lash"
+)
+
+// ErrNotFound is returned when a session does not exist or has expired.
+var ErrNotFound = errors.New("sessions: session not found")
+
+// DefaultKeyPrefix is prepended to session IDs to form the keys under which
+// sessions are stored, unless changed using WithKeyPrefix.
+const DefaultKeyPrefix = "session/"
+
+// idLen is the number of random bytes in a session ID.
+const idLen = 32
+
+// A Codec converts session data to and from the form in which it is stored.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(b []byte, v any) error
+}
+
+var (
+	// GobCodec encodes session data using encoding/gob. It is the default.
+	GobCodec Codec = gobCodec{}
+
+	// JSONCodec encodes session data using encoding/json.
+	JSONCodec Codec = jsonCodec{}
+)
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(b []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(v)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error)   { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(b []byte, v any) error { return json.Unmarshal(b, v) }
+
+// An Option configures a Store when it is created by New.
+type Option func(*Store)
+
+// WithCodec sets the codec used to encode session data. The default is
+// GobCodec.
+func WithCodec(c Codec) Option {
+	return func(s *Store) {
+		s.codec = c
+	}
+}
+
+// WithSlidingExpiry extends the life of a session each time it is loaded,
+// so that sessions expire only after a period of inactivity.
+func WithSlidingExpiry() Option {
+	return func(s *Store) {
+		s.sliding = true
+	}
+}
+
+// WithKeyPrefix sets the prefix prepended to session IDs to form the keys
+// under which sessions are stored. The default is DefaultKeyPrefix.
+func WithKeyPrefix(prefix string) Option {
+	return func(s *Store) {
+		s.prefix = prefix
+	}
+}
+
+// Store holds sessions in a lash Table. Each session is stored under a key
+// formed from its ID and the store's key prefix, together with the time at
+// which it expires. Keys with the prefix should not be modified other than
+// through the store.
+type Store struct {
+	mu      sync.Mutex // serialises read-modify-write of sessions
+	t       *lash.Table
+	ttl     time.Duration
+	codec   Codec
+	sliding bool
+	prefix  string
+	now     func() time.Time
+}
+
+// New returns a Store that holds sessions in t, each of which expires ttl
+// after it was last saved.
+func New(t *lash.Table, ttl time.Duration, opts ...Option) *Store {
+	s := &Store{
+		t:      t,
+		ttl:    ttl,
+		codec:  GobCodec,
+		prefix: DefaultKeyPrefix,
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// NewID returns a new random session ID.
+func NewID() (string, error) {
+	b := make([]byte, idLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Create stores data as a new session and returns its ID.
+func (s *Store) Create(data any) (string, error) {
+	id, err := NewID()
+	if err != nil {
+		return "", err
+	}
+	if err := s.Save(id, data); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Save stores data as the session with the given ID, replacing any existing
+// data, and resets the time at which the session expires.
+func (s *Store) Save(id string, data any) error {
+	b, err := s.codec.Marshal(data)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.put(id, b)
+}
+
+// Load decodes the data of the session with the given ID into data. It
+// returns ErrNotFound if the session does not exist or has expired. If the
+// store uses sliding expiry then the time at which the session expires is
+// reset.
+func (s *Store) Load(id string, data any) error {
+	s.mu.Lock()
+	b, err := s.get(id)
+	if err == nil && s.sliding {
+		err = s.put(id, b)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return s.codec.Unmarshal(b, data)
+}
+
+// Touch resets the time at which the session with the given ID expires. It
+// returns ErrNotFound if the session does not exist or has expired.
+func (s *Store) Touch(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.get(id)
+	if err != nil {
+		return err
+	}
+	return s.put(id, b)
+}
+
+// Delete removes the session with the given ID.
+func (s *Store) Delete(id string) error {
+	return s.t.Delete(s.prefix + id)
+}
+
+// Cleanup removes all expired sessions from the table and returns the
+// number removed.
+func (s *Store) Cleanup() (int, error) {
+	now := s.now()
+	expired := s.t.Filter(func(k string, v []byte) bool {
+		if !strings.HasPrefix(k, s.prefix) {
+			return false
+		}
+		exp, _, ok := decode(v)
+		return ok && !now.Before(exp)
+	})
+
+	n := 0
+	for k := range expired {
+		if err := s.t.Delete(k); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Expiring returns an iterator over the IDs of sessions that have not yet
+// expired but will expire within the given period, so that applications
+// can refresh them before they do. The sessions are those in the table
+// when the iterator is called, in no particular order.
+func (s *Store) Expiring(within time.Duration) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		now := s.now()
+		end := now.Add(within)
+		expiring := s.t.Filter(func(k string, v []byte) bool {
+			if !strings.HasPrefix(k, s.prefix) {
+				return false
+			}
+			exp, _, ok := decode(v)
+			return ok && now.Before(exp) && !end.Before(exp)
+		})
+		for k := range expiring {
+			if !yield(strings.TrimPrefix(k, s.prefix)) {
+				return
+			}
+		}
+	}
+}
+
+// get returns the encoded data of the session with the given ID.
+// It is the responsibility of the caller to acquire locks.
+func (s *Store) get(id string) ([]byte, error) {
+	v, found := s.t.Get(s.prefix + id)
+	if !found {
+		return nil, ErrNotFound
+	}
+	exp, b, ok := decode(v)
+	if !ok || !s.now().Before(exp) {
+		return nil, ErrNotFound
+	}
+	return b, nil
+}
+
+// put stores the encoded data b as the session with the given ID.
+// It is the responsibility of the caller to acquire locks.
+func (s *Store) put(id string, b []byte) error {
+	v := binary.BigEndian.AppendUint64(nil, uint64(s.now().Add(s.ttl).UnixNano()))
+	return s.t.Put(s.prefix+id, append(v, b...))
+}
+
+// decode splits a stored session into its expiry time and data.
+func decode(v []byte) (time.Time, []byte, bool) {
+	if len(v) < 8 {
+		return time.Time{}, nil, false
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(v))), v[8:], true
+}
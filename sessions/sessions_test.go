@@ -0,0 +1,147 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package sessions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iand/lash"
+)
+
+type cart struct {
+	User  string
+	Items []string
+}
+
+func makeStore(t *testing.T, opts ...Option) (*Store, *time.Time) {
+	table, err := lash.New("", 10)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := New(table, time.Hour, opts...)
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+func TestStore(t *testing.T) {
+	for _, codec := range []Codec{GobCodec, JSONCodec} {
+		s, now := makeStore(t, WithCodec(codec))
+
+		id, err := s.Create(cart{User: "alice", Items: []string{"apple"}})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		var c cart
+		if err := s.Load(id, &c); err != nil {
+			t.Fatal(err.Error())
+		}
+		if c.User != "alice" || len(c.Items) != 1 {
+			t.Errorf("got %+v, wanted user alice with one item", c)
+		}
+
+		c.Items = append(c.Items, "bread")
+		if err := s.Save(id, c); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		*now = now.Add(59 * time.Minute)
+		var c2 cart
+		if err := s.Load(id, &c2); err != nil {
+			t.Fatal(err.Error())
+		}
+		if len(c2.Items) != 2 {
+			t.Errorf("got %d items, wanted %d", len(c2.Items), 2)
+		}
+
+		// Loading does not extend the session without sliding expiry
+		*now = now.Add(2 * time.Minute)
+		if err := s.Load(id, &c2); err != ErrNotFound {
+			t.Errorf("got error %v, wanted %v", err, ErrNotFound)
+		}
+
+		if err := s.Load("missing", &c2); err != ErrNotFound {
+			t.Errorf("got error %v, wanted %v", err, ErrNotFound)
+		}
+	}
+}
+
+func TestStoreSlidingExpiry(t *testing.T) {
+	s, now := makeStore(t, WithSlidingExpiry())
+
+	id, err := s.Create("data")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var v string
+	for i := 0; i < 3; i++ {
+		*now = now.Add(50 * time.Minute)
+		if err := s.Load(id, &v); err != nil {
+			t.Fatalf("load %d: got error %v, wanted nil", i, err)
+		}
+	}
+	if v != "data" {
+		t.Errorf("got %q, wanted %q", v, "data")
+	}
+
+	*now = now.Add(61 * time.Minute)
+	if err := s.Touch(id); err != ErrNotFound {
+		t.Errorf("got error %v, wanted %v", err, ErrNotFound)
+	}
+}
+
+func TestStoreCleanup(t *testing.T) {
+	s, now := makeStore(t)
+
+	old, _ := s.Create("old")
+	*now = now.Add(30 * time.Minute)
+	recent, _ := s.Create("recent")
+	s.t.Put("other", []byte("value"))
+	*now = now.Add(45 * time.Minute)
+
+	n, err := s.Cleanup()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if n != 1 {
+		t.Errorf("got %d removed, wanted %d", n, 1)
+	}
+	if _, found := s.t.Get(DefaultKeyPrefix + old); found {
+		t.Errorf("got expired session, wanted it removed")
+	}
+	if _, found := s.t.Get(DefaultKeyPrefix + recent); !found {
+		t.Errorf("got recent session removed, wanted it kept")
+	}
+	if _, found := s.t.Get("other"); !found {
+		t.Errorf("got other key removed, wanted it kept")
+	}
+}
+
+func TestStoreExpiring(t *testing.T) {
+	s, now := makeStore(t)
+
+	old, _ := s.Create("old")
+	*now = now.Add(30 * time.Minute)
+	soon, _ := s.Create("soon")
+	*now = now.Add(10 * time.Minute)
+	s.Create("recent")
+	*now = now.Add(25 * time.Minute)
+
+	// old has expired, soon expires in 25 minutes and recent in 35
+	var got []string
+	for id := range s.Expiring(30 * time.Minute) {
+		got = append(got, id)
+	}
+	if len(got) != 1 || got[0] != soon {
+		t.Errorf("got %q, wanted %q", got, []string{soon})
+	}
+	for id := range s.Expiring(time.Hour) {
+		if id == old {
+			t.Errorf("got expired session, wanted it excluded")
+		}
+	}
+}
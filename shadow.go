@@ -0,0 +1,143 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// shadowMtx serialises calls to Shadow so that concurrent calls cannot form
+// a cycle of shadows.
+var shadowMtx sync.Mutex
+
+// Shadow mirrors every later change to the table into other, such as a table
+// using a newer file format or different options, so that a migration to it
+// can be verified before it is relied upon. Each change is made to other
+// while the table is locked, once it has been committed to the table's data
+// file according to the table's sync policy, so a change that fails is not
+// mirrored. Changes to counters, sets and lists are mirrored by storing
+// their new values. A failure to write to other is logged rather than
+// returned, and shows up as a divergence reported by Divergence. Changes
+// made by SwapFile and Swap are not mirrored.
+//
+// Shadow does not copy the table's existing contents, which may be copied
+// beforehand using WriteTo and ReadFrom. The table must be the only writer
+// of other, and neither other nor any table it shadows in turn may shadow
+// the table. Passing nil stops mirroring.
+func (t *Table) Shadow(other *Table) error {
+	if other == t {
+		return errors.New("lash: table cannot shadow itself")
+	}
+	shadowMtx.Lock()
+	defer shadowMtx.Unlock()
+
+	// Changes to a table lock its shadow while the table is locked, so the
+	// shadows are inspected without holding the table's lock
+	for s := other; s != nil; {
+		s.mtx.RLock()
+		next := s.shadow
+		s.mtx.RUnlock()
+		if next == t {
+			return errors.New("lash: shadow table already shadows the table")
+		}
+		s = next
+	}
+	t.mtx.Lock()
+	t.shadow = other
+	t.mtx.Unlock()
+	return nil
+}
+
+// Divergence returns the keys of the items whose values differ between the
+// table and the table passed to Shadow, including those missing from either,
+// in sorted order. Soft deleted items are treated as missing. It returns an
+// error if the table is not being shadowed.
+func (t *Table) Divergence() ([]string, error) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	other := t.shadow
+	if other == nil {
+		return nil, errors.New("lash: table is not shadowed")
+	}
+	// Writes to the shadow are made while the table is locked, so it
+	// cannot change while it is compared
+	other.mtx.RLock()
+	defer other.mtx.RUnlock()
+
+	var keys []string
+	for k, p := range t.data {
+		if p.deleted != 0 {
+			continue
+		}
+		q, found := other.data[k]
+		if !found || q.deleted != 0 {
+			keys = append(keys, k)
+			continue
+		}
+		v, err := t.value(p)
+		if err != nil {
+			return nil, err
+		}
+		w, err := other.value(q)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(v, w) {
+			keys = append(keys, k)
+		}
+	}
+	for k, q := range other.data {
+		if q.deleted != 0 {
+			continue
+		}
+		if p, found := t.data[k]; !found || p.deleted != 0 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// mirror makes the change described by rec, which has been written to the
+// table's data file, to the table's shadow, if it has one. Delta records are
+// mirrored by mirrorValue instead since they do not hold the new value.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) mirror(rec record) {
+	if t.shadow == nil {
+		return
+	}
+	var err error
+	switch rec.kind {
+	case kindPut:
+		err = t.shadow.Put(rec.key, rec.val)
+	case kindDelete:
+		err = t.shadow.Delete(rec.key)
+	case kindSoftDelete:
+		err = t.shadow.SoftDelete(rec.key)
+	case kindUndelete:
+		err = t.shadow.Undelete(rec.key)
+	case kindMeta:
+		err = t.shadow.SetMetadata(rec.key, string(rec.val))
+	}
+	if err != nil {
+		t.logger.Error("lash: failed to write to shadow table", "key", t.redactKey(rec.key), "error", err)
+	}
+}
+
+// mirrorValue stores the value v under key k in the table's shadow, if it
+// has one.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) mirrorValue(k string, v []byte) {
+	if t.shadow == nil {
+		return
+	}
+	if err := t.shadow.Put(k, v); err != nil {
+		t.logger.Error("lash: failed to write to shadow table", "key", t.redactKey(k), "error", err)
+	}
+}
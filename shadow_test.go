@@ -0,0 +1,234 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestShadow(t *testing.T) {
+	dir := t.TempDir()
+	table, err := New(filepath.Join(dir, "old"), 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+	shadow, err := New(filepath.Join(dir, "new"), 0, WithCompression())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer shadow.Close()
+
+	if err := table.Shadow(shadow); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := shadow.Shadow(table); err == nil {
+		t.Errorf("got no error for a cycle of shadows, wanted one")
+	}
+
+	table.Put("a", []byte("value a"))
+	table.Put("b", []byte("value b"))
+	table.Delete("b")
+	table.Put("c", []byte("value c"))
+	table.SoftDelete("c")
+	table.Counters().Incr("n", 1)
+	table.Counters().Incr("n", 2)
+	table.Set("s").Add("x")
+	table.Set("s").Add("y")
+	table.SetMetadata("version", "2")
+
+	diff, err := table.Divergence()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(diff) != 0 {
+		t.Errorf("got divergent keys %q, wanted none", diff)
+	}
+	if n, _ := shadow.Counters().Get("n"); n != 3 {
+		t.Errorf("got counter %d in shadow, wanted %d", n, 3)
+	}
+	if !shadow.Set("s").Contains("y") {
+		t.Errorf("got set without member in shadow, wanted it mirrored")
+	}
+	if v, _ := shadow.Metadata("version"); v != "2" {
+		t.Errorf("got version %q in shadow, wanted %q", v, "2")
+	}
+	if err := table.Undelete("c"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if v, _ := shadow.Get("c"); string(v) != "value c" {
+		t.Errorf("got %q in shadow, wanted %q", v, "value c")
+	}
+
+	// Writes made directly to the shadow are reported
+	shadow.Put("a", []byte("changed"))
+	shadow.Put("z", []byte("extra"))
+	diff, err = table.Divergence()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if want := []string{"a", "z"}; !reflect.DeepEqual(diff, want) {
+		t.Errorf("got divergent keys %q, wanted %q", diff, want)
+	}
+
+	// Mirroring stops
+	if err := table.Shadow(nil); err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Put("d", []byte("value d"))
+	if _, found := shadow.Get("d"); found {
+		t.Errorf("got value mirrored after shadowing stopped, wanted none")
+	}
+	if _, err := table.Divergence(); err == nil {
+		t.Errorf("got no error from unshadowed table, wanted one")
+	}
+}
+
+func TestShadowBulkLoad(t *testing.T) {
+	dir := t.TempDir()
+	table, err := New(filepath.Join(dir, "old"), 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+	shadow, err := New(filepath.Join(dir, "new"), 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer shadow.Close()
+	if err := table.Shadow(shadow); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := table.BulkLoad(context.Background(), &pairIterator{n: 5000}, 4); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.ImportCSV(strings.NewReader("key,value\nk1,csv\nextra,x\n")); err != nil {
+		t.Fatal(err.Error())
+	}
+	diff, err := table.Divergence()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(diff) != 0 {
+		t.Errorf("got %d divergent keys, wanted none", len(diff))
+	}
+	if v, _ := shadow.Get("k1"); string(v) != "csv" {
+		t.Errorf("got %q in shadow, wanted %q", v, "csv")
+	}
+}
+
+func TestShadowSyncFailure(t *testing.T) {
+	// Writes to /dev/null succeed but it cannot be synced
+	null, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		t.Skip("/dev/null is not available")
+	}
+	defer null.Close()
+	if null.Sync() == nil {
+		t.Skip("/dev/null can be synced")
+	}
+
+	table, err := New(filepath.Join(t.TempDir(), "data"), 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+	shadow, err := New("", 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.Shadow(shadow); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := table.Put("a", []byte("value")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table.mtx.Lock()
+	dbfile := table.dbfile
+	table.dbfile = null
+	table.mtx.Unlock()
+
+	if err := table.Put("b", []byte("value")); err == nil {
+		t.Errorf("got no error, wanted one")
+	}
+	if err := table.Delete("a"); err == nil {
+		t.Errorf("got no error, wanted one")
+	}
+	if err := table.SetMetadata("version", "2"); err == nil {
+		t.Errorf("got no error, wanted one")
+	}
+
+	table.mtx.Lock()
+	table.dbfile = dbfile
+	table.mtx.Unlock()
+
+	// Changes that were not committed are not mirrored
+	if _, found := shadow.Get("b"); found {
+		t.Errorf("got uncommitted put mirrored, wanted it not mirrored")
+	}
+	if _, found := shadow.Get("a"); !found {
+		t.Errorf("got uncommitted delete mirrored, wanted it not mirrored")
+	}
+	if _, found := shadow.Metadata("version"); found {
+		t.Errorf("got uncommitted metadata mirrored, wanted it not mirrored")
+	}
+}
+
+func TestShadowConcurrent(t *testing.T) {
+	var tables [3]*Table
+	for i := range tables {
+		table, err := New("", 0)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		tables[i] = table
+	}
+	a, b, c := tables[0], tables[1], tables[2]
+
+	// A cycle through several tables is refused
+	if err := a.Shadow(b); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := b.Shadow(c); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := c.Shadow(a); err == nil {
+		t.Errorf("got no error for a cycle of shadows, wanted one")
+	}
+	a.Shadow(nil)
+	b.Shadow(nil)
+
+	// Tables shadowing each other concurrently while being written neither
+	// deadlock nor form a cycle
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, pair := range [][2]*Table{{a, b}, {b, a}} {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs[i] = pair[0].Shadow(pair[1])
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				pair[0].Put(fmt.Sprintf("k%d", j), []byte("value"))
+			}
+		}()
+	}
+	wg.Wait()
+	if (errs[0] == nil) == (errs[1] == nil) {
+		t.Errorf("got errors %v and %v, wanted exactly one", errs[0], errs[1])
+	}
+}
@@ -0,0 +1,162 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"io"
+	"sort"
+)
+
+// WriteTo writes a snapshot of the table's items and metadata to w in the
+// format of a compacted data file. Soft deleted items are included, except
+// those whose undelete window has passed. The snapshot may be restored using
+// ReadFrom or opened as a data file using New. It returns the number of
+// bytes written.
+func (t *Table) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	_, _, err := t.writeSnapshot(cw, t.codec, nil, nil)
+	return cw.n, err
+}
+
+// ReadFrom reads a snapshot written by WriteTo, or a data file, from r and
+// stores its items and metadata in the table, replacing any existing values
+// stored under the same keys. Items retain the metadata they had in the
+// snapshot but soft deleted items are not restored. The snapshot must be
+// complete and undamaged; if it is not, or any write fails, then the table is
+// left unchanged. It returns the number of bytes read.
+func (t *Table) ReadFrom(r io.Reader) (int64, error) {
+	snap, n, err := readSnapshot(r)
+	if err != nil {
+		return n, err
+	}
+	return n, t.restore(snap)
+}
+
+// readSnapshot reads a snapshot from r into a new table that does not
+// persist data. It returns the table and the number of bytes read.
+func readSnapshot(r io.Reader) (*Table, int64, error) {
+	d, err := newDecoder(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	snap := &Table{
+		data:     make(map[string]item),
+		meta:     make(map[string]metaItem),
+		checksum: d.checksum,
+		strict:   true,
+	}
+	n, err := snap.loadRecords(d)
+	if err != nil {
+		return nil, n, err
+	}
+	return snap, n, nil
+}
+
+// restore stores the items and metadata of the snapshot snap in the table.
+func (t *Table) restore(snap *Table) error {
+	var err error
+	keys := make([]string, 0, len(snap.data)-snap.trashed)
+	for k, p := range snap.data {
+		if p.deleted == 0 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return snap.data[keys[i]].seq < snap.data[keys[j]].seq })
+	metakeys := make([]string, 0, len(snap.meta))
+	for k := range snap.meta {
+		metakeys = append(metakeys, k)
+	}
+	sort.Strings(metakeys)
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.readonly {
+		return ErrReadOnly
+	}
+
+	start := t.size
+	auditStart, err := t.auditSize()
+	if err != nil {
+		return err
+	}
+	adds := make([]item, len(keys))
+	for i, k := range keys {
+		var v []byte
+		p := snap.data[k]
+		if v, err = snap.value(p); err != nil {
+			break
+		}
+		if err = t.checkPut(k, v); err != nil {
+			break
+		}
+		adds[i] = item{
+			val:     v,
+			created: p.created,
+			updated: p.updated,
+			writes:  p.writes,
+			seq:     t.nextSeq(),
+		}
+		adds[i].pos, adds[i].size, err = t.writeNoSync(adds[i].record(k))
+		if err != nil {
+			break
+		}
+	}
+	metas := make([]metaItem, len(metakeys))
+	for i, k := range metakeys {
+		if err != nil {
+			break
+		}
+		metas[i] = metaItem{val: snap.meta[k].val, seq: t.nextSeq()}
+		rec := metas[i].record(k)
+		metas[i].pos, _, err = t.writeNoSync(rec)
+		metas[i].size = recordSize(rec, t.checksum)
+	}
+	if err == nil {
+		err = t.sync()
+	}
+	if err != nil {
+		if t.dbfile != nil {
+			t.dbfile.Truncate(start)
+			t.size = start
+		}
+		t.truncateAudit(auditStart)
+		return err
+	}
+
+	// The changes are only mirrored once they have been committed
+	for i, k := range keys {
+		old, exists := t.data[k]
+		if err := t.replace(k, adds[i], old, exists); err != nil {
+			return err
+		}
+		t.mirror(adds[i].record(k))
+	}
+	for i, k := range metakeys {
+		old, exists := t.meta[k]
+		t.meta[k] = metas[i]
+		t.mirror(metas[i].record(k))
+		if exists {
+			if err := t.mark(old.pos, old.size); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written to an underlying writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
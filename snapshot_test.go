@@ -0,0 +1,202 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteToReadFrom(t *testing.T) {
+	src, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer src.Close()
+
+	for _, k := range []string{"a", "b", "c"} {
+		err = src.Put(k, []byte("val"+k))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if _, err := src.Counters().Incr("n", 5); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := src.Counters().Incr("n", 2); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := src.SoftDelete("c"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := src.SetMetadata("schema", "2"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	n, err := src.WriteTo(zw)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if n == 0 {
+		t.Errorf("got 0 bytes written, wanted more")
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	dst, err := New("", 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = dst.Put("a", []byte("old"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = dst.Put("d", []byte("vald"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	m, err := dst.ReadFrom(zr)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if m != n {
+		t.Errorf("got %d bytes read, wanted %d", m, n)
+	}
+
+	for _, k := range []string{"a", "b", "d"} {
+		v, found := dst.Get(k)
+		if !found {
+			t.Fatalf("got not found for %q, wanted found", k)
+		}
+		if string(v) != "val"+k {
+			t.Errorf("got %q, wanted %q", v, "val"+k)
+		}
+	}
+	if _, found := dst.Get("c"); found {
+		t.Errorf("got found for soft deleted key, wanted not found")
+	}
+	if c, _ := dst.Counters().Get("n"); c != 7 {
+		t.Errorf("got counter %d, wanted %d", c, 7)
+	}
+	if v, _ := dst.Metadata("schema"); v != "2" {
+		t.Errorf("got metadata %q, wanted %q", v, "2")
+	}
+	srcMeta, _ := src.GetMeta("b")
+	dstMeta, _ := dst.GetMeta("b")
+	if !dstMeta.Created.Equal(srcMeta.Created) {
+		t.Errorf("got created %v, wanted %v", dstMeta.Created, srcMeta.Created)
+	}
+}
+
+func TestReadFromCorrupt(t *testing.T) {
+	src, err := New("", 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, k := range []string{"a", "b"} {
+		err = src.Put(k, []byte("val"))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	var buf bytes.Buffer
+	if _, err := src.WriteTo(&buf); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	dst, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer dst.Close()
+
+	before := dst.Stats().FileBytes
+	_, err = dst.ReadFrom(bytes.NewReader(buf.Bytes()[:buf.Len()-3]))
+	if err == nil {
+		t.Fatalf("got no error, wanted one")
+	}
+	if dst.Len() != 0 {
+		t.Errorf("got %d items, wanted %d", dst.Len(), 0)
+	}
+	if after := dst.Stats().FileBytes; after != before {
+		t.Errorf("got %d file bytes, wanted %d", after, before)
+	}
+}
+
+func TestReadFromRollback(t *testing.T) {
+	src, err := New("", 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := src.Put("a", []byte("val")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := src.Put("b", []byte("too long")); err != nil {
+		t.Fatal(err.Error())
+	}
+	var buf bytes.Buffer
+	if _, err := src.WriteTo(&buf); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	dir := t.TempDir()
+	dst, err := New(filepath.Join(dir, "data"), 50, WithMaxValueSize(4), WithAuditLog(filepath.Join(dir, "audit")))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer dst.Close()
+	shadow, err := New("", 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := dst.Shadow(shadow); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// The value of b is rejected after a has been written
+	if _, err := dst.ReadFrom(bytes.NewReader(buf.Bytes())); err != ErrValueTooLarge {
+		t.Fatalf("got error %v, wanted %v", err, ErrValueTooLarge)
+	}
+	if shadow.Len() != 0 {
+		t.Errorf("got %d items in shadow, wanted %d", shadow.Len(), 0)
+	}
+	entries, err := dst.AuditSince(time.Time{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(entries) != 0 {
+		t.Errorf("got %d audit entries, wanted %d", len(entries), 0)
+	}
+
+	// Later changes are still recorded
+	if err := dst.Put("c", []byte("val")); err != nil {
+		t.Fatal(err.Error())
+	}
+	entries, err = dst.AuditSince(time.Time{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(entries) != 1 || entries[0].Key != "c" {
+		t.Errorf("got audit entries %v, wanted one for %q", entries, "c")
+	}
+	if shadow.Len() != 1 {
+		t.Errorf("got %d items in shadow, wanted %d", shadow.Len(), 1)
+	}
+}
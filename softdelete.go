@@ -0,0 +1,140 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// ErrNotFound is returned when an operation requires a key that is not
+// present in the table.
+var ErrNotFound = errors.New("lash: key not found")
+
+// SoftDelete hides the value stored under key k so that it is no longer
+// returned by Get or counted by Len, but retains it in persistent storage
+// so that it may be recovered by Undelete. Soft deleted items are removed
+// permanently when the data file is compacted after the undelete window
+// has passed (see WithUndeleteWindow). It is not an error to soft delete
+// a key that is not present in the table.
+func (t *Table) SoftDelete(k string) error {
+	t.putLimit.wait()
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.softDelete(k, t.now().UnixNano(), 0)
+}
+
+// softDelete soft deletes the value stored under key k, recording it as
+// deleted at the given time in nanoseconds since the epoch.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) softDelete(k string, at int64, d Durability) error {
+	cur, exists := t.data[k]
+	if !exists || cur.deleted != 0 {
+		return nil
+	}
+
+	cur.deleted = at
+	cur.dseq = t.nextSeq()
+	rec := cur.softDeleteRecord(k)
+	var err error
+	cur.dpos, _, err = t.writeNoSync(rec)
+	if err != nil {
+		return err
+	}
+	if err := t.syncFor(d); err != nil {
+		return err
+	}
+	t.data[k] = cur
+	t.trashed++
+	t.mirror(rec)
+	return nil
+}
+
+// Undelete recovers a value that was previously soft deleted under key k,
+// making it visible to Get once more. It returns ErrNotFound if there is
+// no soft deleted value for k or if the undelete window for the value has
+// passed.
+func (t *Table) Undelete(k string) error {
+	t.putLimit.wait()
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	cur, exists := t.data[k]
+	if !exists || cur.deleted == 0 || t.expired(cur.deleted) {
+		return ErrNotFound
+	}
+
+	rec := record{kind: kindUndelete, key: k, updated: t.now().UnixNano(), seq: t.nextSeq()}
+	if _, err := t.write(rec); err != nil {
+		return err
+	}
+	t.garbage += recordSize(rec, t.checksum)
+
+	err := t.mark(cur.dpos, recordSize(cur.softDeleteRecord(k), t.checksum))
+	if err != nil {
+		return err
+	}
+	cur.deleted = 0
+	cur.dpos = 0
+	cur.dseq = 0
+	t.data[k] = cur
+	t.trashed--
+	return nil
+}
+
+// loadUndelete applies an undelete record, occupying size bytes in the data
+// file, while the table is being initialised. The record itself is garbage.
+// The soft delete record it reverses is normally already marked as deleted,
+// but may not have been if the undelete was interrupted.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) loadUndelete(rec record, size int64) {
+	t.garbage += size
+	cur, exists := t.data[rec.key]
+	if !exists || cur.deleted == 0 || cur.dseq > rec.seq {
+		return
+	}
+	t.garbage += recordSize(cur.softDeleteRecord(rec.key), t.checksum)
+	cur.deleted = 0
+	cur.dpos = 0
+	cur.dseq = 0
+	t.data[rec.key] = cur
+	t.trashed--
+}
+
+// softDeleteRecord returns the record that marks the item stored under
+// key k as soft deleted.
+func (p item) softDeleteRecord(k string) record {
+	return record{kind: kindSoftDelete, key: k, val: binary.AppendVarint(nil, p.deleted), seq: p.dseq}
+}
+
+// expired reports whether an item soft deleted at the given time can no
+// longer be recovered.
+func (t *Table) expired(deleted int64) bool {
+	return t.now().UnixNano()-deleted > int64(t.window)
+}
+
+// loadSoftDelete applies a soft delete record read from the data file at
+// the given position while the table is being initialised.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) loadSoftDelete(rec record, pos int64) error {
+	cur, exists := t.data[rec.key]
+	if !exists || cur.deleted != 0 {
+		t.garbage += recordSize(rec, t.checksum)
+		return nil
+	}
+
+	deleted, n := binary.Varint(rec.val)
+	if n <= 0 {
+		return ErrCorrupt
+	}
+
+	cur.deleted = deleted
+	cur.dpos = pos
+	cur.dseq = rec.seq
+	t.data[rec.key] = cur
+	t.trashed++
+	return nil
+}
@@ -0,0 +1,161 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// withClock returns an option that replaces the table's source of time
+func withClock(now func() time.Time) Option {
+	return func(t *Table) {
+		t.now = now
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	err = table.Put("a", []byte("val"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err = table.SoftDelete("a")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	_, found := table.Get("a")
+	if found {
+		t.Errorf("got found, wanted not found")
+	}
+	if table.Len() != 0 {
+		t.Errorf("got len %d, wanted %d", table.Len(), 0)
+	}
+
+	err = table.Undelete("a")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	v, found := table.Get("a")
+	if !found {
+		t.Fatalf("got not found, wanted found")
+	}
+	if string(v) != "val" {
+		t.Errorf("got %q, wanted %q", v, "val")
+	}
+	if table.Len() != 1 {
+		t.Errorf("got len %d, wanted %d", table.Len(), 1)
+	}
+
+	err = table.Undelete("a")
+	if err != ErrNotFound {
+		t.Errorf("got error %v, wanted %v", err, ErrNotFound)
+	}
+}
+
+func TestSoftDeletePut(t *testing.T) {
+	table, err := New("", 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+
+	err = table.Put("a", []byte("val"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = table.SoftDelete("a")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = table.Put("a", []byte("val2"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	v, found := table.Get("a")
+	if !found {
+		t.Fatalf("got not found, wanted found")
+	}
+	if string(v) != "val2" {
+		t.Errorf("got %q, wanted %q", v, "val2")
+	}
+	if table.Len() != 1 {
+		t.Errorf("got len %d, wanted %d", table.Len(), 1)
+	}
+
+	err = table.Undelete("a")
+	if err != ErrNotFound {
+		t.Errorf("got error %v, wanted %v", err, ErrNotFound)
+	}
+}
+
+func TestSoftDeleteRead(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	tf, err := os.CreateTemp("", "lash")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tf.Close()
+	defer os.Remove(tf.Name())
+
+	table, err := New(tf.Name(), 50, WithUndeleteWindow(time.Hour), withClock(clock))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, k := range []string{"a", "b"} {
+		err = table.Put(k, []byte("val"))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		err = table.SoftDelete(k)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		now = now.Add(40 * time.Minute)
+	}
+	table.Close()
+
+	// a was deleted 80 minutes ago and is dropped, b was deleted 40 minutes ago
+	table2, err := New(tf.Name(), 50, WithUndeleteWindow(time.Hour), withClock(clock))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table2.Close()
+
+	if table2.Len() != 0 {
+		t.Errorf("got len %d, wanted %d", table2.Len(), 0)
+	}
+
+	err = table2.Undelete("a")
+	if err != ErrNotFound {
+		t.Errorf("got error %v, wanted %v", err, ErrNotFound)
+	}
+
+	err = table2.Undelete("b")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	v, found := table2.Get("b")
+	if !found {
+		t.Fatalf("got not found, wanted found")
+	}
+	if string(v) != "val" {
+		t.Errorf("got %q, wanted %q", v, "val")
+	}
+}
@@ -0,0 +1,133 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"time"
+)
+
+// maxHistory is the number of compactions retained in a table's history.
+const maxHistory = 16
+
+// Stats holds statistics about a table.
+type Stats struct {
+	// Keys is the number of items in the table, excluding soft deleted items.
+	Keys int
+
+	// SoftDeleted is the number of soft deleted items held by the table.
+	SoftDeleted int
+
+	// FileBytes is the size of the data file in bytes.
+	FileBytes int64
+
+	// GarbageBytes is the number of bytes in the data file occupied by
+	// records that have been deleted or superseded. They are reclaimed
+	// when the data file is compacted.
+	GarbageBytes int64
+
+	// BytesWritten is the number of bytes written to the data file since the
+	// table was opened, including tombstone markers and compactions.
+	BytesWritten int64
+
+	// CompactionBytesWritten is the number of bytes written to the data file
+	// by compactions since the table was opened.
+	CompactionBytesWritten int64
+
+	// LogicalBytesPut is the total size of the keys and values stored in the
+	// table since it was opened.
+	LogicalBytesPut int64
+
+	// Compactions holds details of the most recent compactions of the data
+	// file, oldest first.
+	Compactions []Compaction
+
+	// DiskFull reports whether the table has stopped accepting changes
+	// because the disk became full. See ErrDiskFull.
+	DiskFull bool
+
+	// FsyncTimedOut reports whether the table has stopped accepting changes
+	// because committing its data file to stable storage took longer than
+	// the period set using WithFsyncTimeout. See ErrFsyncTimeout.
+	FsyncTimedOut bool
+
+	// LastScrub is the time at which the scrubber started by WithScrub last
+	// finished reading the whole data file without finding damage, or the
+	// zero time if it has not.
+	LastScrub time.Time
+
+	// ScrubErrors is the number of times the scrubber has found damage in
+	// the data file since the table was opened.
+	ScrubErrors int
+}
+
+// GarbageRatio returns the proportion of the data file that is occupied by
+// garbage, between 0 and 1.
+func (s Stats) GarbageRatio() float64 {
+	if s.FileBytes == 0 {
+		return 0
+	}
+	return float64(s.GarbageBytes) / float64(s.FileBytes)
+}
+
+// WriteAmplification returns the ratio of bytes written to the data file to
+// the logical bytes put, or 0 if nothing has been put.
+func (s Stats) WriteAmplification() float64 {
+	if s.LogicalBytesPut == 0 {
+		return 0
+	}
+	return float64(s.BytesWritten) / float64(s.LogicalBytesPut)
+}
+
+// Compaction describes a single compaction of a table's data file.
+type Compaction struct {
+	// Time is the time the compaction started.
+	Time time.Time
+
+	// Duration is the time taken to complete the compaction.
+	Duration time.Duration
+
+	// BytesBefore is the size of the data file before compaction.
+	BytesBefore int64
+
+	// BytesAfter is the size of the data file after compaction.
+	BytesAfter int64
+}
+
+// Stats returns statistics about the table.
+func (t *Table) Stats() Stats {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	return Stats{
+		Keys:                   len(t.data) - t.trashed,
+		SoftDeleted:            t.trashed,
+		FileBytes:              t.size,
+		GarbageBytes:           t.garbage,
+		BytesWritten:           t.written,
+		CompactionBytesWritten: t.rewritten,
+		LogicalBytesPut:        t.logical,
+		Compactions:            append([]Compaction(nil), t.history...),
+		DiskFull:               t.full,
+		FsyncTimedOut:          t.stalled,
+		LastScrub:              t.scrubbed,
+		ScrubErrors:            t.scrubErrors,
+	}
+}
+
+// compacted records a compaction that started at the given time, when
+// the data file was the given size.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) compacted(start time.Time, before int64) {
+	c := Compaction{
+		Time:        start,
+		Duration:    t.now().Sub(start),
+		BytesBefore: before,
+		BytesAfter:  t.size,
+	}
+	if len(t.history) == maxHistory {
+		t.history = append(t.history[:0], t.history[1:]...)
+	}
+	t.history = append(t.history, c)
+}
@@ -0,0 +1,100 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	err = table.Put("a", []byte("val"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	s := table.Stats()
+	if s.GarbageBytes != 0 {
+		t.Errorf("got garbage bytes %d, wanted %d", s.GarbageBytes, 0)
+	}
+	before := s.FileBytes
+
+	err = table.Put("a", []byte("val2"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	s = table.Stats()
+	if s.Keys != 1 {
+		t.Errorf("got keys %d, wanted %d", s.Keys, 1)
+	}
+	if s.GarbageBytes == 0 {
+		t.Errorf("got no garbage bytes, wanted some")
+	}
+
+	fi, err := os.Stat(tf.Name())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.FileBytes != fi.Size() {
+		t.Errorf("got file bytes %d, wanted %d", s.FileBytes, fi.Size())
+	}
+	if s.FileBytes-s.GarbageBytes != before+1 {
+		t.Errorf("got live bytes %d, wanted %d", s.FileBytes-s.GarbageBytes, before+1)
+	}
+	if len(s.Compactions) != 1 {
+		t.Errorf("got %d compactions, wanted %d", len(s.Compactions), 1)
+	}
+}
+
+func TestWriteAmplification(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	s := table.Stats()
+	if s.WriteAmplification() != 0 {
+		t.Errorf("got write amplification %v, wanted %v", s.WriteAmplification(), 0)
+	}
+	opened := s.BytesWritten
+	if s.CompactionBytesWritten != opened {
+		t.Errorf("got compaction bytes written %d, wanted %d", s.CompactionBytesWritten, opened)
+	}
+
+	table.Put("a", []byte("val"))
+	table.Put("a", []byte("val2"))
+	s = table.Stats()
+	if s.LogicalBytesPut != 9 {
+		t.Errorf("got logical bytes put %d, wanted %d", s.LogicalBytesPut, 9)
+	}
+	// Two records plus a tombstone marker for the first
+	if want := s.FileBytes - opened + 1; s.BytesWritten-opened != want {
+		t.Errorf("got bytes written %d, wanted %d", s.BytesWritten-opened, want)
+	}
+
+	before := s.BytesWritten
+	if err := table.Compact(); err != nil {
+		t.Fatal(err.Error())
+	}
+	s = table.Stats()
+	if s.BytesWritten != before+s.FileBytes {
+		t.Errorf("got bytes written %d, wanted %d", s.BytesWritten, before+s.FileBytes)
+	}
+	if s.CompactionBytesWritten != opened+s.FileBytes {
+		t.Errorf("got compaction bytes written %d, wanted %d", s.CompactionBytesWritten, opened+s.FileBytes)
+	}
+	if s.WriteAmplification() <= 1 {
+		t.Errorf("got write amplification %v, wanted more than 1", s.WriteAmplification())
+	}
+}
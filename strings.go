@@ -0,0 +1,57 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+// PutString stores the string value v under key k in the table. It is
+// equivalent to calling Put with v converted to a byte slice.
+func (t *Table) PutString(k string, v string) error {
+	return t.Put(k, []byte(v))
+}
+
+// GetString retrieves the value stored under key k as a string and returns
+// it along with a boolean that indicates whether the value was found in the
+// table or not.
+func (t *Table) GetString(k string) (string, bool) {
+	v, found := t.Get(k)
+	if !found {
+		return "", false
+	}
+	return string(v), true
+}
+
+// Strings returns a view of the table that stores and retrieves values as
+// strings. The view shares its data with the table.
+func (t *Table) Strings() Strings {
+	return Strings{t: t}
+}
+
+// Strings is a view of a Table that handles the conversion of values to and
+// from strings. It is intended for tables that hold UTF-8 text.
+type Strings struct {
+	t *Table
+}
+
+// Put stores the string value v under key k in the underlying table.
+func (s Strings) Put(k string, v string) error {
+	return s.t.PutString(k, v)
+}
+
+// Get retrieves the value stored under key k as a string and returns it
+// along with a boolean that indicates whether the value was found in the
+// table or not.
+func (s Strings) Get(k string) (string, bool) {
+	return s.t.GetString(k)
+}
+
+// Len returns the number of items in the underlying table.
+func (s Strings) Len() int {
+	return s.t.Len()
+}
+
+// Table returns the table underlying the view.
+func (s Strings) Table() *Table {
+	return s.t
+}
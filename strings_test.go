@@ -0,0 +1,69 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPutString(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	err = table.PutString("a", "val")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	v, found := table.GetString("a")
+	if !found {
+		t.Fatalf("got not found, wanted found")
+	}
+	if v != "val" {
+		t.Errorf("got %q, wanted %q", v, "val")
+	}
+
+	_, found = table.GetString("b")
+	if found {
+		t.Errorf("got found, wanted not found")
+	}
+}
+
+func TestStrings(t *testing.T) {
+	table, err := New("", 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+
+	s := table.Strings()
+	err = s.Put("a", "val")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	v, found := s.Get("a")
+	if !found {
+		t.Fatalf("got not found, wanted found")
+	}
+	if v != "val" {
+		t.Errorf("got %q, wanted %q", v, "val")
+	}
+
+	b, _ := table.Get("a")
+	if string(b) != "val" {
+		t.Errorf("got %q from table, wanted %q", b, "val")
+	}
+
+	if s.Len() != 1 {
+		t.Errorf("got len %d, wanted %d", s.Len(), 1)
+	}
+}
@@ -0,0 +1,224 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"errors"
+	"os"
+)
+
+// ErrSameTable is returned by Swap when both arguments refer to the same
+// data file.
+var ErrSameTable = errors.New("lash: tables share a data file")
+
+// SwapFile replaces the entire contents of the table with the contents of
+// the data file named fname, such as a dataset built offline by another
+// process. The file is loaded and validated in full before the table is
+// changed: it must be undamaged and its keys and values must be within the
+// table's size limits. The contents are then written to a new data file for
+// the table which replaces the old one in the same way as Compact. If any
+// step fails then the table is left unchanged. Readers see either the old
+// or the new contents in full. The file fname is not modified and may be
+// removed once SwapFile returns. The swap is recorded by a single entry in
+// the table's audit log rather than one for each key. Queues obtained
+// before the swap must be obtained again using Queue.
+func (t *Table) SwapFile(fname string) error {
+	if t.readonly {
+		return ErrReadOnly
+	}
+
+	fresh := &Table{
+		data:     make(map[string]item),
+		meta:     make(map[string]metaItem),
+		filename: fname,
+		window:   t.window,
+		now:      t.now,
+		readonly: true,
+		strict:   true,
+		logger:   t.logger,
+	}
+	if err := fresh.readonlyLoad(); err != nil {
+		return err
+	}
+	for k, p := range fresh.data {
+		v, err := fresh.value(p)
+		if err != nil {
+			return err
+		}
+		if err := t.checkPut(k, v); err != nil {
+			return err
+		}
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.dbfile == nil {
+		if t.filename == "" {
+			return errors.New("lash: table does not persist data")
+		}
+		return errors.New("database not open")
+	}
+
+	return t.replaceContents(fresh.data, fresh.meta, fresh.trashed, fresh.seq)
+}
+
+// Swap exchanges the entire contents of tables a and b, such as a live table
+// and a freshly built replacement, so that each holds what the other held
+// before. Both tables must be writable and persist their data, and the items
+// in each must be within the other's size limits and pass its validator.
+// The contents of each table are written to a new data file which replaces
+// its old one in the same way as Compact. Values left in the data files by
+// WithMaxLoadBytes are read into memory. Both tables are locked for the
+// duration so readers of either see the old or the new contents in full,
+// never a mixture. If any step fails then both tables are left unchanged.
+// Swap is not atomic across a crash: a crash while the second table is
+// being rewritten may leave both holding the former contents of b. The swap
+// is recorded by a single entry in the audit log of each table. Queues
+// obtained before the swap must be obtained again using Queue.
+func Swap(a, b *Table) error {
+	if a == b {
+		return nil
+	}
+	if a.readonly || b.readonly {
+		return ErrReadOnly
+	}
+	if a.filename == "" || b.filename == "" {
+		return errors.New("lash: table does not persist data")
+	}
+	if a.filename == b.filename {
+		return ErrSameTable
+	}
+
+	// Lock in a consistent order so concurrent swaps cannot deadlock
+	first, second := a, b
+	if first.filename > second.filename {
+		first, second = second, first
+	}
+	first.mtx.Lock()
+	defer first.mtx.Unlock()
+	second.mtx.Lock()
+	defer second.mtx.Unlock()
+	if a.dbfile == nil || b.dbfile == nil {
+		return errors.New("database not open")
+	}
+	// The names may differ but still refer to the same file, such as
+	// through a linked directory. The names are compared rather than the
+	// open files since opening a table replaces its data file, leaving an
+	// earlier table with the same data file holding the replaced one.
+	afi, err := os.Stat(a.filename)
+	if err != nil {
+		return ioError("swap", err)
+	}
+	bfi, err := os.Stat(b.filename)
+	if err != nil {
+		return ioError("swap", err)
+	}
+	if os.SameFile(afi, bfi) {
+		return ErrSameTable
+	}
+
+	adata, ameta, err := a.detach()
+	if err != nil {
+		return err
+	}
+	bdata, bmeta, err := b.detach()
+	if err != nil {
+		return err
+	}
+	for k, p := range adata {
+		if err := b.checkPut(k, p.val); err != nil {
+			return err
+		}
+	}
+	for k, p := range bdata {
+		if err := a.checkPut(k, p.val); err != nil {
+			return err
+		}
+	}
+
+	seq := max(a.seq, b.seq)
+	atrashed, btrashed := a.trashed, b.trashed
+	auditStart, err := a.auditSize()
+	if err != nil {
+		return err
+	}
+	if err := a.replaceContents(bdata, bmeta, btrashed, seq); err != nil {
+		return err
+	}
+	if err := b.replaceContents(adata, ameta, atrashed, seq); err != nil {
+		// Return the original contents to a, which are held in memory,
+		// along with its audit log
+		if rerr := a.replaceContents(adata, ameta, atrashed, seq); rerr != nil {
+			a.logger.Error("failed to restore table after swap", "error", rerr)
+		} else {
+			a.truncateAudit(auditStart)
+		}
+		return err
+	}
+	return nil
+}
+
+// replaceContents replaces the table's contents with the given items and metadata,
+// which must hold their values in memory, and writes them to a new data file
+// for the table. The replacement is recorded by a single entry in the
+// table's audit log. The sequence number never decreases. If this fails then
+// the table is left unchanged.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) replaceContents(data map[string]item, meta map[string]metaItem, trashed int, seq uint64) error {
+	if t.writer != nil {
+		return ErrSingleWriter
+	}
+	auditStart, err := t.auditSize()
+	if err != nil {
+		return err
+	}
+	olddata, oldmeta, oldtrashed, oldseq := t.data, t.meta, t.trashed, t.seq
+	t.data, t.meta, t.trashed = data, meta, trashed
+	if seq > t.seq {
+		t.seq = seq
+	}
+	err = t.auditSwap()
+	if err == nil {
+		err = t.compact()
+	}
+	if err != nil {
+		t.data, t.meta, t.trashed, t.seq = olddata, oldmeta, oldtrashed, oldseq
+		t.truncateAudit(auditStart)
+		return err
+	}
+	t.queues = nil
+	t.sequences = nil
+	t.buildIndexes(nil)
+	return nil
+}
+
+// detach returns copies of the table's items and metadata with any values
+// left in the data file read into memory, so that they no longer refer to it,
+// and any collections encoded, so that they are not shared with the table.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) detach() (map[string]item, map[string]metaItem, error) {
+	data := make(map[string]item, len(t.data))
+	for k, p := range t.data {
+		if p.coll != nil {
+			p.val, p.coll = p.coll.encode(), nil
+		}
+		if p.onDisk {
+			v, err := t.value(p)
+			if err != nil {
+				return nil, nil, err
+			}
+			p.val = v
+			p.onDisk = false
+			p.cold = false
+		}
+		data[k] = p
+	}
+	meta := make(map[string]metaItem, len(t.meta))
+	for k, m := range t.meta {
+		meta[k] = m
+	}
+	return data, meta, nil
+}
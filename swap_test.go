@@ -0,0 +1,316 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSwapFile(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	for _, k := range []string{"a", "b"} {
+		if err := table.Put(k, []byte("old "+k)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	fname := filepath.Join(t.TempDir(), "dataset")
+	built, err := New(fname, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, k := range []string{"b", "c"} {
+		if err := built.Put(k, []byte("new "+k)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	built.Close()
+
+	if err := table.SwapFile(fname); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, ok := table.Get("a"); ok {
+		t.Errorf("got value for %q, wanted none", "a")
+	}
+	for _, k := range []string{"b", "c"} {
+		v, ok := table.Get(k)
+		if !ok || string(v) != "new "+k {
+			t.Errorf("got %q, wanted %q", v, "new "+k)
+		}
+	}
+
+	// The table continues to write to its own data file
+	if err := table.Put("d", []byte("new d")); err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Close()
+	table, err = New(tf.Name(), 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+	if table.Len() != 3 {
+		t.Errorf("got length %d, wanted %d", table.Len(), 3)
+	}
+}
+
+func TestSwapFileCorrupt(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	if err := table.Put("a", []byte("old a")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	fname := filepath.Join(t.TempDir(), "dataset")
+	built, err := New(fname, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := built.Put("b", []byte("new b")); err != nil {
+		t.Fatal(err.Error())
+	}
+	built.Close()
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.WriteFile(fname, data[:len(data)-1], 0o666); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := table.SwapFile(fname); err == nil {
+		t.Fatalf("got no error, wanted one for a damaged file")
+	}
+	if v, ok := table.Get("a"); !ok || string(v) != "old a" {
+		t.Errorf("got %q, wanted %q", v, "old a")
+	}
+	if table.Len() != 1 {
+		t.Errorf("got length %d, wanted %d", table.Len(), 1)
+	}
+}
+
+func TestSwap(t *testing.T) {
+	dir := t.TempDir()
+	live, err := New(filepath.Join(dir, "live"), 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer live.Close()
+	fresh, err := New(filepath.Join(dir, "fresh"), 0, WithMaxLoadBytes(1))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer fresh.Close()
+
+	if err := live.Put("a", []byte("old a")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := live.SetMetadata("version", "1"); err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, k := range []string{"b", "c"} {
+		if err := fresh.Put(k, []byte("new "+k)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := fresh.SetMetadata("version", "2"); err != nil {
+		t.Fatal(err.Error())
+	}
+	// Reopen so the values are left in the data file
+	fresh.Close()
+	fresh, err = New(filepath.Join(dir, "fresh"), 0, WithMaxLoadBytes(1))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer fresh.Close()
+
+	if err := Swap(live, fresh); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	check := func(table *Table, keys []string, prefix, version string) {
+		t.Helper()
+		if table.Len() != len(keys) {
+			t.Errorf("got length %d, wanted %d", table.Len(), len(keys))
+		}
+		for _, k := range keys {
+			v, ok := table.Get(k)
+			if !ok || string(v) != prefix+k {
+				t.Errorf("got %q, wanted %q", v, prefix+k)
+			}
+		}
+		if v, _ := table.Metadata("version"); v != version {
+			t.Errorf("got version %q, wanted %q", v, version)
+		}
+	}
+	check(live, []string{"b", "c"}, "new ", "2")
+	check(fresh, []string{"a"}, "old ", "1")
+
+	// Each table continues to write to its own data file
+	if err := live.Put("d", []byte("new d")); err != nil {
+		t.Fatal(err.Error())
+	}
+	live.Close()
+	fresh.Close()
+	live, err = New(filepath.Join(dir, "live"), 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer live.Close()
+	fresh, err = New(filepath.Join(dir, "fresh"), 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer fresh.Close()
+	check(live, []string{"b", "c", "d"}, "new ", "2")
+	check(fresh, []string{"a"}, "old ", "1")
+}
+
+func TestSwapLimits(t *testing.T) {
+	dir := t.TempDir()
+	live, err := New(filepath.Join(dir, "live"), 0, WithMaxValueSize(4))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer live.Close()
+	fresh, err := New(filepath.Join(dir, "fresh"), 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer fresh.Close()
+
+	if err := live.Put("a", []byte("old")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := fresh.Put("b", []byte("too long")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := Swap(live, fresh); err == nil {
+		t.Fatalf("got no error, wanted one for a value over the size limit")
+	}
+	if v, _ := live.Get("a"); string(v) != "old" {
+		t.Errorf("got %q, wanted %q", v, "old")
+	}
+	if v, _ := fresh.Get("b"); string(v) != "too long" {
+		t.Errorf("got %q, wanted %q", v, "too long")
+	}
+
+	if err := Swap(live, live); err != nil {
+		t.Errorf("got error %v, wanted nil", err)
+	}
+}
+
+func TestSwapAudit(t *testing.T) {
+	dir := t.TempDir()
+	live, err := New(filepath.Join(dir, "live"), 0, WithAuditLog(filepath.Join(dir, "live.audit")))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer live.Close()
+	fresh, err := New(filepath.Join(dir, "fresh"), 0, WithAuditLog(filepath.Join(dir, "fresh.audit")))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer fresh.Close()
+
+	for _, k := range []string{"a", "b"} {
+		if err := live.Put(k, []byte("old "+k)); err != nil {
+			t.Fatal(err.Error())
+		}
+		if err := fresh.Put(k, []byte("new "+k)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := Swap(live, fresh); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := live.SwapFile(filepath.Join(dir, "fresh")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	check := func(table *Table, puts, swaps int) {
+		t.Helper()
+		entries, err := table.AuditSince(time.Time{})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if len(entries) != puts+swaps {
+			t.Fatalf("got %d audit entries, wanted %d", len(entries), puts+swaps)
+		}
+		for _, e := range entries[puts:] {
+			if e.Op != "swap" || e.Key != "" {
+				t.Errorf("got entry %+v, wanted a swap", e)
+			}
+		}
+	}
+	check(live, 2, 2)
+	check(fresh, 2, 1)
+
+	// A swap that fails once the first table has been changed is not
+	// recorded
+	held, err := New(filepath.Join(dir, "held"), 0, WithAuditLog(filepath.Join(dir, "held.audit")))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer held.Close()
+	if err := held.Put("c", []byte("held c")); err != nil {
+		t.Fatal(err.Error())
+	}
+	w, err := held.SingleWriter()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer w.Release()
+	if err := Swap(live, held); err != ErrSingleWriter {
+		t.Fatalf("got error %v, wanted %v", err, ErrSingleWriter)
+	}
+	if v, _ := live.Get("a"); string(v) != "old a" {
+		t.Errorf("got %q, wanted %q", v, "old a")
+	}
+	check(live, 2, 2)
+	check(held, 1, 0)
+}
+
+func TestSwapSameFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "real"), 0777); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.Symlink(filepath.Join(dir, "real"), filepath.Join(dir, "alias")); err != nil {
+		t.Skip("symbolic links are not supported")
+	}
+	live, err := New(filepath.Join(dir, "real", "live"), 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer live.Close()
+	if err := live.Put("a", []byte("value")); err != nil {
+		t.Fatal(err.Error())
+	}
+	aliased, err := New(filepath.Join(dir, "alias", "live"), 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer aliased.Close()
+
+	if err := Swap(live, aliased); err != ErrSameTable {
+		t.Errorf("got error %v, wanted %v", err, ErrSameTable)
+	}
+}
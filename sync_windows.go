@@ -0,0 +1,12 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+// syncDir is a no-op on Windows where directories cannot be opened for
+// syncing and renames are committed with the file system metadata.
+func syncDir(name string) error {
+	return nil
+}
@@ -0,0 +1,201 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"bytes"
+	"unsafe"
+)
+
+// A MergeFunc resolves a conflict found by SyncWith between the value local,
+// stored under key k in the table being synchronised, and the value remote,
+// stored under the same key in its peer. It returns the value to be stored
+// in both.
+type MergeFunc func(k string, local, remote []byte) []byte
+
+// SyncWith exchanges changes with peer, such as a table on an occasionally
+// connected device and a table on the hub it synchronises with, so that
+// afterwards both hold the same items. An item held by only one of the
+// tables is copied to the other. Where both hold an item with different
+// values the conflict is passed to merge or, if merge is nil, resolved in
+// favour of the value written most recently according to the update times
+// reported by GetMeta; values with equal update times are ordered by their
+// bytes so that every table chooses the same one. Copied values keep their
+// update times so that later synchronisations with other tables resolve
+// conflicts consistently. Soft deletions are exchanged in the same way,
+// taking the time of the deletion as the update time, but items deleted
+// using Delete are not, so an item deleted from only one table is copied
+// back to it.
+//
+// Both tables are locked for the duration. Changes are committed according
+// to each table's sync policy. If an error occurs then some changes may have
+// been made, which a later call to SyncWith will complete.
+func (t *Table) SyncWith(peer *Table, merge MergeFunc) error {
+	if t == peer {
+		return nil
+	}
+	if t.readonly || peer.readonly {
+		return ErrReadOnly
+	}
+	if t.filename != "" && t.filename == peer.filename {
+		return ErrSameTable
+	}
+
+	// Lock in a consistent order so concurrent synchronisations cannot
+	// deadlock
+	first, second := t, peer
+	if first.filename > second.filename || first.filename == second.filename && uintptr(unsafe.Pointer(first)) > uintptr(unsafe.Pointer(second)) {
+		first, second = second, first
+	}
+	first.mtx.Lock()
+	defer first.mtx.Unlock()
+	second.mtx.Lock()
+	defer second.mtx.Unlock()
+
+	keys := make(map[string]struct{}, len(t.data))
+	for k := range t.data {
+		keys[k] = struct{}{}
+	}
+	for k := range peer.data {
+		keys[k] = struct{}{}
+	}
+
+	var err error
+	tseq, pseq := t.seq, peer.seq
+	for k := range keys {
+		if err = syncItem(k, t, peer, merge); err != nil {
+			break
+		}
+	}
+	if serr := t.syncFor(0); serr != nil {
+		if err == nil {
+			err = serr
+		}
+	} else {
+		t.mirrorSynced(keys, tseq)
+	}
+	if serr := peer.syncFor(0); serr != nil {
+		if err == nil {
+			err = serr
+		}
+	} else {
+		peer.mirrorSynced(keys, pseq)
+	}
+	return err
+}
+
+// syncItem makes the item stored under key k the same in tables a and b.
+// It is the responsibility of the caller to acquire locks.
+func syncItem(k string, a, b *Table, merge MergeFunc) error {
+	p, pok := a.data[k]
+	q, qok := b.data[k]
+	if !pok {
+		return copyItem(k, b, q, a)
+	}
+	if !qok {
+		return copyItem(k, a, p, b)
+	}
+
+	if p.deleted != 0 || q.deleted != 0 {
+		if p.deleted != 0 && q.deleted != 0 {
+			return nil
+		}
+		if syncTime(p) > syncTime(q) {
+			return copyItem(k, a, p, b)
+		}
+		return copyItem(k, b, q, a)
+	}
+
+	v, err := a.value(p)
+	if err != nil {
+		return err
+	}
+	w, err := b.value(q)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(v, w) {
+		return nil
+	}
+
+	if merge != nil {
+		m := merge(k, v, w)
+		if !bytes.Equal(m, v) {
+			if err := a.syncPut(k, m, 0); err != nil {
+				return err
+			}
+		}
+		if !bytes.Equal(m, w) {
+			return b.syncPut(k, m, 0)
+		}
+		return nil
+	}
+
+	if p.updated > q.updated || p.updated == q.updated && bytes.Compare(v, w) > 0 {
+		return b.syncPut(k, v, p.updated)
+	}
+	return a.syncPut(k, w, q.updated)
+}
+
+// copyItem copies the item p stored under key k in table src to table dst,
+// soft deleting any item held by dst if p is soft deleted.
+// It is the responsibility of the caller to acquire locks.
+func copyItem(k string, src *Table, p item, dst *Table) error {
+	if p.deleted != 0 {
+		return dst.softDelete(k, p.deleted, Deferred)
+	}
+	v, err := src.value(p)
+	if err != nil {
+		return err
+	}
+	return dst.syncPut(k, v, p.updated)
+}
+
+// syncTime returns the time at which the item p was last changed, in
+// nanoseconds since the epoch.
+func syncTime(p item) int64 {
+	if p.deleted != 0 {
+		return p.deleted
+	}
+	return p.updated
+}
+
+// mirrorSynced makes the changes stored by syncPut under any of keys since
+// the write with sequence number seq to the table's shadow, once they have
+// been committed.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) mirrorSynced(keys map[string]struct{}, seq uint64) {
+	if t.shadow == nil {
+		return
+	}
+	for k := range keys {
+		if p, exists := t.data[k]; exists && p.seq > seq && p.deleted == 0 {
+			t.mirror(p.record(k))
+		}
+	}
+}
+
+// syncPut stores the value v under key k without committing it to stable
+// storage or mirroring it to the table's shadow, recording it as updated at
+// the given time in nanoseconds since the epoch or now if it is zero.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) syncPut(k string, v []byte, updated int64) error {
+	if err := t.checkPut(k, v); err != nil {
+		return err
+	}
+	old, exists := t.data[k]
+	add := t.newItem(v, old, exists)
+	if updated != 0 {
+		add.updated = updated
+	}
+
+	var err error
+	add.pos, add.size, err = t.writeNoSync(add.record(k))
+	if err != nil {
+		return err
+	}
+	return t.replace(k, add, old, exists)
+}
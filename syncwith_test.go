@@ -0,0 +1,101 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSyncWith(t *testing.T) {
+	now := time.Unix(1000, 0)
+	clock := withClock(func() time.Time { return now })
+
+	device, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer device.Close()
+	clock(device)
+	hub, err := New("", 50, clock)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer hub.Close()
+
+	puts := []struct {
+		table *Table
+		k, v  string
+	}{
+		{device, "a", "device a"},
+		{hub, "b", "hub b"},
+		{hub, "c", "hub c"},
+		{device, "c", "device c"},
+		{device, "d", "device d"},
+		{hub, "d", "hub d"},
+		{device, "e", "device e"},
+		{hub, "e", "hub e"},
+	}
+	for _, p := range puts {
+		now = now.Add(time.Second)
+		if err := p.table.Put(p.k, []byte(p.v)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	now = now.Add(time.Second)
+	if err := device.SoftDelete("e"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	now = now.Add(time.Second)
+	if err := device.SyncWith(hub, nil); err != nil {
+		t.Fatal(err.Error())
+	}
+	want := map[string]string{"a": "device a", "b": "hub b", "c": "device c", "d": "hub d"}
+	for _, table := range []*Table{device, hub} {
+		if table.Len() != len(want) {
+			t.Errorf("got length %d, wanted %d", table.Len(), len(want))
+		}
+		for k, v := range want {
+			if got, _ := table.Get(k); string(got) != v {
+				t.Errorf("got %q for %q, wanted %q", got, k, v)
+			}
+		}
+		if _, ok := table.Get("e"); ok {
+			t.Errorf("got value for soft deleted key %q, wanted none", "e")
+		}
+	}
+	if m, _ := hub.GetMeta("a"); !m.Updated.Equal(time.Unix(1001, 0)) {
+		t.Errorf("got update time %v for copied value, wanted %v", m.Updated, time.Unix(1001, 0))
+	}
+
+	// A merge function resolves conflicts instead of the update times
+	now = now.Add(time.Second)
+	if err := device.Put("a", []byte("x")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := hub.Put("a", []byte("y")); err != nil {
+		t.Fatal(err.Error())
+	}
+	merge := func(k string, local, remote []byte) []byte {
+		return bytes.Join([][]byte{local, remote}, []byte("+"))
+	}
+	if err := device.SyncWith(hub, merge); err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, table := range []*Table{device, hub} {
+		if got, _ := table.Get("a"); string(got) != "x+y" {
+			t.Errorf("got %q, wanted %q", got, "x+y")
+		}
+	}
+
+	if err := device.SyncWith(device, nil); err != nil {
+		t.Errorf("got error %v syncing with itself, wanted none", err)
+	}
+}
@@ -7,13 +7,14 @@
 package lash
 
 import (
-	"bufio"
-	"bytes"
-	"encoding/binary"
 	"errors"
 	"io"
+	"log/slog"
 	"os"
 	"sync"
+	"time"
+
+	"github.com/iand/lash/format"
 )
 
 // New creates a new Table backed by the file fname and with an initial capacity
@@ -21,19 +22,120 @@ import (
 // and will operate purely in memory as though it were a less performant, but
 // concurrent version of the Go map type. If the file fname already exists then
 // it will be read to initialise the data for the table, compacting the file
-// in the process by rewriting it to remove tombstones.
-func New(fname string, n int) (*Table, error) {
+// in the process by rewriting it to remove tombstones. The behaviour of the
+// table may be customised by passing one or more options.
+func New(fname string, n int, opts ...Option) (*Table, error) {
 	t := &Table{
 		data:     make(map[string]item, n),
+		meta:     make(map[string]metaItem),
 		filename: fname,
+		window:   defaultUndeleteWindow,
+		checksum: defaultChecksum,
+		logger:   slog.Default(),
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.watch != nil && !t.readonly {
+		return nil, errors.New("lash: WithWatch requires WithReadOnly")
+	}
+	if t.maxLoad > 0 && t.readonly {
+		return nil, errors.New("lash: WithMaxLoadBytes cannot be used with WithReadOnly")
+	}
+	if t.auditName != "" && t.readonly {
+		return nil, errors.New("lash: WithAuditLog cannot be used with WithReadOnly")
+	}
+	if t.coldAfter > 0 && t.readonly {
+		return nil, errors.New("lash: WithColdStorage cannot be used with WithReadOnly")
+	}
+	if t.coldAfter > 0 && t.accessRes <= 0 {
+		t.accessRes = defaultAccessResolution
 	}
+	t.opened = t.now()
 
-	return t, t.read()
+	err := t.read()
+	if err != nil {
+		return t, err
+	}
+	if t.auditName != "" {
+		if err := t.openAudit(); err != nil {
+			t.Close()
+			return t, err
+		}
+	}
+	if !t.readonly {
+		if err := t.writeReserve(); err != nil {
+			t.Close()
+			return t, err
+		}
+	}
+	if t.watch != nil {
+		err = t.startWatch()
+	}
+	if t.policy == SyncInterval && !t.readonly && t.filename != "" {
+		t.startFlusher()
+	}
+	if t.scrub != nil && !t.readonly && t.filename != "" {
+		t.startScrubber()
+	}
+	return t, err
 }
 
 type item struct {
 	val []byte
 	pos int64
+
+	// created and updated are times in nanoseconds since the epoch, zero
+	// if unknown. writes is the number of times the key has been written.
+	created int64
+	updated int64
+	writes  uint64
+
+	// seq is the sequence number of the write that stored the value.
+	seq uint64
+
+	// size is the number of bytes occupied by the record at pos, or zero if
+	// the value has since been changed by a delta or op record or the table
+	// does not persist data.
+	size int64
+
+	// onDisk reports whether the value was left in the data file by
+	// WithMaxLoadBytes or is held in the cold file, in which case val is nil
+	// and the value is read from the record at pos.
+	onDisk bool
+
+	// deleted is the time at which the item was soft deleted, in nanoseconds
+	// since the epoch, or zero if the item is live. dpos is the file offset
+	// of the record marking the soft deletion.
+	deleted int64
+	dpos    int64
+	dseq    uint64
+
+	// cold reports whether the record at pos is in the cold file rather
+	// than the data file. Cold items are always onDisk.
+	cold bool
+
+	// coll holds the value in decoded form, in which case val is nil, once
+	// a set, list or sorted set has been modified by an op record.
+	coll *collection
+}
+
+// record returns the record that persists the item under key k.
+func (p item) record(k string) record {
+	v := p.val
+	if p.coll != nil {
+		v = p.coll.encode()
+	}
+	return record{
+		kind:    kindPut,
+		key:     k,
+		val:     v,
+		created: p.created,
+		updated: p.updated,
+		writes:  p.writes,
+		seq:     p.seq,
+	}
 }
 
 // Table is a persistent, concurrent, memory-resident key/value hashtable.
@@ -42,68 +144,203 @@ type item struct {
 // key and value are appended to the underlying data file before being inserted
 // into the memory hashtable. Data to be deleted from the table is marked
 // with a tombstone in the data file. Tombstones are evicted when restoring
-// the table from the data file during initialisation. This simple log-based
-// approach performs well but will lead to very large data files for long-lived
-// tables with high volumes of writes. Currently the only method of compacting
-// the data file is to close the table and instantiate a new one pointing at the
-// same file.
+// the table from the data file during initialisation or when the table is
+// compacted using Compact. This simple log-based approach performs well but
+// will lead to very large data files for long-lived tables with high volumes
+// of writes unless they are compacted periodically.
 type Table struct {
-	mtx      sync.RWMutex
-	data     map[string]item
-	filename string
-	dbfile   *os.File
+	mtx         sync.RWMutex
+	data        map[string]item
+	filename    string
+	dbfile      *os.File
+	meta        map[string]metaItem
+	trashed     int           // number of soft deleted items in data
+	seq         uint64        // sequence number of the last write
+	size        int64         // size of the data file in bytes
+	garbage     int64         // bytes occupied by deleted records in the data file
+	written     int64         // bytes written to the data file since the table was opened
+	rewritten   int64         // bytes written to the data file by compactions
+	logical     int64         // bytes of keys and values stored by callers
+	history     []Compaction  // recent compactions, oldest first
+	window      time.Duration // period during which soft deleted items may be recovered
+	readonly    bool          // table was opened using WithReadOnly
+	checksum    Checksum      // algorithm used to checksum records in the data file
+	checksumSet bool          // checksum was set using WithChecksum
+	strict      bool          // table was opened using WithStrictOpen
+	compress    bool          // table was opened using WithCompression
+	codec       *format.Codec // compresses values in the data file, nil if they are not compressed
+	maxKey      int           // maximum length of keys, if greater than zero
+	maxValue    int           // maximum length of values, if greater than zero
+	maxLoad     int64         // maximum bytes of values loaded into memory when opened, if greater than zero
+	loaded      int64         // bytes of values loaded into memory when opened
+	bulk        int           // number of BeginBulk calls not yet matched by EndBulk
+	policy      SyncPolicy    // when writes are committed to stable storage
+	interval    time.Duration // period between fsyncs when policy is SyncInterval
+	durable     int64         // offset in the data file up to which writes are on stable storage
+	fileID      uint32        // identifies the data file in offsets returned by BackupSince
+	logger      *slog.Logger
+	indexes     map[string]index // secondary indexes, keyed by identifier
+	search      *searchIndex     // index used by Search, also held in indexes
+	queues      map[string]*Queue
+	sequences   map[string]*sequence       // sequences used by NextSequence, keyed by name
+	keylocks    [keyLockStripes]sync.Mutex // locks shared between keys by LockKey
+	migrateMtx  sync.Mutex                 // serialises calls to Migrate
+
+	pipelineOnce sync.Once
+	pipeline     *pipeline                               // commits writes submitted by PutAsync
+	flusher      *flusher                                // commits writes periodically when policy is SyncInterval
+	recovery     RecoveryReport                          // describes the loading of the data file when the table was opened
+	watch        *watcher                                // watches the data file when opened using WithWatch
+	validator    func(k string, v []byte) error          // checks writes before they are persisted, if set
+	rewrite      func(k string, v []byte) ([]byte, bool) // transforms or drops items during compaction, if set
+	auditName    string                                  // name of the audit log set using WithAuditLog
+	audit        *os.File                                // audit log, nil if the table has none
+	actor        string                                  // actor recorded in the audit log for the write in progress
+	shadow       *Table                                  // table that changes are mirrored to, set using Shadow
+	reserve      int64                                   // bytes of disk space reserved using WithDiskReserve
+	full         bool                                    // a write failed because the disk is full
+	fsyncTimeout time.Duration                           // maximum time to wait for an fsync, set using WithFsyncTimeout
+	stalled      bool                                    // an fsync did not complete within fsyncTimeout
+	scrub        *scrubber                               // verifies the data file when opened using WithScrub
+	scrubbed     time.Time                               // time the last scrub completed without finding damage
+	scrubErrors  int                                     // number of scrubs that found damage
+	writer       *Writer                                 // sole writer of the table, set using SingleWriter
+	writing      bool                                    // writer is making the change in progress
+	bindings     []*Binding                              // structs bound using BindConfig
+	redact       func(k string) string                   // applied to keys in logs and errors, set using WithKeyRedaction
+	coldAfter    time.Duration                           // idle period after which items are moved to the cold file, set using WithColdStorage
+	coldfile     *os.File                                // cold file, nil if there is none
+	coldChecksum Checksum                                // algorithm used to checksum records in the cold file
+	coldCodec    *format.Codec                           // compresses values in the cold file, nil if they are not compressed
+	coldCount    int                                     // number of records in the cold file
+	opened       time.Time                               // time the table was opened
+	accessRes    time.Duration                           // resolution of tracked read times, zero if reads are not tracked
+	touchMtx     sync.RWMutex                            // guards touched
+	touched      map[string]int64                        // times keys were last read by Get
+	putLimit     *limiter                                // limits the rate of writes when opened using WithRateLimit
+	getLimit     *limiter                                // limits the rate of reads when opened using WithRateLimit
+	now          func() time.Time                        // source of the current time
 }
 
-const sep = byte(31)
-const tomb = byte(127)
+const tomb = format.KindTomb
 
-// write serialises the key and item to the table's datafile
+// write serialises a record to the table's datafile
 // It returns the file offset at which the data was written
 // and/or any error that occurred while writing.
-func (t *Table) write(k string, p item) (int64, error) {
-	if t.dbfile == nil {
-		if t.filename == "" {
-			return 0, nil
-		}
-		return 0, errors.New("database not open")
+// The change is made to the table's shadow once it has been committed.
+func (t *Table) write(rec record) (int64, error) {
+	pos, _, err := t.writeNoSync(rec)
+	if err != nil {
+		return pos, err
 	}
 
-	// TODO: sanitize k for separators
-	buf := &bytes.Buffer{}
-	buf.Write([]byte(k))
-	buf.WriteByte(sep)
+	err = t.sync()
+	if err != nil {
+		return pos, err
+	}
 
-	b := []byte(p.val)
-	lbuf := make([]byte, binary.MaxVarintLen64)
-	lbufn := binary.PutVarint(lbuf, int64(len(b)))
-	buf.Write(lbuf[:lbufn])
-	buf.Write(b)
+	t.mirror(rec)
+	return pos, nil
+}
 
-	pos, err := t.dbfile.Seek(0, os.SEEK_END)
+// writeNoSync serialises a record to the table's datafile without waiting
+// for it to be committed to stable storage. It returns the file offset at
+// which the record was written and the number of bytes it occupies, which
+// is zero if the table does not persist data. The change is not made to the
+// table's shadow, which is left to the caller once it has been committed.
+func (t *Table) writeNoSync(rec record) (int64, int64, error) {
+	if t.readonly {
+		return 0, 0, ErrReadOnly
+	}
+	if t.dbfile == nil && t.filename != "" {
+		return 0, 0, errors.New("database not open")
+	}
+	if t.full {
+		return 0, 0, ErrDiskFull
+	}
+	if t.stalled {
+		return 0, 0, ErrFsyncTimeout
+	}
+	if t.writer != nil && !t.writing {
+		return 0, 0, ErrSingleWriter
+	}
+	if err := t.auditRecord(rec); err != nil {
+		return 0, 0, err
+	}
+	if t.dbfile == nil {
+		return 0, 0, nil
+	}
+
+	buf := appendRecord(nil, rec, t.checksum, t.codec)
+
+	pos, err := t.dbfile.Seek(0, io.SeekEnd)
 	if err != nil {
-		return 0, err
+		return 0, 0, ioError("write", err)
 	}
 
 	// TODO: check number of bytes written
-	n, err := buf.WriteTo(t.dbfile)
+	n, err := t.dbfile.Write(buf)
+	t.size = pos + int64(n)
+	t.written += int64(n)
 	if err != nil {
+		if isDiskFull(err) {
+			return 0, 0, t.diskFull(pos)
+		}
 		if n == 0 {
-			return 0, err
+			return 0, 0, t.recordIOError("write", pos, rec.key, err)
 		}
 		// TODO: decide what to do on a partial write error
-		return 0, err
+		return 0, 0, t.recordIOError("write", pos, rec.key, err)
 	}
+	return pos, int64(n), nil
+}
 
-	err = t.dbfile.Sync()
-	if err != nil {
-		return pos, err
+// sync commits the table's datafile to stable storage if required by the
+// table's sync policy.
+func (t *Table) sync() error {
+	if t.dbfile != nil && (t.bulk > 0 || t.policy != SyncAlways) {
+		return nil
 	}
+	return t.fsync()
+}
 
-	return pos, nil
+// fsync commits the table's datafile to stable storage regardless of the
+// table's sync policy.
+func (t *Table) fsync() error {
+	if t.dbfile == nil {
+		if t.filename == "" {
+			return nil
+		}
+		return errors.New("database not open")
+	}
+	// The audit log must record every change that is committed
+	if t.audit != nil {
+		if err := t.syncFile(t.audit.Sync); err != nil {
+			if err == ErrFsyncTimeout {
+				return err
+			}
+			return ioError("sync audit log", err)
+		}
+	}
+	if err := t.syncFile(t.dbfile.Sync); err != nil {
+		if isDiskFull(err) {
+			return t.diskFull(t.size)
+		}
+		if err == ErrFsyncTimeout {
+			return err
+		}
+		return ioError("sync", err)
+	}
+	t.durable = t.size
+	return nil
 }
 
 // mark inserts a tombstone marker in the data file for a deleted item
-func (t *Table) mark(pos int64) error {
+// whose record occupies size bytes.
+func (t *Table) mark(pos int64, size int64) error {
+	if t.readonly {
+		return ErrReadOnly
+	}
 	if t.dbfile == nil {
 		if t.filename == "" {
 			return nil
@@ -112,10 +349,12 @@ func (t *Table) mark(pos int64) error {
 	}
 
 	// TODO: check number of bytes written
-	_, err := t.dbfile.WriteAt([]byte{tomb}, pos)
+	n, err := t.dbfile.WriteAt([]byte{tomb}, pos)
+	t.written += int64(n)
 	if err != nil {
-		return err
+		return t.recordIOError("mark", pos, "", err)
 	}
+	t.garbage += size
 	return nil
 }
 
@@ -125,146 +364,464 @@ func (t *Table) read() error {
 	if t.filename == "" {
 		return nil
 	}
+	if t.readonly {
+		return t.readonlyLoad()
+	}
+
+	recovered, err := recoverFiles(t.filename)
+	t.recovery.InterruptedCompaction = recovered
+	if err != nil {
+		return ioError("recover", err)
+	}
+	// Records in the data file supersede those in the cold file so it is
+	// loaded first
+	if err := t.loadCold(); err != nil {
+		return err
+	}
 
-	_, err := os.Stat(t.filename)
+	f, err := openFile(t.filename, os.O_RDWR, 0)
 	if err != nil {
 		if os.IsNotExist(err) {
-			t.dbfile, err = os.OpenFile(t.filename, os.O_RDWR|os.O_CREATE|os.O_EXCL, os.FileMode(0666))
-			if err != nil {
-				return err
-			}
-			return nil
+			return t.compact()
 		}
+		return ioError("open", err)
+	}
+
+	// Values left in the data file by WithMaxLoadBytes are read from it
+	// while loading
+	t.dbfile = f
+	err = t.load(f)
+	if err != nil {
+		t.dbfile = nil
+		f.Close()
+		t.closeCold()
 		return err
 	}
 
-	err = os.Rename(t.filename, t.filename+".swp")
+	before := t.size
+	err = t.compact()
 	if err != nil {
 		return err
 	}
+	t.recovery.BytesReclaimed = before - t.size
+	return nil
+}
 
-	t.dbfile, err = os.OpenFile(t.filename, os.O_RDWR|os.O_CREATE|os.O_EXCL, os.FileMode(0666))
+// readonlyLoad initialises the table from its data file without modifying
+// the file.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) readonlyLoad() error {
+	f, err := os.Open(t.filename)
 	if err != nil {
+		return ioError("open", err)
+	}
+	defer f.Close()
+	if err := t.loadCold(); err != nil {
 		return err
 	}
 
-	swapFile, err := os.Open(t.filename + ".swp")
+	return t.load(f)
+}
+
+// load reads all the records from the data file f and applies them to the
+// table's in-memory state. Corrupt records at the end of the file, such as
+// those left by a crash part way through a write, are discarded.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) load(f *os.File) error {
+	d, err := newDecoder(f)
+	if err != nil {
+		return ioError("load", err)
+	}
+	if t.readonly || !t.checksumSet && d.version >= 4 {
+		t.checksum = d.checksum
+	}
+	t.codec = d.codec
+
+	end, err := t.loadRecords(d)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
-		}
 		return err
 	}
-	defer os.Remove(swapFile.Name())
-	defer swapFile.Close()
 
-	r := bufio.NewReader(swapFile)
+	fi, err := f.Stat()
+	if err != nil {
+		return ioError("load", err)
+	}
+	if fi.Size() > end {
+		t.recovery.TruncatedBytes = fi.Size() - end
+		t.logger.Warn("lash: discarded corrupt records at end of data file", "file", t.filename, "offset", end, "bytes", fi.Size()-end)
+	}
+	t.size = end
+	t.durable = end
+	t.loadIndexes()
+	return nil
+}
 
+// loadRecords reads all the records from d and applies them to the table's
+// in-memory state, recording the position of each record in the file read
+// by d. It returns the offset of the end of the last valid record.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) loadRecords(d *decoder) (int64, error) {
 	for {
-		key, err := r.ReadString(sep)
+		pos := d.offset()
+		rec, err := d.next()
 		if err != nil {
-			break
+			if err == io.EOF {
+				return pos, nil
+			}
+			if corrupt(err) && !t.strict && atTail(d) {
+				return pos, nil
+			}
+			return pos, t.recordIOError("load", pos, "", err)
 		}
-
-		lb, err := binary.ReadVarint(r)
-		if err != nil {
-			return err
+		size := d.offset() - pos
+		if rec.kind == tomb {
+			t.recovery.TombstonesSkipped++
+		} else {
+			t.recovery.RecordsLoaded++
 		}
-
-		buf := make([]byte, lb)
-		n, err := io.ReadFull(r, buf)
-		if err != nil {
-			return err
+		if rec.seq == 0 {
+			// Written by an earlier release, so number in file order
+			rec.seq = t.seq + 1
 		}
-
-		if key[0] != tomb {
-			t.putnew(key[:len(key)-1], item{val: buf[:n]})
+		if rec.seq > t.seq {
+			t.seq = rec.seq
 		}
-	}
 
-	// TODO: tighten this up, ensure we have read a full key
-	if err != io.EOF {
-		return err
+		switch rec.kind {
+		case kindPut:
+			if old, exists := t.data[rec.key]; exists {
+				// An earlier write was not marked as deleted
+				t.garbage += t.itemSize(rec.key, old)
+				if old.deleted != 0 {
+					t.trashed--
+					t.garbage += recordSize(old.softDeleteRecord(rec.key), t.checksum)
+				}
+			}
+			p := item{
+				val:     rec.val,
+				pos:     pos,
+				size:    size,
+				created: rec.created,
+				updated: rec.updated,
+				writes:  rec.writes,
+				seq:     rec.seq,
+			}
+			if t.lazy(len(rec.val)) {
+				p.val, p.onDisk = nil, true
+			}
+			t.data[rec.key] = p
+		case kindSoftDelete:
+			err = t.loadSoftDelete(rec, pos)
+			if err != nil {
+				return pos, err
+			}
+		case kindDelete:
+			t.loadDelete(rec, size)
+		case kindUndelete:
+			t.loadUndelete(rec, size)
+		case kindDelta:
+			err = t.loadDelta(rec, size)
+			if err != nil {
+				return pos, err
+			}
+		case kindOp:
+			err = t.loadOp(rec, size)
+			if err != nil {
+				return pos, err
+			}
+		case kindMeta:
+			if old, exists := t.meta[rec.key]; exists {
+				t.garbage += old.size
+			}
+			t.meta[rec.key] = metaItem{val: string(rec.val), pos: pos, size: size, seq: rec.seq}
+		default:
+			if t.strict && rec.kind != tomb {
+				return pos, ErrCorrupt
+			}
+			t.garbage += size
+		}
 	}
-
-	return nil
 }
 
 // Close closes the underlying data file (if any) for the table. The
 // table will continue to respond to read-only methods such as Get and
 // Len but will return an error for any mutating methods such as Put.
 func (t *Table) Close() error {
+	if t.watch != nil && t.watch.fsw != nil {
+		t.watch.stop()
+	}
+	t.stopPipeline()
+	t.stopFlusher()
+	t.stopScrubber()
 	t.mtx.Lock()
 	defer t.mtx.Unlock()
+	if t.readonly {
+		return nil
+	}
 	if t.dbfile == nil {
 		if t.filename == "" {
-			return nil
+			return t.closeAudit()
 		}
 		return errors.New("database not open")
 	}
-	return t.dbfile.Close()
+	// Commit any writes that have not been synced, such as those made in
+	// bulk mode, before saving indexes that describe them.
+	t.bulk = 0
+	err := t.fsync()
+	if err == nil {
+		err = t.saveIndexes()
+	}
+	if cerr := t.closeAudit(); err == nil {
+		err = cerr
+	}
+	if cerr := t.dbfile.Close(); err == nil {
+		err = ioError("close", cerr)
+	}
+	t.dbfile = nil
+	if cerr := t.closeCold(); err == nil {
+		err = cerr
+	}
+	return err
 }
 
 // Put stores the value v under key k in the table
 // and writes it to persistent storage. Any error encountered
 // while persisting the data will be returned. If the table fails
 // to persist the data then the table will be restored to the state
-// it had just prior to the call to Put.
-func (t *Table) Put(k string, v []byte) error {
-	add := item{
-		val: v,
+// it had just prior to the call to Put. Whether Put waits for the
+// data to be committed to stable storage is determined by the table's
+// sync policy unless overridden by passing Durable or Deferred.
+func (t *Table) Put(k string, v []byte, d ...Durability) error {
+	var dur Durability
+	if len(d) > 0 {
+		dur = d[len(d)-1]
 	}
+	t.putLimit.wait()
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.put(k, v, dur)
+}
 
+// update replaces the value stored under key k with the value returned by
+// fn, which is passed the current value and a boolean that indicates whether
+// it was found in the table. The table is locked while fn is called so the
+// read, modify and write happen atomically. If fn returns an error then the
+// table is not changed.
+func (t *Table) update(k string, fn func(v []byte, found bool) ([]byte, error)) error {
+	t.putLimit.wait()
 	t.mtx.Lock()
 	defer t.mtx.Unlock()
 
-	old, exists := t.data[k]
-	if !exists {
-		return t.putnew(k, add)
+	cur, found := t.data[k]
+	if cur.deleted != 0 {
+		found = false
 	}
+	var v []byte
+	if found {
+		var err error
+		v, err = t.value(cur)
+		if err != nil {
+			return err
+		}
+	}
+	v, err := fn(v, found)
+	if err != nil {
+		return err
+	}
+	return t.put(k, v, 0)
+}
+
+// put stores the value v under key k in the table, committing it to stable
+// storage as required by d.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) put(k string, v []byte, d Durability) error {
+	if err := t.checkPut(k, v); err != nil {
+		return err
+	}
+	old, exists := t.data[k]
+	add := t.newItem(v, old, exists)
 
+	rec := add.record(k)
 	var err error
-	add.pos, err = t.write(k, add)
+	add.pos, add.size, err = t.writeNoSync(rec)
 	if err != nil {
 		return err
 	}
+	if err := t.syncFor(d); err != nil {
+		return err
+	}
+	if err := t.replace(k, add, old, exists); err != nil {
+		return err
+	}
+	t.mirror(rec)
+	return nil
+}
 
+// newItem returns a new item holding the value v that will replace the item
+// old, if it exists.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) newItem(v []byte, old item, exists bool) item {
+	now := t.now().UnixNano()
+	add := item{
+		val:     v,
+		created: now,
+		updated: now,
+		writes:  1,
+		seq:     t.nextSeq(),
+	}
+	if exists && old.deleted == 0 {
+		add.created = old.created
+		add.writes = old.writes + 1
+	}
+	return add
+}
+
+// replace stores the item add, which has already been written to the data
+// file, under key k and marks the item old, if it exists, as deleted.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) replace(k string, add item, old item, exists bool) error {
 	t.data[k] = add
-	err = t.mark(old.pos)
+	t.logical += int64(len(k) + len(add.val))
+	if exists {
+		t.unindexItem(k, old)
+	}
+	t.indexValue(k, add.val)
+	if !exists {
+		return nil
+	}
+
+	err := t.markItem(k, old)
 	if err != nil {
 		t.data[k] = old
+		t.logical -= int64(len(k) + len(add.val))
+		t.unindexValue(k, add.val)
+		t.indexItem(k, old)
 		return err
 	}
+	if old.deleted != 0 {
+		t.trashed--
+		// A failure to mark the soft delete record would only resurrect the
+		// soft deletion after the next restart, so it is not reported.
+		t.mark(old.dpos, recordSize(old.softDeleteRecord(k), t.checksum))
+	}
 	return nil
 }
 
-// putnew adds a new item to the table without checking
-// whether it is overwriting any existing data.
+// nextSeq returns the sequence number to be used for the next write.
 // It is the responsibility of the caller to acquire locks.
-func (t *Table) putnew(k string, add item) error {
-	var err error
-	add.pos, err = t.write(k, add)
+func (t *Table) nextSeq() uint64 {
+	t.seq++
+	return t.seq
+}
+
+// Delete removes the value stored under key k from the table and marks
+// it as deleted in persistent storage. It is not an error to delete a key
+// that is not present in the table.
+func (t *Table) Delete(k string) error {
+	t.putLimit.wait()
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.delete(k, 0)
+}
+
+// DeleteLater removes the value stored under key k like Delete but returns
+// without waiting for the deletion to be committed to stable storage,
+// whatever the table's sync policy. The deletion is committed along with a
+// later write, by the next periodic fsync if the sync policy is
+// SyncInterval, or when the table is closed, so that many deletions made in
+// quick succession share a single fsync. The value may reappear if the
+// machine crashes before the deletion is committed.
+func (t *Table) DeleteLater(k string) error {
+	t.putLimit.wait()
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.delete(k, Deferred)
+}
+
+// delete removes the value stored under key k, committing the deletion to
+// stable storage according to the durability d.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) delete(k string, d Durability) error {
+	old, exists := t.data[k]
+	if !exists {
+		return nil
+	}
+
+	rec := record{kind: kindDelete, key: k, updated: t.now().UnixNano(), seq: t.nextSeq()}
+	if _, _, err := t.writeNoSync(rec); err != nil {
+		return err
+	}
+	if err := t.syncFor(d); err != nil {
+		return err
+	}
+	t.garbage += recordSize(rec, t.checksum)
+
+	err := t.markItem(k, old)
 	if err != nil {
 		return err
 	}
-	t.data[k] = add
+	delete(t.data, k)
+	t.unindexItem(k, old)
+	if old.deleted != 0 {
+		t.trashed--
+		t.mark(old.dpos, recordSize(old.softDeleteRecord(k), t.checksum))
+	}
+	t.mirror(rec)
 	return nil
 }
 
+// loadDelete applies a delete record, occupying size bytes in the data file,
+// while the table is being initialised. The record itself is garbage. The
+// deleted item's records are normally already marked as deleted, but an
+// earlier write may not have been marked if the delete was interrupted.
+// It is the responsibility of the caller to acquire locks.
+func (t *Table) loadDelete(rec record, size int64) {
+	t.garbage += size
+	cur, exists := t.data[rec.key]
+	if !exists || cur.seq > rec.seq {
+		return
+	}
+	t.garbage += t.itemSize(rec.key, cur)
+	if cur.deleted != 0 {
+		t.trashed--
+		t.garbage += recordSize(cur.softDeleteRecord(rec.key), t.checksum)
+	}
+	delete(t.data, rec.key)
+}
+
 // Get retrieves the value stored under key k and returns it
 // along with a boolean that indicates whether the value was
-// found in the table or not.
+// found in the table or not. A value left in the data file by
+// WithMaxLoadBytes or held in the cold file that cannot be read
+// is logged and reported as not found.
 func (t *Table) Get(k string) ([]byte, bool) {
+	t.getLimit.wait()
 	t.mtx.RLock()
 	cur, found := t.data[k]
+	if !found || cur.deleted != 0 {
+		t.mtx.RUnlock()
+		return nil, false
+	}
+	if !cur.onDisk && cur.coll == nil {
+		t.mtx.RUnlock()
+		t.touch(k)
+		return cur.val, true
+	}
+	v, err := t.value(cur)
 	t.mtx.RUnlock()
-	return cur.val, found
+	if err != nil {
+		t.logger.Error("lash: failed to read value", "key", t.redactKey(k), "error", err)
+		return nil, false
+	}
+	t.touch(k)
+	return v, true
 }
 
-// Len returns the number of items in the table.
+// Len returns the number of items in the table. Soft deleted items
+// are not included.
 func (t *Table) Len() int {
 	t.mtx.RLock()
-	l := len(t.data)
+	l := len(t.data) - t.trashed
 	t.mtx.RUnlock()
 	return l
 }
@@ -125,3 +125,82 @@ func TestRead(t *testing.T) {
 		t.Errorf("got %q, wanted %q", v, "val")
 	}
 }
+
+func TestDelete(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	err = table.Put("a", []byte("val"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = table.Put("b", []byte("val"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = table.Delete("a")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	_, found := table.Get("a")
+	if found {
+		t.Errorf("got found, wanted not found")
+	}
+	table.Close()
+
+	table2, err := New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table2.Close()
+
+	_, found = table2.Get("a")
+	if found {
+		t.Errorf("got found after reload, wanted not found")
+	}
+	if table2.Len() != 1 {
+		t.Errorf("got len %d, wanted %d", table2.Len(), 1)
+	}
+}
+
+func TestLoadUnmarkedDelete(t *testing.T) {
+	tf, err := os.CreateTemp("", "lash")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	// A delete record whose put record was never marked as deleted, followed
+	// by an undelete record for a soft deleted item in the same state
+	data := appendHeader(nil, defaultChecksum, nil)
+	data = appendRecord(data, record{kind: kindPut, key: "a", val: []byte("val"), seq: 1}, defaultChecksum, nil)
+	data = appendRecord(data, record{kind: kindPut, key: "b", val: []byte("val"), seq: 2}, defaultChecksum, nil)
+	data = appendRecord(data, item{deleted: 1, dseq: 3}.softDeleteRecord("b"), defaultChecksum, nil)
+	data = appendRecord(data, record{kind: kindDelete, key: "a", seq: 4}, defaultChecksum, nil)
+	data = appendRecord(data, record{kind: kindUndelete, key: "b", seq: 5}, defaultChecksum, nil)
+	_, err = tf.Write(data)
+	tf.Close()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	table, err := New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table.Close()
+
+	if _, found := table.Get("a"); found {
+		t.Errorf("got found for deleted key, wanted not found")
+	}
+	if _, found := table.Get("b"); !found {
+		t.Errorf("got not found for undeleted key, wanted found")
+	}
+	if table.Len() != 1 {
+		t.Errorf("got len %d, wanted %d", table.Len(), 1)
+	}
+}
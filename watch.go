@@ -0,0 +1,174 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ErrReadOnly is returned by mutating methods of a table opened with
+// WithReadOnly.
+var ErrReadOnly = errors.New("lash: table is read only")
+
+// watchDelay is the period of quiet after a change to a watched data file
+// before the table is reloaded.
+const watchDelay = 100 * time.Millisecond
+
+// WithReadOnly opens the table without modifying its data file. The file
+// is not compacted and all mutating methods such as Put return ErrReadOnly.
+// The data file must already exist. A read only table may share its data
+// file with a table that is open for writing in another process.
+func WithReadOnly() Option {
+	return func(t *Table) {
+		t.readonly = true
+	}
+}
+
+// WithWatch watches the data file of a read only table and reloads the
+// table's contents whenever another process replaces the file, such as
+// when it is rewritten or compacted. Readers see either the old or the new
+// contents in full. Errors encountered while reloading are passed to
+// onError, which may be nil, and leave the existing contents in place.
+// WithWatch must be used with WithReadOnly.
+func WithWatch(onError func(error)) Option {
+	return func(t *Table) {
+		t.watch = &watcher{onError: onError}
+	}
+}
+
+type watcher struct {
+	onError func(error)
+	fsw     *fsnotify.Watcher
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	pending bool // the data file has been replaced since it was last loaded
+	closed  bool
+}
+
+// startWatch begins watching the table's data file for changes.
+func (t *Table) startWatch() error {
+	var err error
+	t.watch.fsw, err = fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// Watch the directory since the file itself is replaced
+	err = t.watch.fsw.Add(filepath.Dir(t.filename))
+	if err != nil {
+		t.watch.fsw.Close()
+		return err
+	}
+
+	go t.watchLoop()
+	return nil
+}
+
+func (t *Table) watchLoop() {
+	w := t.watch
+	name := filepath.Clean(t.filename)
+	for {
+		select {
+		case ev, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(ev.Name) != name {
+				continue
+			}
+			w.mu.Lock()
+			// A new file has been created or renamed into place. Wait until
+			// writes to it have finished before reloading.
+			if ev.Has(fsnotify.Create) {
+				w.pending = true
+			}
+			if w.pending && !w.closed {
+				if w.timer == nil {
+					w.timer = time.AfterFunc(watchDelay, t.reloadPending)
+				} else {
+					w.timer.Reset(watchDelay)
+				}
+			}
+			w.mu.Unlock()
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			w.report(err)
+		}
+	}
+}
+
+// reloadPending reloads the table if its data file has been replaced.
+func (t *Table) reloadPending() {
+	w := t.watch
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed || !w.pending {
+		return
+	}
+
+	err := t.reload()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			w.report(err)
+		}
+		return
+	}
+	w.pending = false
+	t.rebind(w.report)
+}
+
+// reload replaces the contents of the table with the contents of its data file.
+func (t *Table) reload() error {
+	fresh := &Table{
+		data:     make(map[string]item, len(t.data)),
+		meta:     make(map[string]metaItem),
+		filename: t.filename,
+		window:   t.window,
+		now:      t.now,
+		readonly: true,
+		strict:   t.strict,
+		logger:   t.logger,
+	}
+	err := fresh.readonlyLoad()
+	if err != nil {
+		return err
+	}
+
+	t.mtx.Lock()
+	t.data = fresh.data
+	t.meta = fresh.meta
+	t.trashed = fresh.trashed
+	t.size = fresh.size
+	t.garbage = fresh.garbage
+	t.buildIndexes(nil)
+	t.mtx.Unlock()
+	return nil
+}
+
+// stop stops watching the data file.
+func (w *watcher) stop() error {
+	w.mu.Lock()
+	w.closed = true
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.mu.Unlock()
+	return w.fsw.Close()
+}
+
+func (w *watcher) report(err error) {
+	if w.onError != nil {
+		w.onError(err)
+	}
+}
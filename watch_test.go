@@ -0,0 +1,112 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReadOnly(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	err = table.Put("a", []byte("val"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Close()
+
+	before, err := os.Stat(tf.Name())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	ro, err := New(tf.Name(), 50, WithReadOnly())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer ro.Close()
+
+	after, err := os.Stat(tf.Name())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !os.SameFile(before, after) {
+		t.Errorf("data file was replaced by read only open")
+	}
+
+	v, found := ro.Get("a")
+	if !found {
+		t.Fatalf("got not found, wanted found")
+	}
+	if string(v) != "val" {
+		t.Errorf("got %q, wanted %q", v, "val")
+	}
+
+	err = ro.Put("b", []byte("val"))
+	if err != ErrReadOnly {
+		t.Errorf("got error %v, wanted %v", err, ErrReadOnly)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	err = table.Put("a", []byte("val"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Close()
+
+	ro, err := New(tf.Name(), 50, WithReadOnly(), WithWatch(func(err error) { t.Logf("watch error: %v", err) }))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer ro.Close()
+
+	// Reopening the table for writing rewrites the data file
+	table, err = New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = table.Put("b", []byte("val"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Close()
+	table, err = New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	table.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for ro.Len() != 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if ro.Len() != 2 {
+		t.Fatalf("got len %d, wanted %d", ro.Len(), 2)
+	}
+	if _, found := ro.Get("b"); !found {
+		t.Errorf("got not found, wanted found")
+	}
+}
+
+func TestWatchRequiresReadOnly(t *testing.T) {
+	_, err := New("", 50, WithWatch(nil))
+	if err == nil {
+		t.Errorf("got no error, wanted error")
+	}
+}
@@ -0,0 +1,80 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"errors"
+)
+
+// ErrSingleWriter is returned when a change is made to a table other than
+// through the Writer returned by SingleWriter, including by a Writer that
+// has been released, and when SingleWriter is called while a Writer is held.
+var ErrSingleWriter = errors.New("lash: table is reserved for a single writer")
+
+// A Writer is the only means of changing a table while it is held. It
+// allows an application to enforce an ownership model in which a single
+// designated goroutine, such as an actor, makes every change to a table.
+type Writer struct {
+	t *Table
+}
+
+// SingleWriter reserves the table for changes made through the returned
+// Writer until it is released. While it is held every other change to the
+// table, whether made by methods of the table or by its views, fails with
+// ErrSingleWriter. Reads are not affected, nor is compaction. It returns
+// ErrSingleWriter if a Writer is already held.
+func (t *Table) SingleWriter() (*Writer, error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.writer != nil {
+		return nil, ErrSingleWriter
+	}
+	w := &Writer{t: t}
+	t.writer = w
+	return w, nil
+}
+
+// Put stores the value v under key k in the table as described by the Put
+// method of Table.
+func (w *Writer) Put(k string, v []byte, d ...Durability) error {
+	var dur Durability
+	if len(d) > 0 {
+		dur = d[len(d)-1]
+	}
+	return w.do(func() error { return w.t.put(k, v, dur) })
+}
+
+// Delete removes the value stored under key k from the table as described
+// by the Delete method of Table.
+func (w *Writer) Delete(k string) error {
+	return w.do(func() error { return w.t.delete(k, 0) })
+}
+
+// Release ends the reservation of the table so that it may be changed by
+// any caller. Changes made through the Writer after it has been released
+// fail with ErrSingleWriter.
+func (w *Writer) Release() {
+	w.t.mtx.Lock()
+	defer w.t.mtx.Unlock()
+	if w.t.writer == w {
+		w.t.writer = nil
+	}
+}
+
+// do calls fn with the table locked, permitting it to make changes if the
+// writer is held.
+func (w *Writer) do(fn func() error) error {
+	t := w.t
+	t.putLimit.wait()
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.writer != w {
+		return ErrSingleWriter
+	}
+	t.writing = true
+	defer func() { t.writing = false }()
+	return fn()
+}
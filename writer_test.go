@@ -0,0 +1,70 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSingleWriter(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+	defer table.Close()
+
+	if err := table.Put("a", []byte("before")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	w, err := table.SingleWriter()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := table.SingleWriter(); err != ErrSingleWriter {
+		t.Errorf("got error %v, wanted %v", err, ErrSingleWriter)
+	}
+
+	if err := w.Put("b", []byte("writer")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := w.Delete("a"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Other changes are rejected
+	if err := table.Put("c", []byte("other")); err != ErrSingleWriter {
+		t.Errorf("got error %v from Put, wanted %v", err, ErrSingleWriter)
+	}
+	if _, err := table.Counters().Incr("n", 1); err != ErrSingleWriter {
+		t.Errorf("got error %v from Incr, wanted %v", err, ErrSingleWriter)
+	}
+	if err := table.PutAsync("c", []byte("other")).Err(); err != ErrSingleWriter {
+		t.Errorf("got error %v from PutAsync, wanted %v", err, ErrSingleWriter)
+	}
+	if err := table.Delete("b"); err != ErrSingleWriter {
+		t.Errorf("got error %v from Delete, wanted %v", err, ErrSingleWriter)
+	}
+	if v, _ := table.Get("b"); string(v) != "writer" {
+		t.Errorf("got %q, wanted %q", v, "writer")
+	}
+	if table.Len() != 1 {
+		t.Errorf("got len %d, wanted %d", table.Len(), 1)
+	}
+	if err := table.Compact(); err != nil {
+		t.Errorf("got error %v from Compact, wanted nil", err)
+	}
+
+	w.Release()
+	if err := w.Put("d", []byte("released")); err != ErrSingleWriter {
+		t.Errorf("got error %v after release, wanted %v", err, ErrSingleWriter)
+	}
+	if err := table.Put("c", []byte("other")); err != nil {
+		t.Errorf("got error %v after release, wanted nil", err)
+	}
+}
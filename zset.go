@@ -0,0 +1,156 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+)
+
+// ErrInvalidScore is returned when a member is added to a sorted set with a
+// score that is NaN.
+var ErrInvalidScore = errors.New("lash: invalid score")
+
+// ZSet returns a view of the sorted set stored under key k. The sorted set
+// need not exist until a member is added.
+func (t *Table) ZSet(k string) ZSet {
+	return ZSet{t: t, k: k}
+}
+
+// ZSet is a view of a sorted set stored under a key in a Table. Each member
+// of a sorted set has a score and members are ordered by score, with members
+// that have equal scores ordered by name. Adding, rescoring or removing a
+// member appends a small record to the data file rather than rewriting the
+// whole sorted set. Sorted sets are suitable for leaderboards and priority
+// orderings.
+type ZSet struct {
+	t *Table
+	k string
+}
+
+// A ZMember is a member of a sorted set together with its score.
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// Add adds member to the sorted set with the given score, replacing its
+// score if it is already a member. Scores must not be NaN.
+func (z ZSet) Add(member string, score float64) error {
+	if math.IsNaN(score) {
+		return ErrInvalidScore
+	}
+	_, _, err := z.t.modify(z.k, typeZSet, opZAdd, zsetElem(member, score))
+	return err
+}
+
+// Remove removes member from the sorted set and reports whether it was a
+// member.
+func (z ZSet) Remove(member string) (bool, error) {
+	_, changed, err := z.t.modify(z.k, typeZSet, opZRemove, []byte(member))
+	return changed, err
+}
+
+// Score returns the score of member along with a boolean that indicates
+// whether it is a member of the sorted set.
+func (z ZSet) Score(member string) (float64, bool) {
+	var e []byte
+	z.t.view(z.k, typeZSet, func(c *collection) {
+		e = c.members[member]
+	})
+	if e == nil {
+		return 0, false
+	}
+	return zsetScore(e), true
+}
+
+// Rank returns the position of member in the sorted set, ordered by
+// ascending score and counting from zero, along with a boolean that
+// indicates whether it is a member.
+func (z ZSet) Rank(member string) (int, bool) {
+	rank, found := -1, false
+	z.t.view(z.k, typeZSet, func(c *collection) {
+		e, ok := c.members[member]
+		if !ok {
+			return
+		}
+		b, i, _ := c.find(e)
+		rank, found = c.elems.rank(b, i), true
+	})
+	return rank, found
+}
+
+// RangeByScore returns the members whose scores are between min and max
+// inclusive, ordered by ascending score.
+func (z ZSet) RangeByScore(min, max float64) []ZMember {
+	var members []ZMember
+	z.t.view(z.k, typeZSet, func(c *collection) {
+		b, i := c.elems.search(func(e []byte) bool { return zsetScore(e) >= min })
+		c.elems.scan(b, i, func(e []byte) bool {
+			if zsetScore(e) > max {
+				return false
+			}
+			members = append(members, zmember(e))
+			return true
+		})
+	})
+	return members
+}
+
+// Top returns up to n members with the highest scores, ordered by
+// descending score.
+func (z ZSet) Top(n int) []ZMember {
+	var members []ZMember
+	z.t.view(z.k, typeZSet, func(c *collection) {
+		if n <= 0 {
+			return
+		}
+		b, i := c.elems.locate(max(c.elems.len()-n, 0))
+		c.elems.scan(b, i, func(e []byte) bool {
+			members = append(members, zmember(e))
+			return true
+		})
+		slices.Reverse(members)
+	})
+	return members
+}
+
+// Len returns the number of members in the sorted set.
+func (z ZSet) Len() int {
+	var n int
+	z.t.view(z.k, typeZSet, func(c *collection) {
+		n = c.elems.len()
+	})
+	return n
+}
+
+// zsetElem encodes a sorted set element.
+func zsetElem(member string, score float64) []byte {
+	bits := math.Float64bits(score)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	return append(binary.BigEndian.AppendUint64(nil, bits), member...)
+}
+
+// zsetScore decodes the score of a sorted set element.
+func zsetScore(e []byte) float64 {
+	bits := binary.BigEndian.Uint64(e)
+	if bits&(1<<63) != 0 {
+		bits &^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	return math.Float64frombits(bits)
+}
+
+func zmember(e []byte) ZMember {
+	return ZMember{Member: string(e[8:]), Score: zsetScore(e)}
+}
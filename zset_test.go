@@ -0,0 +1,160 @@
+/*
+  This is free and unencumbered software released into the public domain. For more
+  information, see <http://unlicense.org/> or the accompanying UNLICENSE file.
+*/
+
+package lash
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestZSet(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	z := table.ZSet("scores")
+	scores := map[string]float64{"alice": 30, "bob": -5, "carol": 12.5, "dave": 30, "erin": 0}
+	for m, s := range scores {
+		if err := z.Add(m, s); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := z.Add("bob", 40); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := z.Add("frank", math.NaN()); err != ErrInvalidScore {
+		t.Errorf("got error %v, wanted %v", err, ErrInvalidScore)
+	}
+	if removed, _ := z.Remove("erin"); !removed {
+		t.Errorf("got not removed, wanted removed")
+	}
+
+	if z.Len() != 4 {
+		t.Errorf("got len %d, wanted %d", z.Len(), 4)
+	}
+	if s, _ := z.Score("bob"); s != 40 {
+		t.Errorf("got score %v, wanted %v", s, 40)
+	}
+	if r, _ := z.Rank("carol"); r != 0 {
+		t.Errorf("got rank %d, wanted %d", r, 0)
+	}
+	if _, ok := z.Rank("erin"); ok {
+		t.Errorf("got rank for removed member, wanted none")
+	}
+	want := []ZMember{{"alice", 30}, {"dave", 30}}
+	if got := z.RangeByScore(20, 30); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, wanted %v", got, want)
+	}
+	table.Close()
+
+	table2, err := New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table2.Close()
+	want = []ZMember{{"bob", 40}, {"dave", 30}, {"alice", 30}}
+	if got := table2.ZSet("scores").Top(3); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v after reopen, wanted %v", got, want)
+	}
+}
+
+func TestZSetScoreEncoding(t *testing.T) {
+	scores := []float64{math.Inf(-1), -1e10, -1, -0.5, 0, 0.5, 1, 1e10, math.Inf(1)}
+	for i, s := range scores {
+		e := zsetElem("m", s)
+		if got := zsetScore(e); got != s {
+			t.Errorf("got %v, wanted %v", got, s)
+		}
+		if i > 0 && string(zsetElem("m", scores[i-1])) >= string(e) {
+			t.Errorf("encoding of %v does not sort after %v", s, scores[i-1])
+		}
+	}
+}
+
+func TestZSetMany(t *testing.T) {
+	table, tf, err := makeTable(50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tf.Name())
+
+	// Enough members to span several blocks, added out of order and then
+	// rescored
+	const n = 3000
+	z := table.ZSet("scores")
+	for i := 0; i < n; i++ {
+		j := (i * 7919) % n
+		if err := z.Add(fmt.Sprintf("m%04d", j), float64(j)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	for i := 0; i < n; i += 3 {
+		if err := z.Add(fmt.Sprintf("m%04d", i), float64(n+i)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	for i := 1; i < n; i += 3 {
+		if _, err := z.Remove(fmt.Sprintf("m%04d", i)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	check := func(table *Table, when string) {
+		z := table.ZSet("scores")
+		if z.Len() != 2*n/3 {
+			t.Errorf("%s: got len %d, wanted %d", when, z.Len(), 2*n/3)
+		}
+		if r, ok := z.Rank("m0002"); !ok || r != 0 {
+			t.Errorf("%s: got rank %d, wanted %d", when, r, 0)
+		}
+		if r, ok := z.Rank("m0000"); !ok || r != n/3 {
+			t.Errorf("%s: got rank %d, wanted %d", when, r, n/3)
+		}
+		if _, ok := z.Rank("m0001"); ok {
+			t.Errorf("%s: got rank for removed member, wanted none", when)
+		}
+		if s, _ := z.Score("m0003"); s != n+3 {
+			t.Errorf("%s: got score %v, wanted %v", when, s, n+3)
+		}
+		want := []ZMember{{"m0005", 5}, {"m0008", 8}}
+		if got := z.RangeByScore(4, 9); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, wanted %v", when, got, want)
+		}
+		want = []ZMember{{"m2997", n + 2997}, {"m2994", n + 2994}}
+		if got := z.Top(2); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, wanted %v", when, got, want)
+		}
+	}
+	check(table, "before reopen")
+
+	// The value read in full matches the sorted set
+	v, _ := table.Get("scores")
+	elems, err := decodeElems(v, typeZSet)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(elems) != 2*n/3 {
+		t.Errorf("got %d elements, wanted %d", len(elems), 2*n/3)
+	}
+	for i := 1; i < len(elems); i++ {
+		if string(elems[i-1]) >= string(elems[i]) {
+			t.Fatalf("got element %d out of order", i)
+		}
+	}
+	table.Close()
+
+	table2, err := New(tf.Name(), 50)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer table2.Close()
+	check(table2, "after reopen")
+}